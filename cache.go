@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/tfpath"
+)
+
+const cacheListTimeFormat = "2006-01-02 15:04:05"
+
+func newCacheCmd() *cobra.Command {
+	var cachePath string
+	var olderThan time.Duration
+	var unmanifested bool
+	var debug bool
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage module cache",
+		Long:  "Manage modules downloaded to the cache directory",
+	}
+
+	cacheCmd.PersistentFlags().StringVarP(&cachePath, "cache", "c", "", "Path to directory where modules are cached (required)")
+	cacheCmd.MarkPersistentFlagRequired("cache")
+	cacheCmd.MarkPersistentFlagDirname("cache")
+	cacheCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached modules",
+		Long:  "List modules recorded in the cache manifest",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(cacheListHandler(debug, cachePath))
+		},
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Prune cached modules",
+		Long:  "Remove expired and missing manifest entries, and optionally directories that are not in the manifest",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(cachePruneHandler(debug, cachePath, olderThan, unmanifested))
+		},
+	}
+
+	pruneCmd.Flags().DurationVarP(&olderThan, "older-than", "", 0, "Remove modules fetched earlier than this long ago (0 keeps all)")
+	pruneCmd.Flags().BoolVarP(&unmanifested, "unmanifested", "", false, "Remove directories that are not in the manifest too, eg. ones put there by hand for --offline")
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify cached modules",
+		Long:  "Check that cached module directories exist and their checksums match the manifest",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(cacheVerifyHandler(debug, cachePath))
+		},
+	}
+
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Clear cache",
+		Long:  "Remove all cached modules and the manifest",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(cacheClearHandler(debug, cachePath))
+		},
+	}
+
+	cacheCmd.AddCommand(listCmd)
+	cacheCmd.AddCommand(pruneCmd)
+	cacheCmd.AddCommand(verifyCmd)
+	cacheCmd.AddCommand(clearCmd)
+
+	return cacheCmd
+}
+
+func cacheListHandler(debug bool, cachePath string) int {
+	setLogger(debug)
+
+	cache := tfpath.NewCache(cachePath, 0, true, nil)
+	manifest := cache.Manifest()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "MODULE\tSOURCE\tREF\tCOMMIT\tFETCHED\tSIZE")
+
+	for _, entryKey := range manifest.EntryNamesSorted() {
+		entry := manifest.Entries[entryKey]
+
+		commit := entry.Commit
+		//nolint:mnd
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+
+		_, _ = fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\t%d\n",
+			entry.Module,
+			entry.SourceURL,
+			entry.Ref,
+			commit,
+			entry.FetchedAt.Local().Format(cacheListTimeFormat),
+			entry.Size,
+		)
+	}
+
+	_ = writer.Flush()
+
+	return 0
+}
+
+func cachePruneHandler(debug bool, cachePath string, olderThan time.Duration, unmanifested bool) int {
+	setLogger(debug)
+
+	cache := tfpath.NewCache(cachePath, 0, true, nil)
+
+	removed, err := cache.Prune(olderThan, unmanifested)
+	for _, dir := range removed {
+		slog.Info(fmt.Sprintf("🧹 Removed cached module 📁%s", dir))
+	}
+
+	if err != nil {
+		slog.Error("❌ Error pruning cache: " + err.Error())
+
+		return exitCodeErrPruningCache
+	}
+
+	slog.Info(fmt.Sprintf("🔸 Pruned %d cached modules", len(removed)))
+
+	return 0
+}
+
+func cacheVerifyHandler(debug bool, cachePath string) int {
+	setLogger(debug)
+
+	cache := tfpath.NewCache(cachePath, 0, true, nil)
+
+	failed := cache.Verify()
+	for _, entryKey := range cache.Manifest().EntryNamesSorted() {
+		err, isFailed := failed[entryKey]
+		if isFailed {
+			slog.Error(fmt.Sprintf("❌ Cached module 📦%s failed verification: %s", entryKey, err.Error()))
+
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("✅ Cached module 📦%s verified", entryKey))
+	}
+
+	if len(failed) > 0 {
+		return exitCodeErrVerifyingCache
+	}
+
+	return 0
+}
+
+func cacheClearHandler(debug bool, cachePath string) int {
+	setLogger(debug)
+
+	cache := tfpath.NewCache(cachePath, 0, true, nil)
+
+	removed, err := cache.Clear()
+	if err != nil {
+		slog.Error("❌ Error clearing cache: " + err.Error())
+
+		return exitCodeErrClearingCache
+	}
+
+	slog.Info(fmt.Sprintf("🧹 Removed %d cached modules", len(removed)))
+
+	return 0
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/findings"
+	"tfsketch/internal/policy"
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	checkFormatText  = "text"
+	checkFormatJSON  = "json"
+	checkFormatSARIF = "sarif"
+	checkFormatJUnit = "junit"
+)
+
+// checkOptions are values of the 'check' flags.
+type checkOptions struct {
+	terraformPath string
+	rulesPath     string
+	outputFile    string
+	format        string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+	typeRegexp        string
+	nameRegexp        string
+	displayAttributes string
+
+	overridesPath     string
+	cachePath         string
+	modulesMirrorPath string
+	gitBackendName    string
+
+	debug   bool
+	offline bool
+}
+
+func newCheckCmd() *cobra.Command {
+	var options checkOptions
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check Terraform files against rules",
+		Long:  "Check resource names, module sources, module nesting and for_each/count usage against a YAML rules file",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(checkHandler(&options))
+		},
+	}
+
+	checkCmd.Flags().StringVarP(&options.terraformPath, "path", "", "", "Path to directory with terraform code (required)")
+	checkCmd.MarkFlagRequired("path")
+	checkCmd.MarkFlagDirname("path")
+
+	checkCmd.Flags().StringVarP(&options.rulesPath, "rules", "", "", "YAML file with rules (required)")
+	checkCmd.MarkFlagRequired("rules")
+	checkCmd.MarkFlagFilename("rules")
+
+	checkCmd.Flags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (standard output if empty)")
+	checkCmd.MarkFlagFilename("output")
+	checkCmd.Flags().StringVarP(&options.format, "format", "", checkFormatText, "Output format: 'text', 'json', 'sarif' or 'junit'")
+
+	checkCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	checkCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+	checkCmd.Flags().StringVarP(&options.typeRegexp, "type-regexp", "t", "^.*$", "Regular expression to filter type of the resource")
+	checkCmd.Flags().StringVarP(&options.nameRegexp, "name-regexp", "n", "^.*$", "Regular expression to filter name of the resource")
+	checkCmd.Flags().StringVarP(
+		&options.displayAttributes, "display-attributes", "a", "",
+		"Comma-separated resource attributes; the first found is checked against name patterns",
+	)
+
+	checkCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	checkCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	checkCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	checkCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	checkCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+	checkCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+
+	return checkCmd
+}
+
+//nolint:funlen
+func checkHandler(options *checkOptions) int {
+	setLogger(options.debug)
+
+	// logs go to standard error so that they do not mix with the violations
+	if options.outputFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel(options.debug)})))
+	}
+
+	if !slices.Contains([]string{checkFormatText, checkFormatJSON, checkFormatSARIF, checkFormatJUnit}, options.format) {
+		slog.Error("❌ Unknown output format: " + options.format)
+
+		return exitCodeErrInvalidCheckArgs
+	}
+
+	rules := &policy.Rules{}
+
+	err := rules.ReadFromFile(options.rulesPath)
+	if err != nil {
+		slog.Error("❌ Error reading rules from file: " + err.Error())
+
+		return exitCodeErrReadingRules
+	}
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	var cache *tfpath.Cache
+	if options.cachePath != "" {
+		cache = tfpath.NewCache(options.cachePath, 0, options.offline, gitBackend)
+	}
+
+	container := tfpath.NewContainer()
+
+	traverser := tfpath.NewTraverser(
+		container,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		options.typeRegexp,
+		options.nameRegexp,
+		options.displayAttributes,
+		cache,
+	)
+
+	if options.modulesMirrorPath != "" {
+		container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+	}
+
+	rootTfPath, exitCode := scanTerraformPath(container, traverser, cache, options.overridesPath, options.terraformPath, ".")
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	violations := policy.NewChecker(rules).Check(rootTfPath)
+
+	output := ""
+
+	switch options.format {
+	case checkFormatJSON:
+		violationsBytes, err := json.MarshalIndent(violations, "", "  ")
+		if err != nil {
+			slog.Error("❌ Error marshalling violations: " + err.Error())
+
+			return exitCodeErrWritingCheckOutput
+		}
+
+		output = string(violationsBytes) + "\n"
+	case checkFormatSARIF, checkFormatJUnit:
+		findingsBytes, err := formatFindings(findings.FromViolations(violations, options.terraformPath), options.format, "tfsketch check")
+		if err != nil {
+			slog.Error("❌ Error marshalling violations: " + err.Error())
+
+			return exitCodeErrWritingCheckOutput
+		}
+
+		output = string(findingsBytes)
+	default:
+		builder := &strings.Builder{}
+		for _, violation := range violations {
+			_, _ = fmt.Fprintf(
+				builder,
+				"%s:%d: [%s] %s: %s\n",
+				violation.File,
+				violation.LineStart,
+				violation.Rule,
+				violation.Address,
+				violation.Message,
+			)
+		}
+
+		output = builder.String()
+	}
+
+	if options.outputFile == "" {
+		_, _ = fmt.Fprint(os.Stdout, output)
+	} else {
+		err = os.WriteFile(filepath.Clean(options.outputFile), []byte(output), newFilesMode)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error writing check output 📄%s: %s", options.outputFile, err.Error()))
+
+			return exitCodeErrWritingCheckOutput
+		}
+	}
+
+	if len(violations) > 0 {
+		slog.Warn(fmt.Sprintf("❗ %d rule violations found", len(violations)))
+
+		return exitCodeErrRuleViolations
+	}
+
+	slog.Info("🔸 No rule violations found")
+
+	return 0
+}
+
+// formatFindings returns findings in SARIF or JUnit format.
+func formatFindings(foundFindings []*findings.Finding, format, suiteName string) ([]byte, error) {
+	if format == checkFormatJUnit {
+		return findings.JUnit(foundFindings, suiteName)
+	}
+
+	return findings.SARIF(foundFindings)
+}
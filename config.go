@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/chart"
+	"tfsketch/internal/config"
+	"tfsketch/internal/tfpath"
+)
+
+// flagsNotInConfig contains 'gen' flags that cannot be set in the configuration file.
+var flagsNotInConfig = map[string]struct{}{
+	"path":      {},
+	"config":    {},
+	"view":      {},
+	"all-views": {},
+	"help":      {},
+}
+
+// loadGenConfig reads the configuration file passed in configPath, or found in configDir, sets 'gen' flags that are
+// not set in the command line from it, and returns the views to generate. It returns a non-zero exit code on failure.
+func loadGenConfig(cmd *cobra.Command, configDir, configPath string, viewNames []string, allViews bool) ([]*config.View, int) {
+	if configPath == "" {
+		configPath = config.Find(configDir)
+	}
+
+	if configPath == "" {
+		if len(viewNames) > 0 || allViews {
+			slog.Error("❌ Views are set but there is no config file, see --config")
+
+			return nil, exitCodeErrInvalidGenArgs
+		}
+
+		return nil, 0
+	}
+
+	slog.Info("🔸 Reading config file 📄" + configPath)
+
+	projectConfig := &config.Config{}
+
+	err := projectConfig.ReadFromFile(configPath)
+	if err != nil {
+		slog.Error("❌ Error reading config: " + err.Error())
+
+		return nil, exitCodeErrReadingConfig
+	}
+
+	for name, value := range projectConfig.Gen {
+		flag := cmd.Flags().Lookup(name)
+
+		_, isNotInConfig := flagsNotInConfig[name]
+		if flag == nil || isNotInConfig {
+			slog.Error(fmt.Sprintf("❌ Error reading config: unknown gen option '%s'", name))
+
+			return nil, exitCodeErrReadingConfig
+		}
+
+		// command line takes precedence
+		if flag.Changed {
+			continue
+		}
+
+		err = flag.Value.Set(value)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error reading config: invalid value of gen option '%s': %s", name, err.Error()))
+
+			return nil, exitCodeErrReadingConfig
+		}
+	}
+
+	if allViews {
+		viewNames = projectConfig.ViewNamesSorted()
+	}
+
+	views := make([]*config.View, 0, len(viewNames))
+
+	for _, viewName := range viewNames {
+		view, exists := projectConfig.Views[viewName]
+		if !exists {
+			slog.Error(
+				fmt.Sprintf(
+					"❌ View '%s' not found in config, available views: %s",
+					viewName,
+					strings.Join(projectConfig.ViewNamesSorted(), ", "),
+				),
+			)
+
+			return nil, exitCodeErrInvalidGenArgs
+		}
+
+		views = append(views, view)
+	}
+
+	return views, 0
+}
+
+// generateViews writes a chart of every view, with resources and sub-directories of the parsed root path filtered
+// with the view regular expressions. It returns a non-zero exit code on failure.
+func generateViews(
+	flowchart *chart.MermaidFlowChart,
+	rootTfPaths []*tfpath.TfPath,
+	views []*config.View,
+	typeRegexp, nameRegexp, outputFormat string,
+) int {
+	for _, view := range views {
+		viewTypeRegexp, err := regexp.Compile(valueOrDefault(view.TypeRegexp, typeRegexp))
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Invalid type regexp of view '%s': %s", view.Name, err.Error()))
+
+			return exitCodeErrInvalidGenArgs
+		}
+
+		viewNameRegexp, err := regexp.Compile(valueOrDefault(view.NameRegexp, nameRegexp))
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Invalid name regexp of view '%s': %s", view.Name, err.Error()))
+
+			return exitCodeErrInvalidGenArgs
+		}
+
+		viewTfPaths := make([]*tfpath.TfPath, 0, len(rootTfPaths))
+
+		for _, rootTfPath := range rootTfPaths {
+			viewTfPath := rootTfPath.FilterResources(viewTypeRegexp, viewNameRegexp)
+
+			// paths are already filtered with 'gen' regular expressions whilst walking
+			if view.PathIncludeRegexp != "" || view.PathExcludeRegexp != "" {
+				viewTfPath = viewTfPath.FilterChildren(
+					regexp.MustCompile(valueOrDefault(view.PathIncludeRegexp, "^.*$")),
+					regexp.MustCompile(valueOrDefault(view.PathExcludeRegexp, "^SillyName$")),
+				)
+			}
+
+			viewTfPaths = append(viewTfPaths, viewTfPath)
+		}
+
+		err = generateChart(flowchart, viewTfPaths, view.Output, valueOrDefault(view.Format, outputFormat))
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error generating chart of view '%s': %s", view.Name, err.Error()))
+
+			return exitCodeErrGeneratingChart
+		}
+
+		slog.Info(fmt.Sprintf("🔸 View '%s' written to 📄%s", view.Name, view.Output))
+	}
+
+	return 0
+}
+
+func viewFormats(views []*config.View) []string {
+	formats := []string{}
+
+	for _, view := range views {
+		if view.Format != "" {
+			formats = append(formats, view.Format)
+		}
+	}
+
+	return formats
+}
+
+func viewNamesList(views []*config.View) string {
+	names := make([]string, 0, len(views))
+	for _, view := range views {
+		names = append(names, view.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
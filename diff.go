@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/chart"
+	"tfsketch/internal/diff"
+	"tfsketch/internal/tfpath"
+)
+
+// diffOptions are values of the 'diff' flags.
+type diffOptions struct {
+	oldPath       string
+	newPath       string
+	terraformPath string
+	oldRef        string
+	newRef        string
+	outputFile    string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+	typeRegexp        string
+	nameRegexp        string
+	displayAttributes string
+
+	overridesPath     string
+	cachePath         string
+	modulesMirrorPath string
+	gitBackendName    string
+
+	debug            bool
+	offline          bool
+	includeFilenames bool
+	minify           bool
+}
+
+func newDiffCmd() *cobra.Command {
+	var options diffOptions
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare two trees",
+		Long: "Compare resources and modules of two directories, or of two git refs of a directory, and generate " +
+			"a diagram with the changes",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(diffHandler(&options))
+		},
+	}
+
+	diffCmd.Flags().StringVarP(&options.oldPath, "old", "", "", "Path to directory with the old terraform code")
+	diffCmd.MarkFlagDirname("old")
+	diffCmd.Flags().StringVarP(&options.newPath, "new", "", "", "Path to directory with the new terraform code")
+	diffCmd.MarkFlagDirname("new")
+
+	diffCmd.Flags().StringVarP(&options.terraformPath, "path", "", "", "Path to directory with terraform code in a git repository, compared between refs")
+	diffCmd.MarkFlagDirname("path")
+	diffCmd.Flags().StringVarP(&options.oldRef, "old-ref", "", "", "Git ref with the old terraform code")
+	diffCmd.Flags().StringVarP(&options.newRef, "new-ref", "", "", "Git ref with the new terraform code (working tree if empty)")
+
+	diffCmd.Flags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (required)")
+	diffCmd.MarkFlagRequired("output")
+	diffCmd.MarkFlagFilename("output")
+
+	diffCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	diffCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+	diffCmd.Flags().StringVarP(&options.typeRegexp, "type-regexp", "t", "^.*$", "Regular expression to filter type of the resource")
+	diffCmd.Flags().StringVarP(&options.nameRegexp, "name-regexp", "n", "^.*$", "Regular expression to filter name of the resource")
+	diffCmd.Flags().StringVarP(
+		&options.displayAttributes, "display-attributes", "a", "",
+		"Comma-separated resource attributes; the first found is used as the chart’s display name",
+	)
+
+	diffCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	diffCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	diffCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	diffCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	diffCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+
+	diffCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+	diffCmd.Flags().BoolVarP(&options.includeFilenames, "include-filenames", "f", false, "Display source filenames on the diagram")
+	diffCmd.Flags().BoolVarP(&options.minify, "minify", "s", false, "Minify element names in the chart to save space")
+
+	return diffCmd
+}
+
+//nolint:funlen
+func diffHandler(options *diffOptions) int {
+	slog.Info("🚀 tfsketch diff starting...")
+
+	setLogger(options.debug)
+
+	oldPath, newPath := options.oldPath, options.newPath
+
+	switch {
+	case oldPath != "" && newPath != "" && options.terraformPath == "" && options.oldRef == "" && options.newRef == "":
+	case options.terraformPath != "" && options.oldRef != "" && oldPath == "" && newPath == "":
+		oldWorktree, err := diff.NewWorktree(options.terraformPath, options.oldRef)
+		if err != nil {
+			slog.Error("❌ Error checking out old ref: " + err.Error())
+
+			return exitCodeErrCheckingOutDiffRef
+		}
+
+		defer oldWorktree.Remove()
+
+		oldPath = oldWorktree.Path
+		newPath = options.terraformPath
+
+		if options.newRef != "" {
+			newWorktree, err := diff.NewWorktree(options.terraformPath, options.newRef)
+			if err != nil {
+				slog.Error("❌ Error checking out new ref: " + err.Error())
+
+				return exitCodeErrCheckingOutDiffRef
+			}
+
+			defer newWorktree.Remove()
+
+			newPath = newWorktree.Path
+		}
+	default:
+		slog.Error("❌ Either --old and --new, or --path and --old-ref (with optional --new-ref) must be set")
+
+		return exitCodeErrInvalidDiffArgs
+	}
+
+	slog.Info("✨ Old terraform path:              " + oldPath)
+	slog.Info("✨ New terraform path:              " + newPath)
+	slog.Info("✨ Output diagram destination:      " + options.outputFile)
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	snapshots := make([]*diff.Snapshot, 0, 2) //nolint:mnd
+
+	for _, treePath := range []string{oldPath, newPath} {
+		// each tree gets its own container as the same modules can be different in each of them
+		container := tfpath.NewContainer()
+
+		// and its own cache, as modules downloaded for the old tree would otherwise be skipped in the new one
+		var cache *tfpath.Cache
+		if options.cachePath != "" {
+			cache = tfpath.NewCache(options.cachePath, 0, options.offline, gitBackend)
+		}
+
+		traverser := tfpath.NewTraverser(
+			container,
+			options.pathIncludeRegexp,
+			options.pathExcludeRegexp,
+			options.typeRegexp,
+			options.nameRegexp,
+			options.displayAttributes,
+			cache,
+		)
+
+		if options.modulesMirrorPath != "" {
+			container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+		}
+
+		rootTfPath, exitCode := scanTerraformPath(container, traverser, cache, options.overridesPath, treePath, ".")
+		if exitCode != 0 {
+			return exitCode
+		}
+
+		snapshots = append(snapshots, diff.NewSnapshot(rootTfPath))
+	}
+
+	changeset := diff.Compare(snapshots[0], snapshots[1])
+
+	slog.Info(
+		fmt.Sprintf(
+			"🔸 Resources added: %d, removed: %d, moved: %d, with changed names: %d",
+			len(changeset.AddedResources),
+			len(changeset.RemovedResources),
+			len(changeset.MovedResources),
+			len(changeset.ChangedDisplayNames),
+		),
+	)
+	slog.Info(
+		fmt.Sprintf(
+			"🔸 Modules added: %d, removed: %d, changed: %d",
+			len(changeset.AddedModules),
+			len(changeset.RemovedModules),
+			len(changeset.ChangedModules),
+		),
+	)
+
+	flowchart := chart.NewMermaidFlowChart(chart.Options{IncludeFilenames: options.includeFilenames, Minify: options.minify})
+
+	err = flowchart.GenerateDiff(changeset, options.outputFile)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error generating diff chart 📄%s: %s", options.outputFile, err.Error()))
+
+		return exitCodeErrGeneratingChart
+	}
+
+	return 0
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/drift"
+	"tfsketch/internal/lookup"
+	"tfsketch/internal/tfpath"
+)
+
+const driftFormatTable = "table"
+
+// driftOptions are values of the 'drift' flags.
+type driftOptions struct {
+	terraformPaths []string
+
+	outputFile string
+	format     string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+
+	overridesPath     string
+	cachePath         string
+	modulesMirrorPath string
+	gitBackendName    string
+
+	debug   bool
+	offline bool
+	latest  bool
+}
+
+func newDriftCmd() *cobra.Command {
+	var options driftOptions
+
+	driftCmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Report module version drift",
+		Long: "List every external module source with the versions it is used at and where, flag sources used at " +
+			"more than one version and optionally compare them with the latest version",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(driftHandler(cmd.Context(), &options))
+		},
+	}
+
+	driftCmd.Flags().StringSliceVarP(&options.terraformPaths, "path", "", []string{}, "Paths to directories with terraform code, optionally as 'name=path' (required)")
+	driftCmd.MarkFlagRequired("path")
+	driftCmd.MarkFlagDirname("path")
+
+	driftCmd.Flags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (standard output if empty)")
+	driftCmd.MarkFlagFilename("output")
+	driftCmd.Flags().StringVarP(&options.format, "format", "", driftFormatTable, "Output format: 'table' or 'json'")
+	driftCmd.Flags().BoolVarP(&options.latest, "latest", "", false, "Compare versions with the latest one in the cache and the registry (the cache only when offline)")
+
+	driftCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	driftCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+
+	driftCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	driftCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	driftCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	driftCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	driftCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+	driftCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+
+	return driftCmd
+}
+
+//nolint:funlen
+func driftHandler(ctx context.Context, options *driftOptions) int {
+	setLogger(options.debug)
+
+	// logs go to standard error so that they do not mix with the report
+	if options.outputFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel(options.debug)})))
+	}
+
+	if !slices.Contains([]string{driftFormatTable, checkFormatJSON}, options.format) {
+		slog.Error("❌ Unknown output format: " + options.format)
+
+		return exitCodeErrInvalidDriftArgs
+	}
+
+	rootNames, rootPaths, exitCode := parseRootPaths(options.terraformPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	var cache *tfpath.Cache
+	if options.cachePath != "" {
+		cache = tfpath.NewCache(options.cachePath, 0, options.offline, gitBackend)
+	}
+
+	container := tfpath.NewContainer()
+
+	// resources are not needed
+	traverser := tfpath.NewTraverser(
+		container,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		"^SillyName$",
+		"^.*$",
+		"",
+		cache,
+	)
+
+	if options.modulesMirrorPath != "" {
+		container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+	}
+
+	rootTfPaths, exitCode := scanTerraformPaths(container, traverser, cache, options.overridesPath, rootNames, rootPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	logMissingModules(container)
+
+	// external modules are the ones with source that is not a local path
+	matches := lookup.NewFinder(regexp.MustCompile(`^[^.]`), nil).Find(rootTfPaths)
+	report := drift.NewReport(matches, len(rootTfPaths) > 1)
+
+	if options.latest {
+		report.SetLatest(func(source string) string {
+			return latestModuleVersion(ctx, cache, source, options.offline)
+		})
+	}
+
+	output := ""
+
+	switch options.format {
+	case checkFormatJSON:
+		reportJSON := &strings.Builder{}
+
+		// paths contain '>' that is kept as it is
+		encoder := json.NewEncoder(reportJSON)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(report)
+		if err != nil {
+			slog.Error("❌ Error marshalling drift report: " + err.Error())
+
+			return exitCodeErrWritingDriftOutput
+		}
+
+		output = reportJSON.String()
+	default:
+		output = driftTable(report, options.latest)
+	}
+
+	if options.outputFile == "" {
+		_, _ = fmt.Fprint(os.Stdout, output)
+	} else {
+		err = os.WriteFile(filepath.Clean(options.outputFile), []byte(output), newFilesMode)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error writing drift report 📄%s: %s", options.outputFile, err.Error()))
+
+			return exitCodeErrWritingDriftOutput
+		}
+	}
+
+	driftCount := report.DriftCount()
+	if driftCount > 0 {
+		slog.Warn(fmt.Sprintf("❗ %d of %d module sources are used at more than one version", driftCount, len(report.Sources)))
+	} else {
+		slog.Info(fmt.Sprintf("🔸 All %d module sources are used at one version", len(report.Sources)))
+	}
+
+	return 0
+}
+
+// latestModuleVersion returns the latest exact version of the source in the cache manifest and the registry, or
+// an empty string when none is found.
+func latestModuleVersion(ctx context.Context, cache *tfpath.Cache, source string, offline bool) string {
+	versions := []string{}
+
+	if cache != nil {
+		versions = append(versions, cache.Manifest().ModuleVersions(source)...)
+	}
+
+	if !offline {
+		registryVersions, err := tfpath.RegistryModuleVersions(ctx, source)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("🔸 Latest version of 📦%s not found in registry: %s", source, err.Error()))
+		}
+
+		versions = append(versions, registryVersions...)
+	}
+
+	return drift.Latest(versions)
+}
+
+// driftTable returns the report as a table with a row for every version of every source, and the paths using it.
+func driftTable(report *drift.Report, latest bool) string {
+	builder := &strings.Builder{}
+	writer := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+
+	if latest {
+		_, _ = fmt.Fprintln(writer, "SOURCE\tVERSION\tDRIFT\tLATEST\tPATHS")
+	} else {
+		_, _ = fmt.Fprintln(writer, "SOURCE\tVERSION\tDRIFT\tPATHS")
+	}
+
+	for _, source := range report.Sources {
+		driftMark := ""
+		if source.Drift {
+			driftMark = "yes"
+		}
+
+		for _, version := range source.Versions {
+			latestMark := source.Latest
+			if version.Outdated {
+				latestMark += " (outdated)"
+			}
+
+			for i, path := range version.Paths {
+				// source and version are written once, in the first row
+				sourceCell, versionCell, driftCell, latestCell := source.Source, version.Version, driftMark, latestMark
+				if i > 0 {
+					sourceCell, versionCell, driftCell, latestCell = "", "", "", ""
+				}
+
+				if latest {
+					_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", sourceCell, versionCell, driftCell, latestCell, path)
+				} else {
+					_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", sourceCell, versionCell, driftCell, path)
+				}
+			}
+		}
+	}
+
+	_ = writer.Flush()
+
+	return builder.String()
+}
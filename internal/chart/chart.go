@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 
+	"tfsketch/internal/graph"
 	"tfsketch/internal/tfpath"
 )
 
@@ -31,42 +32,63 @@ flowchart LR
   classDef tf-name fill:#eb91c7
 `
 
+// optionalClassDefs are written after the config only when an element of the class is drawn, so that charts without
+// them stay the same.
+var optionalClassDefs = []struct {
+	class string
+	style string
+}{
+	{class: "tf-lock", style: "fill:#f5f5f5,stroke:#c87de8,text-align:left"},
+	{class: "tf-added", style: "fill:#b6f2c3,stroke:#2e9e4a,text-align:left"},
+	{class: "tf-removed", style: "fill:#f7b6b6,stroke:#c62f2f,text-align:left,stroke-dasharray:4"},
+	{class: "tf-changed", style: "fill:#fbe3a6,stroke:#d19a0b,text-align:left"},
+	{class: "tf-moved", style: "fill:#c5d9fb,stroke:#3c6fd1,text-align:left"},
+	{class: "tf-cycle", style: "fill:#f5f5f5,stroke:#c62f2f,text-align:left,stroke-dasharray:4"},
+	{class: "tf-truncated", style: "fill:#f5f5f5,stroke:#999999,text-align:left,stroke-dasharray:4"},
+}
+
 const (
 	elementSeparator = "__"
 	partSeparator    = "_"
 )
 
-const maxWriteModulesDepth = 5
-
 var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9 ]+`)
 
 const newFilesMode = 0o600
 
+// Options decide how the chart is drawn, on top of the options of the graph it is drawn from.
+type Options struct {
+	graph.Options
+
+	// IncludeFilenames adds source filenames to labels
+	IncludeFilenames bool
+	// Minify replaces element IDs with short ones to save space
+	Minify bool
+}
+
 // MermaidFlowChart represents a flowchart.
 type MermaidFlowChart struct {
-	onlyRoot           bool
-	includeFilenames   bool
-	minify             bool
-	module             bool
+	options            Options
 	chart              *strings.Builder
 	summary            *Summary
 	idNum              int
 	minifiedElementIDs *map[string]string
+	usedClasses        map[string]struct{}
+	graph              *graph.Graph
 }
 
-// NewMermaidFlowChart returns a MermaidFlowChart instance.
-func NewMermaidFlowChart(onlyRoot, includeFilenames, minify, module bool) *MermaidFlowChart {
+// NewMermaidFlowChart returns a MermaidFlowChart instance drawing charts as set in options.
+func NewMermaidFlowChart(options Options) *MermaidFlowChart {
 	minifiedElementIDs := map[string]string{}
 
 	flowchart := &MermaidFlowChart{
 		chart:              &strings.Builder{},
-		onlyRoot:           onlyRoot,
-		includeFilenames:   includeFilenames,
-		minify:             minify,
-		module:             module,
+		options:            options,
 		summary:            NewSummary(),
 		idNum:              0,
 		minifiedElementIDs: &minifiedElementIDs,
+		usedClasses:        map[string]struct{}{},
+		graph:              graph.NewGraph(),
 	}
 
 	return flowchart
@@ -76,18 +98,95 @@ func NewMermaidFlowChart(onlyRoot, includeFilenames, minify, module bool) *Merma
 func (m *MermaidFlowChart) Reset() {
 	m.chart.Reset()
 	m.summary.Reset()
+	m.graph = graph.NewGraph()
 	m.idNum = 0
+	m.usedClasses = map[string]struct{}{}
 
 	minifiedElementIDs := map[string]string{}
 	m.minifiedElementIDs = &minifiedElementIDs
 }
 
-// Generate takes a path to Terraform code and generates chart file.
-func (m *MermaidFlowChart) Generate(tfPath *tfpath.TfPath, outputFile string) error {
+// Render takes paths to Terraform code and returns the chart, without writing any files. When there is more than one
+// path, each is drawn as a group, and external modules called from more than one of them are linked.
+func (m *MermaidFlowChart) Render(tfPaths []*tfpath.TfPath) string {
 	m.Reset()
 
+	m.graph = graph.NewBuilder(m.options.Options).Build(tfPaths)
+
+	for _, node := range m.graph.Roots {
+		switch node.Kind {
+		case graph.KindRoot, graph.KindDeployment:
+			m.writeRoot(node)
+		case graph.KindSharedModule:
+			_, _ = fmt.Fprintf(m.chart, "  %s\n", m.sharedModuleElement(node))
+		default:
+			m.writePath(node)
+		}
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeUses) {
+		_, _ = fmt.Fprintf(m.chart, "  %s -.-> %s\n", m.nodeElementID(edge.From), m.nodeElementID(edge.To))
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeDependsOn) {
+		_, _ = fmt.Fprintf(
+			m.chart,
+			"  %s ==>|%s| %s\n",
+			m.nodeElementID(edge.From),
+			m.escapeLabel(edge.Label),
+			m.nodeElementID(edge.To),
+		)
+	}
+
+	for _, moduleCall := range m.graph.ModuleCalls {
+		m.summary.AddModule(moduleCall)
+	}
+
+	discrepancies := tfpath.ProviderLockDiscrepanciesAcross(tfPaths)
+	for providerKey, versions := range discrepancies {
+		slog.Warn(
+			fmt.Sprintf(
+				"🔒 Provider %s is locked at different versions across paths: %v",
+				providerKey,
+				versions,
+			),
+		)
+	}
+
+	m.summary.SetProviderLockDiscrepancies(discrepancies)
+
+	m.prependConfig()
+
+	return m.chart.String()
+}
+
+// prependConfig writes the config, with definitions of the optional classes used in the chart, before the elements.
+func (m *MermaidFlowChart) prependConfig() {
+	elements := m.chart.String()
+
+	m.chart.Reset()
 	m.chart.WriteString(config)
-	m.writePath(tfPath)
+
+	for _, classDef := range optionalClassDefs {
+		_, used := m.usedClasses[classDef.class]
+		if used {
+			_, _ = fmt.Fprintf(m.chart, "  classDef %s %s\n", classDef.class, classDef.style)
+		}
+	}
+
+	m.chart.WriteString(elements)
+}
+
+// useClass marks class as used in the chart and returns it.
+func (m *MermaidFlowChart) useClass(class string) string {
+	m.usedClasses[class] = struct{}{}
+
+	return class
+}
+
+// Generate takes paths to Terraform code and generates chart file.
+func (m *MermaidFlowChart) Generate(tfPaths []*tfpath.TfPath, outputFile string) error {
+	m.Render(tfPaths)
 
 	err := os.WriteFile(filepath.Clean(outputFile), []byte(m.chart.String()), newFilesMode)
 	if err != nil {
@@ -100,18 +199,7 @@ func (m *MermaidFlowChart) Generate(tfPath *tfpath.TfPath, outputFile string) er
 
 	summaryFile := outputFile + ".json"
 
-	summaryBytes, err := json.Marshal(m.summary)
-	if err != nil {
-		slog.Error(
-			"error marshaling summary",
-			slog.String("path", summaryFile),
-			slog.String("error", err.Error()),
-		)
-
-		return nil
-	}
-
-	err = os.WriteFile(filepath.Clean(summaryFile), summaryBytes, newFilesMode)
+	err = m.writeSummary(summaryFile)
 	if err != nil {
 		slog.Error(
 			"error writing summary file",
@@ -123,269 +211,251 @@ func (m *MermaidFlowChart) Generate(tfPath *tfpath.TfPath, outputFile string) er
 	return nil
 }
 
-func (m *MermaidFlowChart) writePath(tfPath *tfpath.TfPath) {
-	elPath, elID := m.pathElement(tfPath)
-	_, _ = fmt.Fprintf(m.chart, "  p%s%s\n", partSeparator, elPath)
-
-	// path resources
-	m.writePathResources(tfPath, elID, false, false)
+// writeOutput writes the chart in a format other than Mermaid, and the summary next to it.
+func (m *MermaidFlowChart) writeOutput(outputFile string, output []byte) error {
+	err := os.WriteFile(filepath.Clean(outputFile), output, newFilesMode)
+	if err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
 
-	// path modules
-	m.writePathModules(tfPath, elID, "", "", false, 1)
+	return m.writeSummary(outputFile + ".json")
+}
 
-	// sub-paths
-	if m.onlyRoot {
-		return
+func (m *MermaidFlowChart) writeSummary(summaryFile string) error {
+	summaryBytes, err := json.Marshal(m.summary)
+	if err != nil {
+		return fmt.Errorf("error marshaling summary: %w", err)
 	}
 
-	sortedPaths := tfPath.ChildrenNamesSorted()
-	for _, childKey := range sortedPaths {
-		childTfPath := tfPath.Children[childKey]
-		if childTfPath == nil {
-			continue
-		}
+	err = os.WriteFile(filepath.Clean(summaryFile), summaryBytes, newFilesMode)
+	if err != nil {
+		return fmt.Errorf("error writing summary: %w", err)
+	}
 
-		// not module
-		_, isModule := tfPath.IsChildModule[childKey]
-		if isModule {
-			continue
-		}
+	return nil
+}
 
-		// ignore 'modules' sub-directory
-		if childTfPath.RelPath == "modules" {
-			continue
-		}
+// writeRoot writes root or deployment as a subgraph containing its paths, and deployments of the root.
+func (m *MermaidFlowChart) writeRoot(rootNode *graph.Node) {
+	_, _ = fmt.Fprintf(
+		m.chart,
+		"  subgraph %s [\"%s\"]\n",
+		m.nodeElementID(rootNode.ID),
+		m.escapeLabel(strings.Join(m.labelLines(rootNode), " ")),
+	)
 
-		// only first-depth sub-directories, unless module is drawn
-		if strings.Contains(childTfPath.RelPath, "/") {
-			if !m.module {
-				continue
-			}
+	for _, child := range rootNode.Children {
+		if child.Kind == graph.KindDeployment {
+			m.writeRoot(child)
 
-			if !strings.HasPrefix(childTfPath.RelPath, "modules/") {
-				continue
-			}
+			continue
 		}
 
-		elChildPath, elChildID := m.pathElement(childTfPath)
-		_, _ = fmt.Fprintf(m.chart, "  p%s%s\n", partSeparator, elChildPath)
+		m.writePath(child)
+	}
 
-		// resources
-		m.writePathResources(childTfPath, elChildID, false, false)
+	m.chart.WriteString("  end\n")
+}
 
-		// modules
-		m.writePathModules(childTfPath, elChildID, "", "", false, 1)
+func (m *MermaidFlowChart) writePath(pathNode *graph.Node) {
+	elPathID := m.nodeElementID(pathNode.ID)
+	_, _ = fmt.Fprintf(
+		m.chart,
+		"  %s[\"%s\"]:::tf-path\n",
+		elPathID,
+		pathLabel(pathNode),
+	)
+
+	relPath := pathLabel(pathNode)
+	if pathNode.Root != "" {
+		relPath = pathNode.Root + ":" + relPath
 	}
-}
 
-func (m *MermaidFlowChart) writePathResources(
-	tfPath *tfpath.TfPath,
-	elID string,
-	isPathModule, forceMultiple bool,
-) {
-	sortedResources := tfPath.ResourceNamesSorted()
-	for _, resourceKey := range sortedResources {
-		resource := tfPath.Resources[resourceKey]
-		if resource == nil {
-			continue
+	m.summary.AddProviderLocks(relPath, pathNode.ProviderLocks)
+
+	for _, child := range pathNode.Children {
+		switch child.Kind {
+		case graph.KindResource:
+			m.writeResource(child, elPathID, "---->")
+		case graph.KindProviderLocks:
+			_, _ = fmt.Fprintf(m.chart, "  %s -.- %s\n", elPathID, m.providerLocksElement(child))
+		case graph.KindModule:
+			m.writeModule(child, elPathID, "")
+		case graph.KindCycle, graph.KindTruncated:
+			_, _ = fmt.Fprintf(m.chart, "  %s --> %s\n", elPathID, m.stopElement(child, ""))
 		}
+	}
+}
 
-		elResource, elResourceID, _, isMultiple := m.resourceElement(resource, elID)
-		if isPathModule {
-			_, _ = fmt.Fprintf(
-				m.chart,
-				"  m%s%s ---> r%s%s\n",
-				partSeparator,
-				elID,
-				partSeparator,
-				elResource,
-			)
-		} else {
-			_, _ = fmt.Fprintf(m.chart, "  p%s%s ----> r%s%s\n", partSeparator, elID, partSeparator, elResource)
-		}
+func (m *MermaidFlowChart) writeResource(resourceNode *graph.Node, elParentID, arrow string) {
+	elResourceID := m.nodeElementID(resourceNode.ID)
+	_, _ = fmt.Fprintf(m.chart, "  %s %s %s\n", elParentID, arrow, m.resourceElement(resourceNode))
 
-		elName, elNameID, elNameLabel := m.nameElement(
-			resource,
-			elResourceID,
-			isMultiple || forceMultiple,
-		)
-		_, _ = fmt.Fprintf(
-			m.chart,
-			"  r%s%s ---> n%s%s\n",
-			partSeparator,
-			elResourceID,
-			partSeparator,
-			elName,
-		)
+	for _, nameNode := range resourceNode.ChildrenOfKind(graph.KindName) {
+		elName, elNameID, elNameLabel := m.nameElement(nameNode)
+		_, _ = fmt.Fprintf(m.chart, "  %s ---> %s\n", elResourceID, elName)
 
-		m.summary.AddEdge(fmt.Sprintf("n%s%s", partSeparator, elNameID))
+		m.summary.AddEdge(elNameID)
 		m.summary.AddName(elNameLabel)
 	}
 }
 
-func (m *MermaidFlowChart) writePathModules(
-	tfPath *tfpath.TfPath,
-	elPathID, elParentModuleID, elParentModuleLabel string,
-	forceMultiple bool,
-	depth int,
-) {
-	if depth > maxWriteModulesDepth {
-		return
+// writeModule writes module with its resources linked to the path, and then modules it calls, with label
+// containing labels of all the modules they are called from.
+func (m *MermaidFlowChart) writeModule(moduleNode *graph.Node, elPathID, parentLabel string) {
+	label := m.moduleLabel(moduleNode)
+	if parentLabel != "" {
+		label = parentLabel + "<br><b>/</b><br>" + label
 	}
 
-	if tfPath == nil {
-		return
-	}
-
-	sortedModules := tfPath.ModuleNamesSorted()
-	for _, moduleKey := range sortedModules {
-		module := tfPath.Modules[moduleKey]
-		if module == nil {
-			continue
-		}
-
-		if !strings.HasPrefix(module.FieldSource, ".") {
-			m.summary.AddModule(module.FieldSource + "@" + module.FieldVersion)
-		}
+	resourceNodes := moduleNode.ChildrenOfKind(graph.KindResource)
+	if len(resourceNodes) > 0 {
+		elModuleID := m.nodeElementID(moduleNode.ID)
+		_, _ = fmt.Fprintf(m.chart, "  %s --> %s[\"%s\"]:::tf-int-mod\n", elPathID, elModuleID, label)
 
-		if module.TfPath == nil {
-			continue
+		for _, resourceNode := range resourceNodes {
+			m.writeResource(resourceNode, elModuleID, "--->")
 		}
+	}
 
-		elModule, elModuleID, elModuleLabel, isMultiple := m.moduleElement(
-			module,
-			elPathID,
-			elParentModuleID,
-			elParentModuleLabel,
-		)
-		if len(module.TfPath.Resources) > 0 {
-			_, _ = fmt.Fprintf(
-				m.chart,
-				"  p%s%s --> m%s%s\n",
-				partSeparator,
-				elPathID,
-				partSeparator,
-				elModule,
-			)
-
-			// resources
-			m.writePathResources(module.TfPath, elModuleID, true, isMultiple || forceMultiple)
+	for _, child := range moduleNode.Children {
+		switch child.Kind {
+		case graph.KindModule:
+			m.writeModule(child, elPathID, label)
+		case graph.KindCycle, graph.KindTruncated:
+			_, _ = fmt.Fprintf(m.chart, "  %s --> %s\n", elPathID, m.stopElement(child, label))
 		}
-
-		// modules
-		m.writePathModules(
-			module.TfPath,
-			elPathID,
-			elModuleID,
-			elModuleLabel,
-			isMultiple || forceMultiple,
-			depth+1,
-		)
 	}
 }
 
-//nolint:varnamelen
-func (m *MermaidFlowChart) pathElement(tfPath *tfpath.TfPath) (string, string) {
-	id := m.elementID(tfPath.RelPath)
-	label := tfPath.RelPath
-
-	if id == "" {
-		id = "root"
+// stopElement returns a module call that is not followed, with label containing labels of all the modules it is
+// called from, and the reason.
+func (m *MermaidFlowChart) stopElement(stopNode *graph.Node, parentLabel string) string {
+	label := m.moduleLabel(stopNode) + "<br><i>" + m.escapeLabel(stopNode.Reason) + "</i>"
+	if parentLabel != "" {
+		label = parentLabel + "<br><b>/</b><br>" + label
 	}
 
-	if label == "" {
-		label = "."
-	}
-
-	return fmt.Sprintf("%s[\"%s\"]:::tf-path", id, label), id
+	return fmt.Sprintf("%s[\"%s\"]:::%s", m.nodeElementID(stopNode.ID), label, m.useClass(nodeClass(stopNode)))
 }
 
-//nolint:varnamelen
-func (m *MermaidFlowChart) resourceElement(
-	resource *tfpath.TfResource,
-	elPathID string,
-) (string, string, string, bool) {
-	id := elPathID + elementSeparator + m.elementID(resource.Type+partSeparator+resource.Name)
-	label := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+func (m *MermaidFlowChart) resourceElement(resourceNode *graph.Node) string {
+	label := fmt.Sprintf("%s.%s", resourceNode.Type, m.escapeLabel(resourceNode.Name))
 
-	isMultiple := false
+	if resourceNode.ForEach != "" {
+		label += "<br>*for_each = " + m.escapeLabel(resourceNode.ForEach) + "*"
+	}
 
-	if resource.FieldForEach != "" {
-		label += "<br>*for_each = " + m.escapeLabel(resource.FieldForEach) + "*"
-		isMultiple = true
+	if resourceNode.Keys != nil {
+		label += "<br>*" + m.escapeLabel(keysText(resourceNode)) + "*"
 	}
 
-	if m.includeFilenames {
-		label += "<br><i>(" + m.escapeLabel(resource.FilePath) + ")</i>"
+	if m.options.IncludeFilenames {
+		label += "<br><i>(" + m.escapeLabel(resourceNode.FilePath) + ")</i>"
 	}
 
-	return fmt.Sprintf("%s[\"%s\"]:::tf-resource", id, label), id, label, isMultiple
+	return fmt.Sprintf("%s[\"%s\"]:::tf-resource", m.nodeElementID(resourceNode.ID), label)
 }
 
 //nolint:varnamelen
-func (m *MermaidFlowChart) nameElement(
-	resource *tfpath.TfResource,
-	elResourceID string,
-	isMultiple bool,
-) (string, string, string) {
-	id := elResourceID + partSeparator + "n"
-	label := m.escapeLabel(resource.FieldName)
-
-	if isMultiple {
+func (m *MermaidFlowChart) nameElement(nameNode *graph.Node) (string, string, string) {
+	id := m.nodeElementID(nameNode.ID)
+	label := m.escapeLabel(nameNode.DisplayName)
+
+	if nameNode.Multiple {
 		return fmt.Sprintf("%s:::tf-name@{ shape: procs, label: \"%s\"}", id, label), id, label
 	}
 
 	return fmt.Sprintf("%s[\"%s\"]:::tf-name", id, label), id, label
 }
 
-//nolint:varnamelen
-func (m *MermaidFlowChart) moduleElement(
-	module *tfpath.TfModule,
-	elPathID, elParentModuleID, elParentModuleLabel string,
-) (string, string, string, bool) {
-	id := elPathID + elementSeparator
-	if elParentModuleID != "" {
-		id += elParentModuleID + elementSeparator
+func (m *MermaidFlowChart) providerLocksElement(locksNode *graph.Node) string {
+	label := "<b>provider locks</b>"
+
+	for _, providerLock := range sortedProviderLocks(locksNode.ProviderLocks) {
+		label += "<br>" + m.escapeLabel(providerLock.Source) + " = " + m.escapeLabel(providerLock.Version)
 	}
 
-	id += m.elementID(module.Name)
+	return fmt.Sprintf("%s[\"%s\"]:::%s", m.nodeElementID(locksNode.ID), label, m.useClass("tf-lock"))
+}
 
-	source := module.FieldSource
-	version := module.FieldVersion
+func (m *MermaidFlowChart) sharedModuleElement(sharedNode *graph.Node) string {
+	return fmt.Sprintf(
+		"%s[\"%s(at)%s<br><i>used in %s</i>\"]:::tf-ext-mod",
+		m.nodeElementID(sharedNode.ID),
+		m.escapeLabel(sharedNode.Source),
+		m.escapeLabel(sharedNode.Version),
+		m.escapeLabel(strings.Join(sharedNode.Roots, ", ")),
+	)
+}
 
+func (m *MermaidFlowChart) moduleLabel(moduleNode *graph.Node) string {
 	var label string
-	if elParentModuleLabel != "" {
-		label += elParentModuleLabel + "<br><b>/</b><br>"
+
+	switch {
+	case moduleNode.Label != "":
+		label = fmt.Sprintf("%s<br>%s", m.escapeLabel(moduleNode.ModuleAddress()), m.escapeLabel(moduleNode.Label))
+	case !strings.HasPrefix(moduleNode.Source, "."):
+		label = fmt.Sprintf(
+			"%s<br>%s(at)%s",
+			m.escapeLabel(moduleNode.ModuleAddress()),
+			m.escapeLabel(moduleNode.Source),
+			m.escapeLabel(moduleNode.Version),
+		)
+	default:
+		label = fmt.Sprintf("%s<br>%s", m.escapeLabel(moduleNode.ModuleAddress()), m.escapeLabel(moduleNode.Source))
 	}
 
-	label += fmt.Sprintf("module.%s<br>%s", module.Name, m.escapeLabel(source))
+	if moduleNode.ForEach != "" {
+		label += "<br>*for_each = " + m.escapeLabel(moduleNode.ForEach) + "*"
+	}
 
-	if !strings.HasPrefix(source, ".") {
-		label += "(at)" + m.escapeLabel(version)
+	if moduleNode.Keys != nil {
+		label += "<br>*" + m.escapeLabel(keysText(moduleNode)) + "*"
 	}
 
-	isMultiple := false
+	if m.options.IncludeFilenames {
+		label += "<br><i>(" + m.escapeLabel(moduleNode.FilePath) + ")</i>"
+	}
 
-	if module.FieldForEach != "" {
-		label += "<br>*for_each = " + m.escapeLabel(module.FieldForEach) + "*"
-		isMultiple = true
+	return label
+}
+
+// nodeElementID returns ID of the element in the chart, which is the graph node ID, or its minified version.
+func (m *MermaidFlowChart) nodeElementID(nodeID string) string {
+	if !m.options.Minify {
+		return nodeID
+	}
+
+	// node ID is a prefix with parts joined with a separator, and an optional suffix, eg. 'n_root__typename_n'
+	prefix, id, _ := strings.Cut(nodeID, partSeparator)
+
+	suffix := ""
+	if strings.HasSuffix(id, partSeparator+prefix) {
+		suffix = partSeparator + prefix
+		id = strings.TrimSuffix(id, suffix)
 	}
 
-	if m.includeFilenames {
-		label += "<br><i>(" + m.escapeLabel(module.FilePath) + ")</i>"
+	parts := strings.Split(id, elementSeparator)
+	for i, part := range parts {
+		parts[i] = m.minifiedID(part)
 	}
 
-	return fmt.Sprintf("%s[\"%s\"]:::tf-int-mod", id, label), id, label, isMultiple
+	return prefix + partSeparator + strings.Join(parts, elementSeparator) + suffix
 }
 
 func (m *MermaidFlowChart) elementID(text string) string {
 	text = strings.ReplaceAll(text, "/", "_")
 	text = m.removeNonAlphanumericChars(text)
 
-	if !m.minify {
+	if !m.options.Minify {
 		return text
 	}
 
+	return m.minifiedID(text)
+}
+
+func (m *MermaidFlowChart) minifiedID(text string) string {
 	minifiedIDs := *m.minifiedElementIDs
 
 	minified, exists := minifiedIDs[text]
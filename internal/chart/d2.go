@@ -0,0 +1,102 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+
+	"tfsketch/internal/graph"
+	"tfsketch/internal/tfpath"
+)
+
+const d2Config = `direction: right
+classes: {
+  tf-root: {style.fill: "#ffffff"}
+  tf-deployment: {style.fill: "#f5f5f5"}
+  tf-path: {style.fill: "#c87de8"}
+  tf-int-mod: {style.fill: "#e7b6fc"}
+  tf-ext-mod: {style.fill: "#7da8e8"}
+  tf-resource: {style: {stroke: "#e7b6fc"; font-color: "#c87de8"}}
+  tf-name: {style.fill: "#eb91c7"}
+  tf-lock: {style: {fill: "#f5f5f5"; stroke: "#c87de8"}}
+  tf-cycle: {style: {fill: "#f5f5f5"; stroke: "#c62f2f"; stroke-dash: 3}}
+  tf-truncated: {style: {fill: "#f5f5f5"; stroke: "#999999"; stroke-dash: 3}}
+}
+`
+
+// GenerateD2 takes paths to Terraform code and generates a D2 diagram, with roots, paths and modules drawn as
+// containers with their resources. Summary is written as a JSON file next to it.
+func (m *MermaidFlowChart) GenerateD2(tfPaths []*tfpath.TfPath, outputFile string) error {
+	m.Render(tfPaths)
+
+	diagram := &strings.Builder{}
+	diagram.WriteString(d2Config)
+
+	for _, root := range m.graph.Roots {
+		m.writeD2Node(diagram, root, "")
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeUses) {
+		_, _ = fmt.Fprintf(diagram, "%s -> %s: {style.stroke-dash: 3}\n", m.d2Path(edge.From), edge.To)
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeDependsOn) {
+		_, _ = fmt.Fprintf(
+			diagram,
+			"%s -> %s: \"%s\" {style.stroke-width: 3}\n",
+			m.d2Path(edge.From),
+			m.d2Path(edge.To),
+			m.d2Label([]string{edge.Label}),
+		)
+	}
+
+	return m.writeOutput(outputFile, []byte(diagram.String()))
+}
+
+func (m *MermaidFlowChart) writeD2Node(diagram *strings.Builder, node *graph.Node, indent string) {
+	_, _ = fmt.Fprintf(diagram, "%s%s: \"%s\" {\n", indent, node.ID, m.d2Label(m.labelLines(node)))
+	_, _ = fmt.Fprintf(diagram, "%s  class: %s\n", indent, nodeClass(node))
+
+	if isDrawnMultiple(node) {
+		_, _ = fmt.Fprintf(diagram, "%s  style.multiple: true\n", indent)
+	}
+
+	if node.IsContainer() {
+		for _, child := range node.Children {
+			m.writeD2Node(diagram, child, indent+"  ")
+		}
+
+		_, _ = fmt.Fprintf(diagram, "%s}\n", indent)
+
+		return
+	}
+
+	_, _ = fmt.Fprintf(diagram, "%s}\n", indent)
+
+	// resource names are drawn next to the resource
+	for _, child := range node.Children {
+		m.writeD2Node(diagram, child, indent)
+		_, _ = fmt.Fprintf(diagram, "%s%s -> %s\n", indent, node.ID, child.ID)
+	}
+}
+
+func (m *MermaidFlowChart) d2Label(lines []string) string {
+	escaped := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, "\\", "\\\\")
+		line = strings.ReplaceAll(line, "$", "\\$") // no variable substitutions
+		escaped = append(escaped, strings.ReplaceAll(line, "\"", "\\\""))
+	}
+
+	return strings.Join(escaped, "\\n")
+}
+
+// d2Path returns the node ID prefixed with IDs of all the containers it is in, as D2 needs it to link nested shapes.
+func (m *MermaidFlowChart) d2Path(nodeID string) string {
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeContains) {
+		if edge.To == nodeID {
+			return m.d2Path(edge.From) + "." + nodeID
+		}
+	}
+
+	return nodeID
+}
@@ -0,0 +1,169 @@
+package chart
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tfsketch/internal/diff"
+)
+
+// GenerateDiff takes changes between two trees and generates a chart file with added, removed, moved and changed
+// resources and modules grouped by directory, and the changeset as a JSON file next to it.
+func (m *MermaidFlowChart) GenerateDiff(changeset *diff.Changeset, outputFile string) error {
+	m.Reset()
+
+	declaredDirs := map[string]string{}
+
+	for _, resource := range changeset.AddedResources {
+		m.writeDiffResource(declaredDirs, resource, "+ ", "", "tf-added")
+	}
+
+	for _, resource := range changeset.RemovedResources {
+		m.writeDiffResource(declaredDirs, resource, "- ", "", "tf-removed")
+	}
+
+	for _, change := range changeset.ChangedDisplayNames {
+		m.writeDiffResource(
+			declaredDirs,
+			change.Resource,
+			"~ ",
+			m.escapeLabel(change.From)+" → "+m.escapeLabel(change.To),
+			"tf-changed",
+		)
+	}
+
+	for _, move := range changeset.MovedResources {
+		from := move.From.Dir + ":" + move.From.Address
+		if move.From.Dir == move.To.Dir && move.From.Address == move.To.Address {
+			from = move.From.File
+		}
+
+		elID := m.writeDiffResource(declaredDirs, move.To, "→ ", "moved from "+m.escapeLabel(from), "tf-moved")
+
+		// link the resource to the directory it was moved from too
+		if move.From.Dir != move.To.Dir {
+			elFromDirID := m.writeDiffDir(declaredDirs, move.From.Dir)
+			_, _ = fmt.Fprintf(m.chart, "  p%s%s -.-> r%s%s\n", partSeparator, elFromDirID, partSeparator, elID)
+		}
+	}
+
+	for _, module := range changeset.AddedModules {
+		m.writeDiffModule(declaredDirs, module, "+ ", m.moduleSourceLabel(module), "tf-added")
+	}
+
+	for _, module := range changeset.RemovedModules {
+		m.writeDiffModule(declaredDirs, module, "- ", m.moduleSourceLabel(module), "tf-removed")
+	}
+
+	for _, change := range changeset.ChangedModules {
+		m.writeDiffModule(
+			declaredDirs,
+			change.To,
+			"~ ",
+			m.moduleSourceLabel(change.From)+" → "+m.moduleSourceLabel(change.To),
+			"tf-changed",
+		)
+	}
+
+	m.prependConfig()
+
+	err := os.WriteFile(filepath.Clean(outputFile), []byte(m.chart.String()), newFilesMode)
+	if err != nil {
+		slog.Error(
+			"error writing output file",
+			slog.String("path", outputFile),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return changeset.WriteToFile(outputFile + ".json")
+}
+
+// writeDiffDir writes directory element once and returns its id.
+func (m *MermaidFlowChart) writeDiffDir(declaredDirs map[string]string, dir string) string {
+	elID, exists := declaredDirs[dir]
+	if exists {
+		return elID
+	}
+
+	elID = m.elementID(dir)
+	if dir == "." {
+		elID = "root"
+	}
+
+	declaredDirs[dir] = elID
+
+	_, _ = fmt.Fprintf(m.chart, "  p%s%s[\"%s\"]:::tf-path\n", partSeparator, elID, m.escapeLabel(dir))
+
+	return elID
+}
+
+func (m *MermaidFlowChart) writeDiffResource(
+	declaredDirs map[string]string,
+	resource *diff.SnapshotResource,
+	prefix, details, class string,
+) string {
+	elDirID := m.writeDiffDir(declaredDirs, resource.Dir)
+	elID := elDirID + elementSeparator + m.elementID(resource.Address) + partSeparator + strings.TrimPrefix(class, "tf-")
+
+	label := prefix + m.escapeLabel(resource.Address)
+	if details != "" {
+		label += "<br>" + details
+	} else {
+		label += "<br>" + m.escapeLabel(resource.DisplayName)
+	}
+
+	if m.options.IncludeFilenames {
+		label += "<br><i>(" + m.escapeLabel(resource.File) + ")</i>"
+	}
+
+	_, _ = fmt.Fprintf(
+		m.chart,
+		"  p%s%s ----> r%s%s[\"%s\"]:::%s\n",
+		partSeparator,
+		elDirID,
+		partSeparator,
+		elID,
+		label,
+		m.useClass(class),
+	)
+
+	return elID
+}
+
+func (m *MermaidFlowChart) writeDiffModule(
+	declaredDirs map[string]string,
+	module *diff.SnapshotModule,
+	prefix, details, class string,
+) {
+	elDirID := m.writeDiffDir(declaredDirs, module.Dir)
+	elID := elDirID + elementSeparator + m.elementID(module.Address) + partSeparator + strings.TrimPrefix(class, "tf-")
+
+	label := prefix + m.escapeLabel(module.Address) + "<br>" + details
+
+	if m.options.IncludeFilenames {
+		label += "<br><i>(" + m.escapeLabel(module.File) + ")</i>"
+	}
+
+	_, _ = fmt.Fprintf(
+		m.chart,
+		"  p%s%s --> m%s%s[\"%s\"]:::%s\n",
+		partSeparator,
+		elDirID,
+		partSeparator,
+		elID,
+		label,
+		m.useClass(class),
+	)
+}
+
+func (m *MermaidFlowChart) moduleSourceLabel(module *diff.SnapshotModule) string {
+	if module.Version == "" {
+		return m.escapeLabel(module.Source)
+	}
+
+	return m.escapeLabel(module.Source) + "(at)" + m.escapeLabel(module.Version)
+}
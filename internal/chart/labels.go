@@ -0,0 +1,136 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"tfsketch/internal/graph"
+	"tfsketch/internal/tfpath"
+)
+
+// nodeClass returns the class (style) of the node, the same in every format.
+func nodeClass(node *graph.Node) string {
+	switch node.Kind {
+	case graph.KindRoot:
+		return "tf-root"
+	case graph.KindDeployment:
+		return "tf-deployment"
+	case graph.KindSharedModule:
+		return "tf-ext-mod"
+	case graph.KindPath:
+		return "tf-path"
+	case graph.KindModule:
+		return "tf-int-mod"
+	case graph.KindName:
+		return "tf-name"
+	case graph.KindProviderLocks:
+		return "tf-lock"
+	case graph.KindCycle:
+		return "tf-cycle"
+	case graph.KindTruncated:
+		return "tf-truncated"
+	default:
+		return "tf-resource"
+	}
+}
+
+func pathLabel(pathNode *graph.Node) string {
+	if pathNode.RelPath == "" {
+		return "."
+	}
+
+	return pathNode.RelPath
+}
+
+// labelLines returns the label of the node as lines of plain text, for formats other than Mermaid.
+func (m *MermaidFlowChart) labelLines(node *graph.Node) []string {
+	var lines []string
+
+	switch node.Kind {
+	case graph.KindRoot:
+		return []string{node.Name}
+	case graph.KindDeployment:
+		return []string{"deployment." + node.Name}
+	case graph.KindSharedModule:
+		return []string{node.Source + "@" + node.Version, "used in " + strings.Join(node.Roots, ", ")}
+	case graph.KindPath:
+		return []string{pathLabel(node)}
+	case graph.KindName:
+		return []string{node.DisplayName}
+	case graph.KindProviderLocks:
+		lines = []string{"provider locks"}
+		for _, providerLock := range sortedProviderLocks(node.ProviderLocks) {
+			lines = append(lines, providerLock.Source+" = "+providerLock.Version)
+		}
+
+		return lines
+	case graph.KindModule, graph.KindCycle, graph.KindTruncated:
+		lines = []string{node.ModuleAddress()}
+
+		switch {
+		case node.Label != "":
+			lines = append(lines, node.Label)
+		case !strings.HasPrefix(node.Source, "."):
+			lines = append(lines, node.Source+"@"+node.Version)
+		default:
+			lines = append(lines, node.Source)
+		}
+	default:
+		lines = []string{fmt.Sprintf("%s.%s", node.Type, node.Name)}
+	}
+
+	if node.ForEach != "" {
+		lines = append(lines, "for_each = "+node.ForEach)
+	}
+
+	if node.Keys != nil {
+		lines = append(lines, keysText(node))
+	}
+
+	if node.Reason != "" {
+		lines = append(lines, node.Reason)
+	}
+
+	if m.options.IncludeFilenames {
+		lines = append(lines, "("+node.FilePath+")")
+	}
+
+	return lines
+}
+
+// keysText returns keys of 'for_each' listed in the label, eg. 'keys: "admin", "reader" and 3 more'.
+func keysText(node *graph.Node) string {
+	if len(node.Keys) == 0 {
+		return "keys: none"
+	}
+
+	text := "keys: " + strings.Join(node.Keys, ", ")
+	if node.KeysOmitted > 0 {
+		text += fmt.Sprintf(" and %d more", node.KeysOmitted)
+	}
+
+	return text
+}
+
+func sortedProviderLocks(providerLocks map[string]*tfpath.TfProviderLock) []*tfpath.TfProviderLock {
+	keys := make([]string, 0, len(providerLocks))
+	for key := range providerLocks {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	sorted := make([]*tfpath.TfProviderLock, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, providerLocks[key])
+	}
+
+	return sorted
+}
+
+// isDrawnMultiple checks if node is drawn as many elements. Resources are drawn once, with their names showing that
+// there are many instances.
+func isDrawnMultiple(node *graph.Node) bool {
+	return node.Multiple && node.Kind != graph.KindResource
+}
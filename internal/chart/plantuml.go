@@ -0,0 +1,130 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+
+	"tfsketch/internal/graph"
+	"tfsketch/internal/tfpath"
+)
+
+const plantUMLConfig = `@startuml
+left to right direction
+hide stereotype
+skinparam defaultTextAlignment left
+skinparam package<<tf-root>> {
+  BackgroundColor #ffffff
+}
+skinparam package<<tf-deployment>> {
+  BackgroundColor #f5f5f5
+}
+skinparam package<<tf-path>> {
+  BackgroundColor #c87de8
+}
+skinparam package<<tf-int-mod>> {
+  BackgroundColor #e7b6fc
+}
+skinparam rectangle<<tf-ext-mod>> {
+  BackgroundColor #7da8e8
+}
+skinparam rectangle<<tf-resource>> {
+  BorderColor #e7b6fc
+  FontColor #c87de8
+}
+skinparam rectangle<<tf-name>> {
+  BackgroundColor #eb91c7
+}
+skinparam collections<<tf-name>> {
+  BackgroundColor #eb91c7
+}
+skinparam rectangle<<tf-lock>> {
+  BackgroundColor #f5f5f5
+  BorderColor #c87de8
+}
+skinparam rectangle<<tf-cycle>> {
+  BackgroundColor #f5f5f5
+  BorderColor #c62f2f
+  BorderStyle dashed
+}
+skinparam rectangle<<tf-truncated>> {
+  BackgroundColor #f5f5f5
+  BorderColor #999999
+  BorderStyle dashed
+}
+`
+
+// GeneratePlantUML takes paths to Terraform code and generates a PlantUML component diagram, with roots, paths and
+// modules drawn as packages containing their resources. Summary is written as a JSON file next to it.
+func (m *MermaidFlowChart) GeneratePlantUML(tfPaths []*tfpath.TfPath, outputFile string) error {
+	m.Render(tfPaths)
+
+	diagram := &strings.Builder{}
+	diagram.WriteString(plantUMLConfig)
+
+	for _, root := range m.graph.Roots {
+		m.writePlantUMLNode(diagram, root, "")
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeUses) {
+		_, _ = fmt.Fprintf(diagram, "%s ..> %s\n", edge.From, edge.To)
+	}
+
+	for _, edge := range m.graph.EdgesOfKind(graph.EdgeDependsOn) {
+		_, _ = fmt.Fprintf(diagram, "%s ==> %s : %s\n", edge.From, edge.To, edge.Label)
+	}
+
+	diagram.WriteString("@enduml\n")
+
+	return m.writeOutput(outputFile, []byte(diagram.String()))
+}
+
+func (m *MermaidFlowChart) writePlantUMLNode(diagram *strings.Builder, node *graph.Node, indent string) {
+	if node.IsContainer() {
+		_, _ = fmt.Fprintf(
+			diagram,
+			"%spackage \"%s\" as %s <<%s>> {\n",
+			indent,
+			m.plantUMLLabel(m.labelLines(node)),
+			node.ID,
+			nodeClass(node),
+		)
+
+		for _, child := range node.Children {
+			m.writePlantUMLNode(diagram, child, indent+"  ")
+		}
+
+		_, _ = fmt.Fprintf(diagram, "%s}\n", indent)
+
+		return
+	}
+
+	element := "rectangle"
+	if isDrawnMultiple(node) {
+		element = "collections"
+	}
+
+	_, _ = fmt.Fprintf(
+		diagram,
+		"%s%s \"%s\" as %s <<%s>>\n",
+		indent,
+		element,
+		m.plantUMLLabel(m.labelLines(node)),
+		node.ID,
+		nodeClass(node),
+	)
+
+	// resource names are drawn next to the resource
+	for _, child := range node.Children {
+		m.writePlantUMLNode(diagram, child, indent)
+		_, _ = fmt.Fprintf(diagram, "%s%s --> %s\n", indent, node.ID, child.ID)
+	}
+}
+
+func (m *MermaidFlowChart) plantUMLLabel(lines []string) string {
+	escaped := make([]string, 0, len(lines))
+	for _, line := range lines {
+		escaped = append(escaped, strings.ReplaceAll(line, "\"", "&#34;"))
+	}
+
+	return strings.Join(escaped, "\\n")
+}
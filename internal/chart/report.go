@@ -0,0 +1,101 @@
+package chart
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"tfsketch/internal/tfpath"
+)
+
+//go:embed assets/report.html
+var reportTemplate string
+
+// reportNode is an element drawn on the diagram of the HTML report.
+type reportNode struct {
+	ID       string   `json:"id"`
+	Lines    []string `json:"lines"`
+	Class    string   `json:"class"`
+	Multiple bool     `json:"multiple,omitempty"`
+	Children []string `json:"children"`
+}
+
+// reportDiagram contains nodes of the graph with their labels, to be drawn with plain HTML.
+type reportDiagram struct {
+	Roots []string               `json:"roots"`
+	Nodes map[string]*reportNode `json:"nodes"`
+}
+
+// reportData is passed to the HTML report template.
+type reportData struct {
+	Title   string         `json:"title"`
+	Diagram *reportDiagram `json:"diagram"`
+	Summary *Summary       `json:"summary"`
+	Mermaid string         `json:"mermaid"`
+}
+
+// GenerateHTML takes paths to Terraform code and generates a single HTML file with the diagram, summary tables and
+// a search box, that does not need anything from the network. Summary is written as a JSON file next to it as well.
+func (m *MermaidFlowChart) GenerateHTML(tfPaths []*tfpath.TfPath, outputFile string) error {
+	m.Render(tfPaths)
+
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return fmt.Errorf("error parsing report template: %w", err)
+	}
+
+	paths := make([]string, 0, len(tfPaths))
+	for _, tfPath := range tfPaths {
+		if tfPath.Path == "" {
+			paths = append(paths, ".")
+		} else {
+			paths = append(paths, tfPath.Path)
+		}
+	}
+
+	title := strings.Join(paths, ", ")
+
+	report := &bytes.Buffer{}
+
+	err = tmpl.Execute(report, &reportData{
+		Title:   title,
+		Diagram: m.reportDiagram(),
+		Summary: m.summary,
+		Mermaid: m.chart.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("error executing report template: %w", err)
+	}
+
+	return m.writeOutput(outputFile, report.Bytes())
+}
+
+func (m *MermaidFlowChart) reportDiagram() *reportDiagram {
+	diagram := &reportDiagram{
+		Roots: []string{},
+		Nodes: map[string]*reportNode{},
+	}
+
+	for _, node := range m.graph.Nodes {
+		children := make([]string, 0, len(node.Children))
+		for _, child := range node.Children {
+			children = append(children, child.ID)
+		}
+
+		diagram.Nodes[node.ID] = &reportNode{
+			ID:       node.ID,
+			Lines:    m.labelLines(node),
+			Class:    nodeClass(node),
+			Multiple: isDrawnMultiple(node),
+			Children: children,
+		}
+	}
+
+	for _, root := range m.graph.Roots {
+		diagram.Roots = append(diagram.Roots, root.ID)
+	}
+
+	return diagram
+}
@@ -1,10 +1,14 @@
 package chart
 
-// Summary contains some stats gathered whilst generating a chart.
+import "tfsketch/internal/tfpath"
+
+// Summary contains some stats gathered whilst generating a chart. Provider locks are left out when there are none.
 type Summary struct {
-	Modules *map[string]int `json:"modules"`
-	Edges   *[]string       `json:"edges"`
-	Names   *[]string       `json:"names"`
+	Modules                   *map[string]int                               `json:"modules"`
+	Edges                     *[]string                                     `json:"edges"`
+	Names                     *[]string                                     `json:"names"`
+	ProviderLocks             *map[string]map[string]*tfpath.TfProviderLock `json:"providerLocks,omitempty"`
+	ProviderLockDiscrepancies *map[string]map[string][]string               `json:"providerLockDiscrepancies,omitempty"`
 }
 
 // NewSummary returns a Summary instance.
@@ -31,6 +35,8 @@ func (s *Summary) Reset() {
 	s.Modules = &modules
 	s.Edges = &edges
 	s.Names = &names
+	s.ProviderLocks = nil
+	s.ProviderLockDiscrepancies = nil
 }
 
 // AddModule increments module occurrence in the summary.
@@ -54,3 +60,27 @@ func (s *Summary) AddEdge(edge string) {
 func (s *Summary) AddName(name string) {
 	*s.Names = append(*s.Names, name)
 }
+
+// AddProviderLocks adds providers locked in a path to the summary.
+func (s *Summary) AddProviderLocks(relPath string, providerLocks map[string]*tfpath.TfProviderLock) {
+	if len(providerLocks) == 0 {
+		return
+	}
+
+	if s.ProviderLocks == nil {
+		s.ProviderLocks = &map[string]map[string]*tfpath.TfProviderLock{}
+	}
+
+	(*s.ProviderLocks)[relPath] = providerLocks
+}
+
+// SetProviderLockDiscrepancies sets providers that are locked at different versions across sibling paths.
+func (s *Summary) SetProviderLockDiscrepancies(discrepancies map[string]map[string][]string) {
+	if len(discrepancies) == 0 {
+		s.ProviderLockDiscrepancies = nil
+
+		return
+	}
+
+	s.ProviderLockDiscrepancies = &discrepancies
+}
@@ -0,0 +1,128 @@
+// Package config contains the project configuration file, with options for generating diagrams and named views.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRead      = errors.New("error reading config file")
+	ErrUnmarshal = errors.New("error unmarshaling config file")
+	ErrValidate  = errors.New("error validating config file")
+)
+
+// FileName is the name of the configuration file looked up in the scanned path.
+const FileName = ".tfsketch.yaml"
+
+// Config represents a YAML file with options for generating diagrams.
+type Config struct {
+	// Gen contains 'gen' command options, with the same names as the flags, eg. 'type-regexp'. Flags set in the
+	// command line take precedence.
+	Gen map[string]string `yaml:"gen"`
+
+	// Views contains named diagrams that are generated from the same scanned code.
+	Views map[string]*View `yaml:"views"`
+}
+
+// View is a diagram with its own resource filters and output file. Empty regular expressions and format default to
+// the 'gen' options.
+type View struct {
+	PathIncludeRegexp string `yaml:"path-include-regexp"`
+	PathExcludeRegexp string `yaml:"path-exclude-regexp"`
+	TypeRegexp        string `yaml:"type-regexp"`
+	NameRegexp        string `yaml:"name-regexp"`
+	Output            string `yaml:"output"`
+	Format            string `yaml:"format"`
+
+	// Name is the key of the view in the file.
+	Name string `yaml:"-"`
+}
+
+// ReadFromFile takes a YAML file and gets its options and views.
+func (c *Config) ReadFromFile(path string) error {
+	fileContents, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	// unknown fields are errors so that a typo does not silently drop an option
+	decoder := yaml.NewDecoder(bytes.NewReader(fileContents))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(c)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrUnmarshal, err)
+	}
+
+	return c.validate()
+}
+
+// Find returns path to the configuration file in the scanned path, or an empty string when there is none.
+func Find(terraformPath string) string {
+	path := filepath.Join(terraformPath, FileName)
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// ViewNamesSorted returns a list of view names sorted alphabetically.
+func (c *Config) ViewNamesSorted() []string {
+	namesSorted := make([]string, 0, len(c.Views))
+	for name := range c.Views {
+		namesSorted = append(namesSorted, name)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+func (c *Config) validate() error {
+	errs := []error{}
+
+	for _, name := range c.ViewNamesSorted() {
+		view := c.Views[name]
+		if view == nil {
+			errs = append(errs, fmt.Errorf("views.%s: cannot be empty", name))
+
+			continue
+		}
+
+		view.Name = name
+
+		if view.Output == "" {
+			errs = append(errs, fmt.Errorf("views.%s.output: cannot be empty", name))
+		}
+
+		for _, field := range []struct{ name, expr string }{
+			{"path-include-regexp", view.PathIncludeRegexp},
+			{"path-exclude-regexp", view.PathExcludeRegexp},
+			{"type-regexp", view.TypeRegexp},
+			{"name-regexp", view.NameRegexp},
+		} {
+			_, err := regexp.Compile(field.expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("views.%s.%s: %w", name, field.name, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrValidate, errors.Join(errs...))
+	}
+
+	return nil
+}
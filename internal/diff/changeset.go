@@ -0,0 +1,209 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const changesetFileMode = 0o600
+
+// ResourceMove is a resource that is found at a different address, directory or file in the new tree.
+type ResourceMove struct {
+	From *SnapshotResource `json:"from"`
+	To   *SnapshotResource `json:"to"`
+}
+
+// DisplayNameChange is a resource at the same address with a different display name.
+type DisplayNameChange struct {
+	Resource *SnapshotResource `json:"resource"`
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+}
+
+// ModuleChange is a module call at the same address with a different source or version.
+type ModuleChange struct {
+	From *SnapshotModule `json:"from"`
+	To   *SnapshotModule `json:"to"`
+}
+
+// Changeset contains differences between two trees.
+type Changeset struct {
+	AddedResources      []*SnapshotResource  `json:"addedResources"`
+	RemovedResources    []*SnapshotResource  `json:"removedResources"`
+	MovedResources      []*ResourceMove      `json:"movedResources"`
+	ChangedDisplayNames []*DisplayNameChange `json:"changedDisplayNames"`
+	AddedModules        []*SnapshotModule    `json:"addedModules"`
+	RemovedModules      []*SnapshotModule    `json:"removedModules"`
+	ChangedModules      []*ModuleChange      `json:"changedModules"`
+}
+
+// Compare returns changes between old and new snapshot. Resources and modules are matched by directory and address.
+// A resource that is removed from one address and added at another, with the same type and display name (or the
+// same name when display name is not known), is considered moved. So is a resource at the same address that is
+// moved to a different file.
+func Compare(oldSnapshot, newSnapshot *Snapshot) *Changeset {
+	changeset := &Changeset{
+		AddedResources:      []*SnapshotResource{},
+		RemovedResources:    []*SnapshotResource{},
+		MovedResources:      []*ResourceMove{},
+		ChangedDisplayNames: []*DisplayNameChange{},
+		AddedModules:        []*SnapshotModule{},
+		RemovedModules:      []*SnapshotModule{},
+		ChangedModules:      []*ModuleChange{},
+	}
+
+	added := []*SnapshotResource{}
+
+	for _, key := range sortedKeys(newSnapshot.Resources) {
+		newResource := newSnapshot.Resources[key]
+
+		oldResource, exists := oldSnapshot.Resources[key]
+		if !exists {
+			added = append(added, newResource)
+
+			continue
+		}
+
+		if oldResource.DisplayName != newResource.DisplayName {
+			changeset.ChangedDisplayNames = append(changeset.ChangedDisplayNames, &DisplayNameChange{
+				Resource: newResource,
+				From:     oldResource.DisplayName,
+				To:       newResource.DisplayName,
+			})
+		}
+
+		if oldResource.File != newResource.File {
+			changeset.MovedResources = append(changeset.MovedResources, &ResourceMove{
+				From: oldResource,
+				To:   newResource,
+			})
+		}
+	}
+
+	removed := []*SnapshotResource{}
+
+	for _, key := range sortedKeys(oldSnapshot.Resources) {
+		_, exists := newSnapshot.Resources[key]
+		if !exists {
+			removed = append(removed, oldSnapshot.Resources[key])
+		}
+	}
+
+	changeset.matchMovedResources(added, removed)
+	changeset.compareModules(oldSnapshot, newSnapshot)
+
+	return changeset
+}
+
+// IsEmpty checks if there are no changes.
+func (c *Changeset) IsEmpty() bool {
+	return len(c.AddedResources) == 0 && len(c.RemovedResources) == 0 && len(c.MovedResources) == 0 &&
+		len(c.ChangedDisplayNames) == 0 && len(c.AddedModules) == 0 && len(c.RemovedModules) == 0 &&
+		len(c.ChangedModules) == 0
+}
+
+// WriteToFile writes the changeset as JSON.
+func (c *Changeset) WriteToFile(path string) error {
+	changesetBytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling changeset: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Clean(path), changesetBytes, changesetFileMode)
+	if err != nil {
+		return fmt.Errorf("error writing changeset: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Changeset) matchMovedResources(added, removed []*SnapshotResource) {
+	matchedRemoved := map[*SnapshotResource]struct{}{}
+
+	for _, addedResource := range added {
+		var match *SnapshotResource
+
+		for _, removedResource := range removed {
+			_, isMatched := matchedRemoved[removedResource]
+			if isMatched || !isSameResource(removedResource, addedResource) {
+				continue
+			}
+
+			match = removedResource
+
+			break
+		}
+
+		if match == nil {
+			c.AddedResources = append(c.AddedResources, addedResource)
+
+			continue
+		}
+
+		matchedRemoved[match] = struct{}{}
+		c.MovedResources = append(c.MovedResources, &ResourceMove{From: match, To: addedResource})
+	}
+
+	for _, removedResource := range removed {
+		_, isMatched := matchedRemoved[removedResource]
+		if !isMatched {
+			c.RemovedResources = append(c.RemovedResources, removedResource)
+		}
+	}
+}
+
+func (c *Changeset) compareModules(oldSnapshot, newSnapshot *Snapshot) {
+	for _, key := range sortedKeys(newSnapshot.Modules) {
+		newModule := newSnapshot.Modules[key]
+
+		oldModule, exists := oldSnapshot.Modules[key]
+		if !exists {
+			c.AddedModules = append(c.AddedModules, newModule)
+
+			continue
+		}
+
+		if oldModule.Source != newModule.Source || oldModule.Version != newModule.Version {
+			c.ChangedModules = append(c.ChangedModules, &ModuleChange{From: oldModule, To: newModule})
+		}
+	}
+
+	for _, key := range sortedKeys(oldSnapshot.Modules) {
+		_, exists := newSnapshot.Modules[key]
+		if !exists {
+			c.RemovedModules = append(c.RemovedModules, oldSnapshot.Modules[key])
+		}
+	}
+}
+
+func isSameResource(oldResource, newResource *SnapshotResource) bool {
+	if oldResource.Type != newResource.Type {
+		return false
+	}
+
+	if isKnownDisplayName(oldResource.DisplayName) && isKnownDisplayName(newResource.DisplayName) {
+		return oldResource.DisplayName == newResource.DisplayName
+	}
+
+	return oldResource.Name == newResource.Name
+}
+
+// isKnownDisplayName checks if display name is a string that identifies a resource, rather than a placeholder or
+// a reference, eg. 'var.name', that many resources can share.
+func isKnownDisplayName(displayName string) bool {
+	return len(displayName) > 1 && displayName[0] == '"'
+}
+
+func sortedKeys[T any](entries map[string]T) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,125 @@
+// Package diff contains code comparing resources and modules of two scanned Terraform trees.
+package diff
+
+import (
+	"path/filepath"
+	"strings"
+
+	"tfsketch/internal/tfpath"
+)
+
+const snapshotModulesMaxDepth = 5
+
+// SnapshotResource is a resource found in a tree, with an address unique within the tree.
+type SnapshotResource struct {
+	// Dir is the directory, relative to the tree root, that the resource is called from.
+	Dir string `json:"dir"`
+	// Address is 'type.name' prefixed with module calls, eg. 'module.a.aws_iam_role.this'.
+	Address     string `json:"address"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	// File is relative to the tree root, unless it is outside of it (eg. in the cache).
+	File string `json:"file"`
+}
+
+// SnapshotModule is a module call found in a tree.
+type SnapshotModule struct {
+	Dir string `json:"dir"`
+	// Address is 'module.name' prefixed with parent module calls.
+	Address string `json:"address"`
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+	File    string `json:"file"`
+}
+
+// Snapshot contains resources and modules of a tree, keyed by 'dir:address'.
+type Snapshot struct {
+	Resources map[string]*SnapshotResource
+	Modules   map[string]*SnapshotModule
+
+	rootPath string
+}
+
+// NewSnapshot returns resources and modules found in the root path, its sub-directories and in modules called from
+// there, up to a depth.
+func NewSnapshot(rootTfPath *tfpath.TfPath) *Snapshot {
+	snapshot := &Snapshot{
+		Resources: map[string]*SnapshotResource{},
+		Modules:   map[string]*SnapshotModule{},
+		rootPath:  rootTfPath.Path,
+	}
+
+	snapshot.addPath(rootTfPath, ".", "", map[*tfpath.TfPath]struct{}{}, 0)
+
+	for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+		childTfPath := rootTfPath.Children[childKey]
+		if childTfPath == nil {
+			continue
+		}
+
+		snapshot.addPath(childTfPath, filepath.ToSlash(childTfPath.RelPath), "", map[*tfpath.TfPath]struct{}{}, 0)
+	}
+
+	return snapshot
+}
+
+func (s *Snapshot) addPath(
+	tfPath *tfpath.TfPath,
+	dir, addressPrefix string,
+	visited map[*tfpath.TfPath]struct{},
+	depth int,
+) {
+	if depth > snapshotModulesMaxDepth {
+		return
+	}
+
+	_, isVisited := visited[tfPath]
+	if isVisited {
+		return
+	}
+
+	visited[tfPath] = struct{}{}
+	defer delete(visited, tfPath)
+
+	for _, resourceKey := range tfPath.ResourceNamesSorted() {
+		resource := tfPath.Resources[resourceKey]
+		address := addressPrefix + resource.Type + "." + resource.Name
+
+		s.Resources[dir+":"+address] = &SnapshotResource{
+			Dir:         dir,
+			Address:     address,
+			Type:        resource.Type,
+			Name:        resource.Name,
+			DisplayName: resource.FieldName,
+			File:        s.relativeFile(resource.FilePath),
+		}
+	}
+
+	for _, moduleKey := range tfPath.ModuleNamesSorted() {
+		module := tfPath.Modules[moduleKey]
+		address := addressPrefix + "module." + module.Name
+
+		s.Modules[dir+":"+address] = &SnapshotModule{
+			Dir:     dir,
+			Address: address,
+			Source:  module.FieldSource,
+			Version: module.FieldVersion,
+			File:    s.relativeFile(module.FilePath),
+		}
+
+		if module.TfPath != nil {
+			s.addPath(module.TfPath, dir, address+".", visited, depth+1)
+		}
+	}
+}
+
+// relativeFile returns file relative to the root path, so that two trees in different directories can be compared.
+func (s *Snapshot) relativeFile(file string) string {
+	relFile, err := filepath.Rel(s.rootPath, file)
+	if err != nil || relFile == ".." || strings.HasPrefix(relFile, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(file)
+	}
+
+	return filepath.ToSlash(relFile)
+}
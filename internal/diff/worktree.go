@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFindingRepository = errors.New("error finding git repository")
+	ErrAddingWorktree    = errors.New("error adding git worktree")
+)
+
+const gitWorktreeTimeout = 120 * time.Second
+
+// Worktree is a git ref of a repository checked out into a temporary directory.
+type Worktree struct {
+	// Path is the scanned path in the checked out worktree.
+	Path string
+
+	repositoryPath string
+	dir            string
+}
+
+// NewWorktree checks out ref of the repository that path is in, into a temporary directory, and returns a Worktree
+// with the same path in it.
+func NewWorktree(path, ref string) (*Worktree, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFindingRepository, err)
+	}
+
+	repositoryPath, err := runGit(absPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFindingRepository, err)
+	}
+
+	// symlinks in path (eg. temporary directory on macOS) would make the relative path wrong
+	evaluatedPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFindingRepository, err)
+	}
+
+	relPath, err := filepath.Rel(repositoryPath, evaluatedPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFindingRepository, err)
+	}
+
+	dir, err := os.MkdirTemp("", "tfsketch-diff-")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAddingWorktree, err)
+	}
+
+	_, err = runGit(repositoryPath, "worktree", "add", "--detach", dir, ref)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, fmt.Errorf("%w: %s: %w", ErrAddingWorktree, ref, err)
+	}
+
+	slog.Info(fmt.Sprintf("🌿 Checked out %s of 📁%s into 📁%s", ref, repositoryPath, dir))
+
+	return &Worktree{
+		Path:           filepath.Join(dir, relPath),
+		repositoryPath: repositoryPath,
+		dir:            dir,
+	}, nil
+}
+
+// Remove removes the worktree and its directory.
+func (w *Worktree) Remove() {
+	_, err := runGit(w.repositoryPath, "worktree", "remove", "--force", w.dir)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("❗ Error removing git worktree 📁%s: %s", w.dir, err.Error()))
+	}
+
+	_ = os.RemoveAll(w.dir)
+}
+
+func runGit(dir string, cmdArgs ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitWorktreeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("'git %s' failed: %w: %s", strings.Join(cmdArgs, " "), err, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
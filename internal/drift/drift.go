@@ -0,0 +1,191 @@
+// Package drift contains the module version drift report, showing which versions of every external module are used
+// and where.
+package drift
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"tfsketch/internal/lookup"
+)
+
+// Report contains external module sources used in the scanned paths, sorted by source.
+type Report struct {
+	Sources []*Source `json:"sources"`
+}
+
+// Source is an external module source with all the versions it is used at.
+type Source struct {
+	Source   string     `json:"source"`
+	Versions []*Version `json:"versions"`
+	// Drift is set when the source is used at more than one version.
+	Drift bool `json:"drift"`
+	// Latest is the latest version found in the cache or the registry, and Outdated is set when any of the used
+	// versions is older than it.
+	Latest   string `json:"latest,omitempty"`
+	Outdated bool   `json:"outdated,omitempty"`
+}
+
+// Version is a version of a source, with chains of module calls using it, eg. 'network: module.vpc'. Outdated is
+// set when it is an exact version older than the latest one.
+type Version struct {
+	Version  string   `json:"version"`
+	Paths    []string `json:"paths"`
+	Outdated bool     `json:"outdated,omitempty"`
+}
+
+// NewReport takes module calls found with lookup.Finder and returns them grouped by source and version.
+func NewReport(matches []*lookup.Match, withRoot bool) *Report {
+	sources := map[string]*Source{}
+
+	for _, match := range matches {
+		if match.Kind != lookup.KindModule {
+			continue
+		}
+
+		source, exists := sources[match.Source]
+		if !exists {
+			source = &Source{Source: match.Source, Versions: []*Version{}}
+			sources[match.Source] = source
+		}
+
+		var version *Version
+
+		for _, sourceVersion := range source.Versions {
+			if sourceVersion.Version == match.Version {
+				version = sourceVersion
+			}
+		}
+
+		if version == nil {
+			version = &Version{Version: match.Version, Paths: []string{}}
+			source.Versions = append(source.Versions, version)
+		}
+
+		path := matchPath(match, withRoot)
+		if !slices.Contains(version.Paths, path) {
+			version.Paths = append(version.Paths, path)
+		}
+	}
+
+	report := &Report{Sources: make([]*Source, 0, len(sources))}
+
+	for _, source := range sources {
+		sort.Slice(source.Versions, func(i, j int) bool {
+			return compareVersions(source.Versions[i].Version, source.Versions[j].Version) < 0
+		})
+
+		source.Drift = len(source.Versions) > 1
+		report.Sources = append(report.Sources, source)
+	}
+
+	sort.Slice(report.Sources, func(i, j int) bool {
+		return report.Sources[i].Source < report.Sources[j].Source
+	})
+
+	return report
+}
+
+// SetLatest takes a function returning the latest available version of a source, or an empty string when it is not
+// known, and marks sources that are used at older versions.
+func (r *Report) SetLatest(latestVersion func(source string) string) {
+	for _, source := range r.Sources {
+		source.Latest = latestVersion(source.Source)
+		if source.Latest == "" {
+			continue
+		}
+
+		for _, version := range source.Versions {
+			if IsExact(version.Version) && compareVersions(version.Version, source.Latest) < 0 {
+				version.Outdated = true
+				source.Outdated = true
+			}
+		}
+	}
+}
+
+// DriftCount returns number of sources that are used at more than one version.
+func (r *Report) DriftCount() int {
+	count := 0
+
+	for _, source := range r.Sources {
+		if source.Drift {
+			count++
+		}
+	}
+
+	return count
+}
+
+// IsExact checks if version is an exact semantic version, eg. '5.1.0' or 'v5.1.0', rather than a constraint.
+func IsExact(version string) bool {
+	return semver.IsValid(canonicalVersion(version))
+}
+
+// Latest returns the latest of exact versions, or an empty string when there are none. Pre-releases, eg.
+// '6.0.0-beta1', are skipped unless all the versions are pre-releases, as they are not what modules are updated to.
+func Latest(versions []string) string {
+	latest, latestPrerelease := "", ""
+
+	for _, version := range versions {
+		if !IsExact(version) {
+			continue
+		}
+
+		if semver.Prerelease(canonicalVersion(version)) != "" {
+			if latestPrerelease == "" || compareVersions(version, latestPrerelease) > 0 {
+				latestPrerelease = version
+			}
+
+			continue
+		}
+
+		if latest == "" || compareVersions(version, latest) > 0 {
+			latest = version
+		}
+	}
+
+	if latest == "" {
+		return latestPrerelease
+	}
+
+	return latest
+}
+
+// compareVersions compares exact versions semantically, and others, eg. constraints, as text after exact ones.
+func compareVersions(a, b string) int {
+	aExact, bExact := IsExact(a), IsExact(b)
+
+	switch {
+	case aExact && bExact:
+		return semver.Compare(canonicalVersion(a), canonicalVersion(b))
+	case aExact:
+		return -1
+	case bExact:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func canonicalVersion(version string) string {
+	return "v" + strings.TrimPrefix(version, "v")
+}
+
+func matchPath(match *lookup.Match, withRoot bool) string {
+	location := match.RelPath
+	if withRoot {
+		location = match.Root + ":" + location
+	}
+
+	addresses := make([]string, 0, len(match.Calls)+1)
+	for _, call := range match.Calls {
+		addresses = append(addresses, call.Address())
+	}
+
+	addresses = append(addresses, match.Address)
+
+	return location + ": " + strings.Join(addresses, " > ")
+}
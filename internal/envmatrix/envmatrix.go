@@ -0,0 +1,183 @@
+// Package envmatrix contains the environment matrix, showing which resources and module calls exist in every
+// environment defined by a '.tfvars' file, and with which names.
+package envmatrix
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	// KindModule is a row of a module call.
+	KindModule = "module"
+	// KindResource is a row of a resource.
+	KindResource = "resource"
+)
+
+const (
+	// StatusPresent is a status of an instance that exists in the environment.
+	StatusPresent = "present"
+	// StatusAbsent is a status of a resource or a module call that has no instances in the environment.
+	StatusAbsent = "absent"
+	// StatusUnknown is a status of a resource or a module call whose 'for_each' or 'count' cannot be evaluated.
+	StatusUnknown = "unknown"
+)
+
+// maxInstances is the number of instances listed for a resource or a module call in an environment, so that a huge
+// 'count' is not listed. A resource or a module call with more of them is unknown.
+const maxInstances = 1000
+
+// Environment is a '.tfvars' file of a path, named after the file, eg. 'dev' for 'envs/dev.tfvars'.
+type Environment struct {
+	Name string
+	// Root is the name of the scanned path the environment is in
+	Root   string
+	TfPath *tfpath.TfPath
+	Values map[string]cty.Value
+}
+
+// Matrix contains names of all the environments, sorted, and resources and module calls of paths with environments.
+type Matrix struct {
+	Environments []string `json:"environments"`
+	Rows         []*Row   `json:"rows"`
+}
+
+// Row is a resource or a module call of a path, with its instances in every environment of the path.
+type Row struct {
+	Root    string `json:"root"`
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Address string `json:"address"`
+	// Cells are keyed by environment name, and there is no cell for an environment the path does not have
+	Cells map[string]*Cell `json:"cells"`
+}
+
+// Cell contains instances of a resource or a module call in an environment.
+type Cell struct {
+	Status    string      `json:"status"`
+	Instances []*Instance `json:"instances,omitempty"`
+	// Reason is why 'for_each' or 'count' cannot be evaluated when status is unknown
+	Reason string `json:"reason,omitempty"`
+}
+
+// Instance is an instance of a resource or a module call, with the name it has in the environment when it is known.
+type Instance struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+}
+
+// New evaluates resources and module calls of paths in every environment and returns them as a matrix.
+func New(environments []*Environment) *Matrix {
+	matrix := &Matrix{Environments: []string{}, Rows: []*Row{}}
+	rows := map[string]*Row{}
+
+	addRow := func(environment *Environment, kind, address string, cell *Cell) {
+		relPath := environment.TfPath.RelPath
+		if relPath == "" {
+			relPath = "."
+		}
+
+		rowKey := environment.Root + ":" + relPath + ":" + address
+
+		row, exists := rows[rowKey]
+		if !exists {
+			row = &Row{Root: environment.Root, Path: relPath, Kind: kind, Address: address, Cells: map[string]*Cell{}}
+			rows[rowKey] = row
+			matrix.Rows = append(matrix.Rows, row)
+		}
+
+		row.Cells[environment.Name] = cell
+	}
+
+	for _, environment := range environments {
+		if !slices.Contains(matrix.Environments, environment.Name) {
+			matrix.Environments = append(matrix.Environments, environment.Name)
+		}
+
+		evalContext := environment.TfPath.EvalContext(environment.Values, environment.Name)
+
+		for _, resourceKey := range environment.TfPath.ResourceNamesSorted() {
+			resource := environment.TfPath.Resources[resourceKey]
+			instances, instancesNum, err := resource.Instances(evalContext, maxInstances)
+			addRow(environment, KindResource, resourceKey, newCell(resourceKey, instances, instancesNum, err))
+		}
+
+		for _, moduleKey := range environment.TfPath.ModuleNamesSorted() {
+			module := environment.TfPath.Modules[moduleKey]
+
+			address := "module." + module.Name
+			if module.Component {
+				address = "component." + module.Name
+			}
+
+			instances, instancesNum, err := module.Instances(evalContext, maxInstances)
+			addRow(environment, KindModule, address, newCell(address, instances, instancesNum, err))
+		}
+	}
+
+	sort.Strings(matrix.Environments)
+
+	// resources first, then module calls, as they are listed in a path
+	sort.SliceStable(matrix.Rows, func(i, j int) bool {
+		if matrix.Rows[i].Root != matrix.Rows[j].Root {
+			return matrix.Rows[i].Root < matrix.Rows[j].Root
+		}
+
+		if matrix.Rows[i].Path != matrix.Rows[j].Path {
+			return matrix.Rows[i].Path < matrix.Rows[j].Path
+		}
+
+		return matrix.Rows[i].Kind > matrix.Rows[j].Kind
+	})
+
+	return matrix
+}
+
+// UnknownCount returns number of cells whose instances could not be evaluated.
+func (m *Matrix) UnknownCount() int {
+	count := 0
+
+	for _, row := range m.Rows {
+		for _, cell := range row.Cells {
+			if cell.Status == StatusUnknown {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func newCell(address string, instances []*tfpath.TfInstance, instancesNum int, err error) *Cell {
+	if err != nil {
+		reason := err.Error()
+		if errors.Is(err, tfpath.ErrUnknownValue) {
+			reason = tfpath.ErrUnknownValue.Error()
+		}
+
+		return &Cell{Status: StatusUnknown, Reason: reason}
+	}
+
+	if instancesNum > len(instances) {
+		return &Cell{
+			Status: StatusUnknown,
+			Reason: fmt.Sprintf("too many instances: %d, more than %d", instancesNum, maxInstances),
+		}
+	}
+
+	if len(instances) == 0 {
+		return &Cell{Status: StatusAbsent}
+	}
+
+	cell := &Cell{Status: StatusPresent, Instances: make([]*Instance, 0, len(instances))}
+	for _, instance := range instances {
+		cell.Instances = append(cell.Instances, &Instance{Address: address + instance.Key, Name: instance.Name})
+	}
+
+	return cell
+}
@@ -0,0 +1,84 @@
+package findings
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type junitTestSuites struct {
+	XMLName    xml.Name          `xml:"testsuites"`
+	Name       string            `xml:"name,attr"`
+	Tests      int               `xml:"tests,attr"`
+	Failures   int               `xml:"failures,attr"`
+	TestSuites []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit returns findings as JUnit XML with a test suite named suiteName. Each finding is a failed test case, and when
+// there are no findings a single passing test case is added so that the suite is not empty.
+func JUnit(findings []*Finding, suiteName string) ([]byte, error) {
+	testSuite := &junitTestSuite{
+		Name:      suiteName,
+		TestCases: []*junitTestCase{},
+	}
+
+	for _, finding := range findings {
+		location := finding.File
+		if finding.LineStart > 0 {
+			location = fmt.Sprintf("%s:%d", finding.File, finding.LineStart)
+		}
+
+		testSuite.TestCases = append(testSuite.TestCases, &junitTestCase{
+			Name:      fmt.Sprintf("%s %s", finding.Rule, location),
+			ClassName: finding.File,
+			Failure: &junitFailure{
+				Message: finding.Message,
+				Type:    finding.Rule,
+				Text:    location + ": " + finding.Message,
+			},
+		})
+	}
+
+	testSuite.Tests = len(testSuite.TestCases)
+	testSuite.Failures = len(findings)
+
+	if len(findings) == 0 {
+		testSuite.TestCases = append(testSuite.TestCases, &junitTestCase{
+			Name:      "no findings",
+			ClassName: suiteName,
+		})
+		testSuite.Tests = 1
+	}
+
+	testSuites := &junitTestSuites{
+		Name:       toolName,
+		Tests:      testSuite.Tests,
+		Failures:   testSuite.Failures,
+		TestSuites: []*junitTestSuite{testSuite},
+	}
+
+	suitesBytes, err := xml.MarshalIndent(testSuites, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling junit: %w", err)
+	}
+
+	return append([]byte(xml.Header), append(suitesBytes, '\n')...), nil
+}
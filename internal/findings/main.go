@@ -0,0 +1,143 @@
+// Package findings contains problems found in Terraform code, and emitters that write them in formats understood by
+// code review tools.
+package findings
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tfsketch/internal/policy"
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	// LevelError is a finding that should fail the build.
+	LevelError = "error"
+	// LevelWarning is a finding that should be looked at.
+	LevelWarning = "warning"
+
+	toolName = "tfsketch"
+
+	// RuleUnresolvedModule is reported when a module call could not be resolved.
+	RuleUnresolvedModule = "unresolved-module"
+)
+
+// Finding represents a single problem at a place in Terraform code.
+type Finding struct {
+	Rule    string
+	Level   string
+	Message string
+	// File is relative to the scanned root path, with '/' separators, or absolute when it is outside of it.
+	File      string
+	LineStart int
+	LineEnd   int
+}
+
+// RuleDescriptions contains short descriptions of the rules, shown by code review tools.
+var RuleDescriptions = map[string]string{
+	RuleUnresolvedModule:             "Module call could not be resolved",
+	policy.RuleResourceName:          "Resource display attribute does not match the naming pattern",
+	policy.RuleForbiddenModuleSource: "Module source is forbidden",
+	policy.RuleMaxModuleNesting:      "Module calls are nested too deep",
+	policy.RuleIteration:             "Resource is repeated with 'count' or 'for_each' against the rules",
+}
+
+// FromViolations returns findings for rule violations found by policy checker.
+func FromViolations(violations []*policy.Violation, rootPath string) []*Finding {
+	findings := make([]*Finding, 0, len(violations))
+
+	for _, violation := range violations {
+		findings = append(findings, &Finding{
+			Rule:      violation.Rule,
+			Level:     LevelError,
+			Message:   violation.Address + ": " + violation.Message,
+			File:      relativeFile(violation.File, rootPath),
+			LineStart: violation.LineStart,
+			LineEnd:   violation.LineEnd,
+		})
+	}
+
+	return findings
+}
+
+// FromResolutionReport returns findings for module calls that could not be resolved. Files are relative to the path
+// of the root the call is in, taken from rootPaths by root name, and files of calls in external modules are relative
+// to the root they are found in, if any.
+func FromResolutionReport(report *tfpath.ResolutionReport, rootPaths map[string]string) []*Finding {
+	unresolvedCalls := report.UnresolvedCalls()
+	findings := make([]*Finding, 0, len(unresolvedCalls))
+
+	for _, call := range unresolvedCalls {
+		source := call.Source
+		if call.Version != "" {
+			source += "@" + call.Version
+		}
+
+		findings = append(findings, &Finding{
+			Rule:      RuleUnresolvedModule,
+			Level:     LevelError,
+			Message:   fmt.Sprintf("module.%s (%s): %s", call.Module, source, call.Reason),
+			File:      callFile(call, rootPaths),
+			LineStart: call.LineStart,
+			LineEnd:   call.LineEnd,
+		})
+	}
+
+	return findings
+}
+
+// callFile returns file of the module call relative to its root, or to the first root, by name, that it is in when
+// the call is in an external module. Files outside of the roots (eg. in the cache) are made absolute.
+func callFile(call *tfpath.ModuleCallResolution, rootPaths map[string]string) string {
+	rootPath, isInRoot := rootPaths[call.Path]
+	if isInRoot {
+		return relativeFile(call.File, rootPath)
+	}
+
+	rootNames := make([]string, 0, len(rootPaths))
+	for rootName := range rootPaths {
+		rootNames = append(rootNames, rootName)
+	}
+
+	sort.Strings(rootNames)
+
+	for _, rootName := range rootNames {
+		relFile, isInPath := fileInPath(call.File, rootPaths[rootName])
+		if isInPath {
+			return relFile
+		}
+	}
+
+	return absoluteFile(call.File)
+}
+
+// relativeFile returns file relative to the root path. Files outside of it (eg. in the cache) are made absolute.
+func relativeFile(file, rootPath string) string {
+	relFile, isInPath := fileInPath(file, rootPath)
+	if !isInPath {
+		return absoluteFile(file)
+	}
+
+	return relFile
+}
+
+// fileInPath returns file relative to the path, and whether it is in the path.
+func fileInPath(file, path string) (string, bool) {
+	relFile, err := filepath.Rel(path, file)
+	if err != nil || relFile == ".." || strings.HasPrefix(relFile, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(relFile), true
+}
+
+func absoluteFile(file string) string {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+
+	return filepath.ToSlash(absFile)
+}
@@ -0,0 +1,165 @@
+package findings
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+)
+
+const (
+	sarifVersion    = "2.1.0"
+	sarifSchema     = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRootID  = "%SRCROOT%"
+	sarifFileScheme = "file"
+)
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    *sarifTool     `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver *sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string       `json:"name"`
+	Rules []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string           `json:"ruleId"`
+	RuleIndex int              `json:"ruleIndex"`
+	Level     string           `json:"level"`
+	Message   *sarifMessage    `json:"message"`
+	Locations []*sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion           `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// SARIF returns findings as a SARIF 2.1.0 log with file paths relative to the scanned root, and files outside of
+// it as absolute 'file://' URIs.
+func SARIF(findings []*Finding) ([]byte, error) {
+	ruleIDs := []string{}
+	ruleIndexes := map[string]int{}
+
+	for _, finding := range findings {
+		_, exists := ruleIndexes[finding.Rule]
+		if !exists {
+			ruleIndexes[finding.Rule] = 0
+			ruleIDs = append(ruleIDs, finding.Rule)
+		}
+	}
+
+	sort.Strings(ruleIDs)
+
+	rules := make([]*sarifRule, 0, len(ruleIDs))
+
+	for i, ruleID := range ruleIDs {
+		ruleIndexes[ruleID] = i
+
+		description, exists := RuleDescriptions[ruleID]
+		if !exists {
+			description = ruleID
+		}
+
+		rules = append(rules, &sarifRule{
+			ID:               ruleID,
+			ShortDescription: &sarifMessage{Text: description},
+		})
+	}
+
+	results := make([]*sarifResult, 0, len(findings))
+
+	for _, finding := range findings {
+		physicalLocation := &sarifPhysicalLocation{
+			ArtifactLocation: sarifFileLocation(finding.File),
+		}
+
+		if finding.LineStart > 0 {
+			physicalLocation.Region = &sarifRegion{StartLine: finding.LineStart}
+
+			if finding.LineEnd >= finding.LineStart {
+				physicalLocation.Region.EndLine = finding.LineEnd
+			}
+		}
+
+		results = append(results, &sarifResult{
+			RuleID:    finding.Rule,
+			RuleIndex: ruleIndexes[finding.Rule],
+			Level:     finding.Level,
+			Message:   &sarifMessage{Text: finding.Message},
+			Locations: []*sarifLocation{{PhysicalLocation: physicalLocation}},
+		})
+	}
+
+	log := &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []*sarifRun{
+			{
+				Tool: &sarifTool{
+					Driver: &sarifDriver{Name: toolName, Rules: rules},
+				},
+				Results: results,
+			},
+		},
+	}
+
+	logBytes, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling sarif: %w", err)
+	}
+
+	return append(logBytes, '\n'), nil
+}
+
+// sarifFileLocation returns location of a file relative to the scanned root, or an absolute 'file://' URI without
+// a base when the file is outside of the root.
+func sarifFileLocation(file string) *sarifArtifactLocation {
+	// files have '/' separators, so an absolute Windows path looks like 'C:/...' and needs a leading '/' in the URI
+	windowsAbs := len(file) > 2 && file[1] == ':' && file[2] == '/'
+	if windowsAbs {
+		file = "/" + file
+	}
+
+	if !path.IsAbs(file) {
+		return &sarifArtifactLocation{URI: (&url.URL{Path: file}).String(), URIBaseID: sarifSrcRootID}
+	}
+
+	return &sarifArtifactLocation{URI: (&url.URL{Scheme: sarifFileScheme, Path: file}).String()}
+}
@@ -0,0 +1,673 @@
+package graph
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"tfsketch/internal/layout"
+	"tfsketch/internal/tfpath"
+)
+
+// DefaultMaxModulesDepth is the default depth of module calls drawn from a path.
+const DefaultMaxModulesDepth = 5
+
+const (
+	// ForEachRaw draws 'for_each' as it is in the code.
+	ForEachRaw = "raw"
+	// ForEachKeys lists keys of a statically known 'for_each' in the label.
+	ForEachKeys = "keys"
+	// ForEachInstances draws an element for every instance of a statically known 'for_each', and lists the keys
+	// when there are more instances than the limit.
+	ForEachInstances = "instances"
+	// DefaultMaxInstances is the default number of instances drawn, or keys listed, for a single 'for_each'.
+	DefaultMaxInstances = 10
+)
+
+// forEachWorkspace is the workspace 'for_each' is evaluated in, as only defaults of variables are known.
+const forEachWorkspace = "default"
+
+var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9 ]+`)
+
+// Options decide what is added to the graph. Zero values of MaxModulesDepth, Layout, ForEachMode and MaxInstances
+// are replaced with the defaults.
+type Options struct {
+	// OnlyRoot skips sub-directories
+	OnlyRoot bool
+	// Module adds module sub-directories, eg. 'modules'
+	Module bool
+	// ProviderLocks adds provider locks as separate elements
+	ProviderLocks bool
+	// MaxModulesDepth is the depth of module calls added, deeper ones are added as truncated
+	MaxModulesDepth int
+	// Layout classifies sub-directories and limits their depth
+	Layout *layout.Layout
+	// ForEachMode is one of ForEachRaw, ForEachKeys or ForEachInstances, deciding how 'for_each' known from literals
+	// and defaults of variables is drawn
+	ForEachMode string
+	// MaxInstances is the number of instances drawn, or keys listed, for a single 'for_each'
+	MaxInstances int
+}
+
+// withDefaults returns the options with zero values replaced with the defaults.
+func (o Options) withDefaults() Options {
+	if o.MaxModulesDepth == 0 {
+		o.MaxModulesDepth = DefaultMaxModulesDepth
+	}
+
+	if o.Layout == nil {
+		o.Layout = layout.Default()
+	}
+
+	if o.ForEachMode == "" {
+		o.ForEachMode = ForEachRaw
+	}
+
+	if o.MaxInstances == 0 {
+		o.MaxInstances = DefaultMaxInstances
+	}
+
+	return o
+}
+
+// Builder builds a Graph from linked paths.
+type Builder struct {
+	options Options
+}
+
+// NewBuilder returns a Builder instance adding paths, modules and resources as set in options.
+func NewBuilder(options Options) *Builder {
+	return &Builder{
+		options: options.withDefaults(),
+	}
+}
+
+// Build takes paths to Terraform code and returns the Graph with them and their sub-directories, and the
+// modules they call up to a depth. When there is more than one path, each is a group named as its container path
+// (TfPath.TraverseName), and external modules called from more than one of them are added and linked.
+func (b *Builder) Build(rootTfPaths []*tfpath.TfPath) *Graph {
+	graph := NewGraph()
+
+	if len(rootTfPaths) == 1 {
+		b.addRootPaths(graph, nil, rootTfPaths[0], "")
+		b.addDependencies(graph, rootTfPaths)
+
+		return graph
+	}
+
+	for _, rootTfPath := range rootTfPaths {
+		rootNode := graph.addRoot(&Node{
+			ID:   "g" + partSeparator + idPart(rootTfPath.TraverseName),
+			Kind: KindRoot,
+			Name: rootTfPath.TraverseName,
+			Path: rootTfPath.Path,
+		})
+
+		b.addRootPaths(graph, rootNode, rootTfPath, idPart(rootTfPath.TraverseName)+idSeparator)
+	}
+
+	b.addSharedModules(graph)
+	b.addDependencies(graph, rootTfPaths)
+
+	return graph
+}
+
+// addRootPaths adds the root path and its sub-directories to the graph, or to the root group when it is set.
+func (b *Builder) addRootPaths(graph *Graph, rootNode *Node, rootTfPath *tfpath.TfPath, idPrefix string) {
+	b.addPath(graph, rootNode, rootTfPath, idPrefix)
+
+	if b.options.OnlyRoot {
+		return
+	}
+
+	for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+		childTfPath := rootTfPath.Children[childKey]
+		if childTfPath == nil {
+			continue
+		}
+
+		// not module
+		_, isModule := rootTfPath.IsChildModule[childKey]
+		if isModule {
+			continue
+		}
+
+		if !b.isPathDrawn(childTfPath) {
+			continue
+		}
+
+		b.addPath(graph, rootNode, childTfPath, idPrefix)
+	}
+}
+
+// isPathDrawn checks if sub-directory is drawn: module directories themselves are not, their sub-directories are
+// drawn only when module is set, and other sub-directories are drawn down to the layout depth. Directories deeper
+// than the first level are drawn only when they contain code, so that directories grouping others are skipped.
+func (b *Builder) isPathDrawn(childTfPath *tfpath.TfPath) bool {
+	dirNames := strings.Split(childTfPath.RelPath, "/")
+	moduleDirIndex := slices.IndexFunc(dirNames, b.options.Layout.ModuleDir.MatchString)
+
+	switch {
+	case moduleDirIndex == 0 && len(dirNames) == 1:
+		return false
+	case moduleDirIndex == 0:
+		return b.options.Module
+	case moduleDirIndex > 0:
+		return false
+	case len(dirNames) > b.options.Layout.MaxPathDepth:
+		return false
+	case len(dirNames) > 1:
+		return len(childTfPath.Resources) > 0 || len(childTfPath.Modules) > 0
+	default:
+		return true
+	}
+}
+
+// addPath adds the path to the graph, or to the root group when it is set. A path with Terraform Stacks deployments
+// is added once in every deployment, which is a group.
+func (b *Builder) addPath(graph *Graph, rootNode *Node, tfPath *tfpath.TfPath, idPrefix string) {
+	rootName := ""
+	if rootNode != nil {
+		rootName = rootNode.Name
+	}
+
+	if len(tfPath.Deployments) == 0 {
+		b.addPathTo(graph, rootNode, rootName, tfPath, idPrefix)
+
+		return
+	}
+
+	for _, deploymentName := range tfPath.DeploymentNamesSorted() {
+		deployment := tfPath.Deployments[deploymentName]
+
+		deploymentNode := &Node{
+			ID:       "d" + partSeparator + idPrefix + pathIDPart(tfPath) + idSeparator + idPart(deploymentName),
+			Kind:     KindDeployment,
+			Name:     deploymentName,
+			Path:     tfPath.Path,
+			Root:     rootName,
+			FilePath: deployment.FilePath,
+		}
+
+		if rootNode != nil {
+			deploymentNode = graph.addNode(rootNode, deploymentNode, EdgeContains)
+		} else {
+			deploymentNode = graph.addRoot(deploymentNode)
+		}
+
+		b.addPathTo(graph, deploymentNode, rootName, tfPath, idPrefix+idPart(deploymentName)+idSeparator)
+	}
+}
+
+// addPathTo adds the path with its resources, provider locks and modules to the parent group, or to the graph when
+// it is nil.
+func (b *Builder) addPathTo(graph *Graph, parent *Node, rootName string, tfPath *tfpath.TfPath, idPrefix string) {
+	pathID := idPrefix + pathIDPart(tfPath)
+
+	pathNode := &Node{
+		ID:            "p" + partSeparator + pathID,
+		Kind:          KindPath,
+		RelPath:       tfPath.RelPath,
+		Path:          tfPath.Path,
+		Root:          rootName,
+		ProviderLocks: tfPath.ProviderLocks,
+	}
+
+	if parent != nil {
+		pathNode = graph.addNode(parent, pathNode, EdgeContains)
+	} else {
+		pathNode = graph.addRoot(pathNode)
+	}
+
+	evalContext := b.pathEvalContext(tfPath)
+
+	b.addResources(graph, pathNode, tfPath, pathID, false, evalContext)
+
+	if b.options.ProviderLocks && len(tfPath.ProviderLocks) > 0 {
+		graph.addNode(pathNode, &Node{
+			ID:            "l" + partSeparator + pathID + partSeparator + "l",
+			Kind:          KindProviderLocks,
+			ProviderLocks: tfPath.ProviderLocks,
+		}, EdgeContains)
+	}
+
+	b.addModules(graph, pathNode, tfPath, pathID, "", false, 1, []*tfpath.TfPath{tfPath}, evalContext)
+}
+
+func (b *Builder) addResources(
+	graph *Graph,
+	parent *Node,
+	tfPath *tfpath.TfPath,
+	parentID string,
+	forceMultiple bool,
+	evalContext *hcl.EvalContext,
+) {
+	for _, resourceKey := range tfPath.ResourceNamesSorted() {
+		resource := tfPath.Resources[resourceKey]
+		if resource == nil {
+			continue
+		}
+
+		resourceID := parentID + idSeparator + idPart(resource.Type+partSeparator+resource.Name)
+		forEach := b.forEachText(resource.FieldForEach, resource.ForEachSource)
+		isMultiple := forEach != "" || forceMultiple
+
+		resourceNode := &Node{
+			ID:          "r" + partSeparator + resourceID,
+			Kind:        KindResource,
+			Multiple:    isMultiple,
+			Type:        resource.Type,
+			Name:        resource.Name,
+			DisplayName: resource.FieldName,
+			ForEach:     forEach,
+			Count:       resource.FieldCount,
+			FilePath:    resource.FilePath,
+		}
+
+		instances, instancesNum := b.forEachInstances(evalContext, forEach, resource.Instances)
+		if !b.isDrawnPerInstance(instancesNum) {
+			b.setKeys(resourceNode, instances, instancesNum)
+			b.addResource(graph, parent, resourceNode)
+
+			continue
+		}
+
+		for i, instance := range instances {
+			instanceNode := *resourceNode
+			instanceNode.ID += partSeparator + instanceIDPart(i)
+			instanceNode.Multiple = forceMultiple
+			instanceNode.Name += instance.Key
+			instanceNode.ForEach = ""
+
+			if instance.Name != "" {
+				instanceNode.DisplayName = instance.Name
+			}
+
+			b.addResource(graph, parent, &instanceNode)
+		}
+	}
+}
+
+// addResource adds the resource and its name to the parent.
+func (b *Builder) addResource(graph *Graph, parent *Node, resourceNode *Node) {
+	resourceNode = graph.addNode(parent, resourceNode, EdgeContains)
+
+	graph.addNode(resourceNode, &Node{
+		ID:          "n" + partSeparator + strings.TrimPrefix(resourceNode.ID, "r"+partSeparator) + partSeparator + "n",
+		Kind:        KindName,
+		Multiple:    resourceNode.Multiple,
+		Name:        resourceNode.Name,
+		DisplayName: resourceNode.DisplayName,
+	}, EdgeNamed)
+}
+
+// forEachText returns 'for_each' as it is drawn: lists and references only, as in the code, unless it is evaluated,
+// when any expression is.
+func (b *Builder) forEachText(fieldForEach, forEachSource string) string {
+	if b.options.ForEachMode == ForEachRaw {
+		return fieldForEach
+	}
+
+	// literal maps span many lines, which cannot be in labels
+	return strings.Join(strings.Fields(forEachSource), " ")
+}
+
+// pathEvalContext returns the context 'for_each' of a drawn path is evaluated in, with defaults of its variables, or
+// nil when 'for_each' is drawn as it is.
+func (b *Builder) pathEvalContext(tfPath *tfpath.TfPath) *hcl.EvalContext {
+	if b.options.ForEachMode == ForEachRaw {
+		return nil
+	}
+
+	return tfPath.EvalContext(nil, forEachWorkspace)
+}
+
+// moduleEvalContext returns the context 'for_each' in the called module is evaluated in, with values of the variables
+// set in the call evaluated in the context of the caller, or nil when it is not evaluated.
+func (b *Builder) moduleEvalContext(module *tfpath.TfModule, callerEvalContext *hcl.EvalContext) *hcl.EvalContext {
+	// variables set in 'inputs' that is not an object are not known, so neither are their defaults used
+	if callerEvalContext == nil || module.Arguments == nil {
+		return nil
+	}
+
+	return module.TfPath.EvalContext(module.ArgumentValues(callerEvalContext), forEachWorkspace)
+}
+
+// forEachInstances returns up to the limit of instances of 'for_each' evaluated in the context, and the number of all
+// of them, or nil when 'for_each' is not evaluated or its instances are not known. No instances are treated as not
+// known too, as they are mostly a value that is not known in the module, eg. the default of a variable set in the call.
+func (b *Builder) forEachInstances(
+	evalContext *hcl.EvalContext,
+	forEach string,
+	instances func(evalContext *hcl.EvalContext, maxInstances int) ([]*tfpath.TfInstance, int, error),
+) ([]*tfpath.TfInstance, int) {
+	if forEach == "" || evalContext == nil {
+		return nil, 0
+	}
+
+	found, foundNum, err := instances(evalContext, b.options.MaxInstances)
+	if err != nil || foundNum == 0 {
+		return nil, 0
+	}
+
+	return found, foundNum
+}
+
+// isDrawnPerInstance checks if there is an element for every instance, which is when there are not more instances
+// than the limit.
+func (b *Builder) isDrawnPerInstance(instancesNum int) bool {
+	return b.options.ForEachMode == ForEachInstances && instancesNum > 0 && instancesNum <= b.options.MaxInstances
+}
+
+// setKeys sets keys of the instances to list in the label, which are up to the limit, and the number of keys not
+// listed.
+func (b *Builder) setKeys(node *Node, instances []*tfpath.TfInstance, instancesNum int) {
+	if instances == nil {
+		return
+	}
+
+	node.Keys = []string{}
+
+	for _, instance := range instances {
+		node.Keys = append(node.Keys, strings.TrimSuffix(strings.TrimPrefix(instance.Key, "["), "]"))
+	}
+
+	node.KeysOmitted = instancesNum - len(instances)
+}
+
+// instanceIDPart returns a part of element ID of an instance. Keys may differ only in characters that cannot be in
+// IDs, so instances are numbered in the order of keys.
+func instanceIDPart(index int) string {
+	return "i" + strconv.Itoa(index)
+}
+
+func (b *Builder) addModules(
+	graph *Graph,
+	parent *Node,
+	tfPath *tfpath.TfPath,
+	pathID, parentModuleID string,
+	forceMultiple bool,
+	depth int,
+	visited []*tfpath.TfPath,
+	evalContext *hcl.EvalContext,
+) {
+	if tfPath == nil {
+		return
+	}
+
+	for _, moduleKey := range tfPath.ModuleNamesSorted() {
+		module := tfPath.Modules[moduleKey]
+		if module == nil {
+			continue
+		}
+
+		moduleID := pathID + idSeparator
+		if parentModuleID != "" {
+			moduleID += parentModuleID + idSeparator
+		}
+
+		moduleID += idPart(module.Name)
+
+		// modules deeper than the limit are not followed, but it is visible where they are
+		if depth > b.options.MaxModulesDepth {
+			if module.TfPath != nil {
+				b.addStop(graph, parent, module, "t"+partSeparator+moduleID, KindTruncated,
+					fmt.Sprintf("truncated: module depth limit %d reached", b.options.MaxModulesDepth))
+			}
+
+			continue
+		}
+
+		if !strings.HasPrefix(module.FieldSource, ".") {
+			graph.ModuleCalls = append(graph.ModuleCalls, module.FieldSource+"@"+module.FieldVersion)
+		}
+
+		if module.TfPath == nil {
+			continue
+		}
+
+		if slices.Contains(visited, module.TfPath) {
+			b.addStop(graph, parent, module, "c"+partSeparator+moduleID, KindCycle,
+				"cycle: module is already called on the way here")
+
+			continue
+		}
+
+		forEach := b.forEachText(module.FieldForEach, module.ForEachSource)
+
+		moduleNode := &Node{
+			ID:         "m" + partSeparator + moduleID,
+			Kind:       KindModule,
+			Multiple:   forEach != "" || forceMultiple,
+			Path:       module.TfPath.Path,
+			Name:       module.Name,
+			Component:  module.Component,
+			Source:     module.FieldSource,
+			Version:    module.FieldVersion,
+			Label:      module.TfPath.Label,
+			Resolution: module.Resolution,
+			ForEach:    forEach,
+			Count:      module.FieldCount,
+			FilePath:   module.FilePath,
+		}
+
+		instances, instancesNum := b.forEachInstances(evalContext, forEach, module.Instances)
+		moduleEvalContext := b.moduleEvalContext(module, evalContext)
+
+		if !b.isDrawnPerInstance(instancesNum) {
+			b.setKeys(moduleNode, instances, instancesNum)
+			b.addModule(graph, parent, module, moduleNode, pathID, moduleID, depth, visited, moduleEvalContext)
+
+			continue
+		}
+
+		// every instance calls the module with all its resources and modules
+		for i, instance := range instances {
+			instanceNode := *moduleNode
+			instanceNode.ID += partSeparator + instanceIDPart(i)
+			instanceNode.Multiple = forceMultiple
+			instanceNode.Name += instance.Key
+			instanceNode.ForEach = ""
+
+			b.addModule(graph, parent, module, &instanceNode, pathID, moduleID+partSeparator+instanceIDPart(i), depth,
+				visited, moduleEvalContext)
+		}
+	}
+}
+
+// addModule adds the module call to the parent, with the resources and the modules called from the module.
+func (b *Builder) addModule(
+	graph *Graph,
+	parent *Node,
+	module *tfpath.TfModule,
+	moduleNode *Node,
+	pathID, moduleID string,
+	depth int,
+	visited []*tfpath.TfPath,
+	evalContext *hcl.EvalContext,
+) {
+	moduleNode = graph.addNode(parent, moduleNode, EdgeContains)
+
+	b.addResources(graph, moduleNode, module.TfPath, moduleID, moduleNode.Multiple, evalContext)
+
+	if module.TfPath.Truncated {
+		b.addStop(graph, moduleNode, module, "t"+partSeparator+moduleID, KindTruncated,
+			"truncated: parse depth limit reached")
+
+		return
+	}
+
+	b.addModules(
+		graph,
+		moduleNode,
+		module.TfPath,
+		pathID,
+		moduleID,
+		moduleNode.Multiple,
+		depth+1,
+		append(append([]*tfpath.TfPath{}, visited...), module.TfPath),
+		evalContext,
+	)
+}
+
+// addStop adds an element in place of a module call that is not followed, as it is a cycle or it is truncated.
+func (b *Builder) addStop(graph *Graph, parent *Node, module *tfpath.TfModule, id string, kind Kind, reason string) {
+	graph.addNode(parent, &Node{
+		ID:        id,
+		Kind:      kind,
+		Name:      module.Name,
+		Component: module.Component,
+		Source:    module.FieldSource,
+		Version:   module.FieldVersion,
+		FilePath:  module.FilePath,
+		Reason:    reason,
+	}, EdgeContains)
+}
+
+// addDependencies links drawn paths that are Terragrunt units with the drawn units from their 'dependency' blocks.
+func (b *Builder) addDependencies(graph *Graph, rootTfPaths []*tfpath.TfPath) {
+	pathNodes := map[string]*Node{}
+
+	for _, node := range graph.Nodes {
+		if node.Kind != KindPath {
+			continue
+		}
+
+		// a path drawn in many deployments is linked once, from its first deployment
+		existing, exists := pathNodes[filepath.Clean(node.Path)]
+		if exists && existing.ID < node.ID {
+			continue
+		}
+
+		pathNodes[filepath.Clean(node.Path)] = node
+	}
+
+	for _, rootTfPath := range rootTfPaths {
+		tfPaths := []*tfpath.TfPath{rootTfPath}
+		for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+			tfPaths = append(tfPaths, rootTfPath.Children[childKey])
+		}
+
+		for _, tfPath := range tfPaths {
+			if tfPath == nil {
+				continue
+			}
+
+			pathNode, isDrawn := pathNodes[filepath.Clean(tfPath.Path)]
+			if !isDrawn {
+				continue
+			}
+
+			for _, dependencyName := range tfPath.DependencyNamesSorted() {
+				dependency := tfPath.Dependencies[dependencyName]
+
+				dependencyNode, isDependencyDrawn := pathNodes[filepath.Join(tfPath.Path, dependency.ConfigPath)]
+				if !isDependencyDrawn {
+					continue
+				}
+
+				graph.addEdge(pathNode, dependencyNode, EdgeDependsOn, "dependency."+dependency.Name)
+			}
+		}
+	}
+}
+
+// addSharedModules adds external modules (with the same source and version) that are called from more than one root,
+// and links them with the paths calling them, directly or from the modules they call.
+func (b *Builder) addSharedModules(graph *Graph) {
+	sources := []string{}
+	callers := map[string][]*Node{}
+	roots := map[string][]string{}
+
+	for _, rootNode := range graph.Roots {
+		for _, pathNode := range pathNodesIn(rootNode) {
+			for _, moduleNode := range externalModules(pathNode) {
+				source := moduleNode.Source + "@" + moduleNode.Version
+
+				_, exists := callers[source]
+				if !exists {
+					sources = append(sources, source)
+				}
+
+				callers[source] = append(callers[source], pathNode)
+
+				if !slices.Contains(roots[source], rootNode.Name) {
+					roots[source] = append(roots[source], rootNode.Name)
+				}
+			}
+		}
+	}
+
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if len(roots[source]) < 2 { //nolint:mnd
+			continue
+		}
+
+		moduleSource, moduleVersion, _ := strings.Cut(source, "@")
+
+		sharedNode := graph.addRoot(&Node{
+			ID:      "s" + partSeparator + idPart(source),
+			Kind:    KindSharedModule,
+			Source:  moduleSource,
+			Version: moduleVersion,
+			Roots:   roots[source],
+		})
+
+		for _, pathNode := range callers[source] {
+			graph.addEdge(pathNode, sharedNode, EdgeUses, "")
+		}
+	}
+}
+
+// pathNodesIn returns paths in the root, directly or in its deployments.
+func pathNodesIn(rootNode *Node) []*Node {
+	pathNodes := rootNode.ChildrenOfKind(KindPath)
+
+	for _, deploymentNode := range rootNode.ChildrenOfKind(KindDeployment) {
+		pathNodes = append(pathNodes, deploymentNode.ChildrenOfKind(KindPath)...)
+	}
+
+	return pathNodes
+}
+
+// externalModules returns external modules called from the node, directly or from the modules it calls.
+func externalModules(node *Node) []*Node {
+	modules := []*Node{}
+
+	for _, moduleNode := range node.ChildrenOfKind(KindModule) {
+		if !strings.HasPrefix(moduleNode.Source, ".") {
+			modules = append(modules, moduleNode)
+		}
+
+		modules = append(modules, externalModules(moduleNode)...)
+	}
+
+	return modules
+}
+
+func pathIDPart(tfPath *tfpath.TfPath) string {
+	// directories are joined with a separator, so that eg. 'a/bc' and 'ab/c' do not get the same ID
+	dirIDs := []string{}
+	for _, dirName := range strings.Split(tfPath.RelPath, "/") {
+		dirIDs = append(dirIDs, idPart(dirName))
+	}
+
+	pathID := strings.Join(dirIDs, idSeparator)
+	if pathID == "" {
+		return "root"
+	}
+
+	return pathID
+}
+
+// idPart returns text with only alphanumeric characters, so that it can be used in element IDs in every format.
+func idPart(text string) string {
+	return nonAlphanumericRegex.ReplaceAllString(text, "")
+}
@@ -0,0 +1,289 @@
+package graph_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"tfsketch/internal/graph"
+	"tfsketch/internal/tfpath"
+)
+
+const testRootName = "root"
+
+// cycleModules calls module 'a', that calls module 'b', that calls module 'a' again.
+var cycleModules = map[string]string{
+	"main.tf": `module "a" {
+  source = "./modules/a"
+}
+`,
+	"modules/a/main.tf": `resource "type" "a" {
+  name = "a"
+}
+
+module "b" {
+  source = "../b"
+}
+`,
+	"modules/b/main.tf": `resource "type" "b" {
+  name = "b"
+}
+
+module "a" {
+  source = "../a"
+}
+`,
+}
+
+// linkTestPath parses and links the path the way 'gen' does, with all resources and without a cache.
+func linkTestPath(t *testing.T, path string) []*tfpath.TfPath {
+	t.Helper()
+
+	container := tfpath.NewContainer()
+	traverser := tfpath.NewTraverser(container, "^.*$", "^SillyName$", "^.*$", "^.*$", "", nil)
+
+	rootTfPath := tfpath.NewTfPath(path, testRootName)
+	container.AddRootPath(testRootName, rootTfPath)
+
+	err := traverser.WalkPath(rootTfPath, false)
+	if err != nil {
+		t.Fatalf("error walking %s: %s", path, err)
+	}
+
+	err = container.ParsePaths(traverser, nil, 1)
+	if err != nil {
+		t.Fatalf("error parsing %s: %s", path, err)
+	}
+
+	err = container.LinkPaths(traverser)
+	if err != nil {
+		t.Fatalf("error linking %s: %s", path, err)
+	}
+
+	return []*tfpath.TfPath{rootTfPath}
+}
+
+// writeTestFiles writes files to a temporary directory and returns its path.
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(filePath), 0o750)
+		if err != nil {
+			t.Fatalf("error creating directory for %s: %s", name, err)
+		}
+
+		err = os.WriteFile(filePath, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("error writing %s: %s", name, err)
+		}
+	}
+
+	return dir
+}
+
+// nodeIDsOfKind returns sorted IDs of the graph nodes of the kind.
+func nodeIDsOfKind(testGraph *graph.Graph, kind graph.Kind) []string {
+	ids := []string{}
+
+	for id, node := range testGraph.Nodes {
+		if node.Kind == kind {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+func mustNode(t *testing.T, testGraph *graph.Graph, id string) *graph.Node {
+	t.Helper()
+
+	node := testGraph.Node(id)
+	if node == nil {
+		t.Fatalf("node %s not found", id)
+	}
+
+	return node
+}
+
+func TestBuildOnlyRoot(t *testing.T) {
+	rootTfPaths := linkTestPath(t, "../../tests/02-local-modules")
+
+	all := graph.NewBuilder(graph.Options{}).Build(rootTfPaths)
+	if len(nodeIDsOfKind(all, graph.KindPath)) < 2 {
+		t.Fatalf("sub-directories are not added: %v", nodeIDsOfKind(all, graph.KindPath))
+	}
+
+	onlyRoot := graph.NewBuilder(graph.Options{OnlyRoot: true}).Build(rootTfPaths)
+
+	paths := nodeIDsOfKind(onlyRoot, graph.KindPath)
+	if !slices.Equal(paths, []string{"p_root"}) {
+		t.Errorf("only the root path is expected, got %v", paths)
+	}
+
+	if len(onlyRoot.Roots) != 1 || onlyRoot.Roots[0].ID != "p_root" {
+		t.Errorf("the root path is expected to be the only root of the graph, got %d roots", len(onlyRoot.Roots))
+	}
+}
+
+func TestBuildMaxModulesDepth(t *testing.T) {
+	rootTfPaths := linkTestPath(t, writeTestFiles(t, cycleModules))
+
+	testGraph := graph.NewBuilder(graph.Options{MaxModulesDepth: 1}).Build(rootTfPaths)
+
+	modules := nodeIDsOfKind(testGraph, graph.KindModule)
+	if !slices.Equal(modules, []string{"m_root__a"}) {
+		t.Errorf("only module 'a' is expected within the depth, got %v", modules)
+	}
+
+	truncated := mustNode(t, testGraph, "m_root__a").ChildrenOfKind(graph.KindTruncated)
+	if len(truncated) != 1 || truncated[0].Name != "b" || truncated[0].Source != "../b" {
+		t.Fatalf("call of module 'b' is expected to be truncated in module 'a', got %v", truncated)
+	}
+
+	if len(nodeIDsOfKind(testGraph, graph.KindCycle)) > 0 {
+		t.Errorf("no cycle is expected within the depth, got %v", nodeIDsOfKind(testGraph, graph.KindCycle))
+	}
+}
+
+func TestBuildCycle(t *testing.T) {
+	rootTfPaths := linkTestPath(t, writeTestFiles(t, cycleModules))
+
+	testGraph := graph.NewBuilder(graph.Options{}).Build(rootTfPaths)
+
+	if len(nodeIDsOfKind(testGraph, graph.KindModule)) != 2 {
+		t.Errorf("modules 'a' and 'b' are expected once each, got %v", nodeIDsOfKind(testGraph, graph.KindModule))
+	}
+
+	moduleB := mustNode(t, testGraph, "m_root__a").ChildrenOfKind(graph.KindModule)
+	if len(moduleB) != 1 || moduleB[0].Name != "b" {
+		t.Fatalf("module 'b' is expected in module 'a', got %v", moduleB)
+	}
+
+	cycles := moduleB[0].ChildrenOfKind(graph.KindCycle)
+	if len(cycles) != 1 || cycles[0].Name != "a" || cycles[0].Source != "../a" {
+		t.Errorf("call of module 'a' from module 'b' is expected to be a cycle, got %v", cycles)
+	}
+
+	if len(nodeIDsOfKind(testGraph, graph.KindTruncated)) > 0 {
+		t.Errorf("no truncated module is expected, got %v", nodeIDsOfKind(testGraph, graph.KindTruncated))
+	}
+}
+
+func TestBuildProviderLocks(t *testing.T) {
+	rootTfPaths := linkTestPath(t, "../../tests/05-lock-files")
+
+	withoutLocks := graph.NewBuilder(graph.Options{}).Build(rootTfPaths)
+	if len(nodeIDsOfKind(withoutLocks, graph.KindProviderLocks)) > 0 {
+		t.Errorf("provider locks are not expected, got %v", nodeIDsOfKind(withoutLocks, graph.KindProviderLocks))
+	}
+
+	testGraph := graph.NewBuilder(graph.Options{ProviderLocks: true}).Build(rootTfPaths)
+
+	locks := nodeIDsOfKind(testGraph, graph.KindProviderLocks)
+	if len(locks) != 3 {
+		t.Fatalf("provider locks of 3 paths are expected, got %v", locks)
+	}
+
+	rootLocks := mustNode(t, testGraph, "l_root_l")
+
+	providerLock, exists := rootLocks.ProviderLocks["registry.terraform.io/hashicorp/aws"]
+	if !exists || providerLock.Version != "5.100.0" {
+		t.Errorf("aws provider 5.100.0 is expected in the root path locks, got %v", rootLocks.ProviderLocks)
+	}
+
+	if !slices.Contains(mustNode(t, testGraph, "p_root").Children, rootLocks) {
+		t.Error("provider locks are expected in the root path")
+	}
+}
+
+func TestBuildForEach(t *testing.T) {
+	rootTfPaths := linkTestPath(t, "../../tests/07-for-each")
+
+	t.Run(graph.ForEachRaw, func(t *testing.T) {
+		testGraph := graph.NewBuilder(graph.Options{}).Build(rootTfPaths)
+
+		set := mustNode(t, testGraph, "r_root__typeset")
+		if set.ForEach != "" || set.Keys != nil {
+			t.Errorf("for_each of a function call is not expected to be drawn, got %q and keys %v", set.ForEach, set.Keys)
+		}
+
+		list := mustNode(t, testGraph, "r_root__typelist")
+		if list.ForEach == "" || !list.Multiple {
+			t.Errorf("for_each of a list is expected to be drawn as in the code, got %+v", list)
+		}
+	})
+
+	t.Run(graph.ForEachKeys, func(t *testing.T) {
+		testGraph := graph.NewBuilder(graph.Options{ForEachMode: graph.ForEachKeys, MaxInstances: 2}).Build(rootTfPaths)
+
+		set := mustNode(t, testGraph, "r_root__typeset")
+		if !slices.Equal(set.Keys, []string{`"a"`, `"b"`}) || set.KeysOmitted != 1 {
+			t.Errorf("keys \"a\" and \"b\" and 1 omitted are expected, got %v and %d", set.Keys, set.KeysOmitted)
+		}
+
+		local := mustNode(t, testGraph, "r_root__typelocal")
+		if !slices.Equal(local.Keys, []string{`"db"`, `"web"`}) || local.KeysOmitted != 0 {
+			t.Errorf("keys of a local map are expected, got %v and %d", local.Keys, local.KeysOmitted)
+		}
+
+		unknown := mustNode(t, testGraph, "r_root__typeunknown")
+		if unknown.Keys != nil || unknown.ForEach == "" {
+			t.Errorf("for_each that is not known is expected as in the code, got %q and keys %v",
+				unknown.ForEach, unknown.Keys)
+		}
+
+		bucket := mustNode(t, testGraph, "r_root__buckets__typebucket")
+		if !slices.Equal(bucket.Keys, []string{`"x"`, `"y"`}) || bucket.KeysOmitted != 1 {
+			t.Errorf("keys from the module call arguments are expected, got %v and %d", bucket.Keys, bucket.KeysOmitted)
+		}
+
+		unknownBucket := mustNode(t, testGraph, "r_root__unknownbuckets__typebucket")
+		if unknownBucket.Keys != nil {
+			t.Errorf("keys of a module called with arguments that are not known are not expected, got %v",
+				unknownBucket.Keys)
+		}
+	})
+
+	t.Run(graph.ForEachInstances, func(t *testing.T) {
+		testGraph := graph.NewBuilder(graph.Options{ForEachMode: graph.ForEachInstances}).Build(rootTfPaths)
+
+		for i, name := range []string{`map["admin"]`, `map["viewer"]`} {
+			instance := mustNode(t, testGraph, "r_root__typemap_i"+strconv.Itoa(i))
+			if instance.Name != name || instance.Multiple || instance.ForEach != "" {
+				t.Errorf("instance %s is expected, got %+v", name, instance)
+			}
+		}
+
+		if testGraph.Node("r_root__typemap") != nil {
+			t.Error("resource is not expected when every instance is drawn")
+		}
+
+		buckets := mustNode(t, testGraph, "m_root__buckets").ChildrenOfKind(graph.KindResource)
+		if len(buckets) != 3 || buckets[2].Name != `bucket["z"]` {
+			t.Errorf("3 instances from the module call arguments are expected, got %d", len(buckets))
+		}
+
+		if mustNode(t, testGraph, "r_root__unknownbuckets__typebucket").ForEach == "" {
+			t.Error("for_each in a module called with arguments that are not known is expected as in the code")
+		}
+	})
+
+	t.Run("too many instances", func(t *testing.T) {
+		testGraph := graph.NewBuilder(graph.Options{ForEachMode: graph.ForEachInstances, MaxInstances: 2}).
+			Build(rootTfPaths)
+
+		set := mustNode(t, testGraph, "r_root__typeset")
+		if len(set.Keys) != 2 || set.KeysOmitted != 1 {
+			t.Errorf("keys are expected in place of instances above the limit, got %v and %d", set.Keys, set.KeysOmitted)
+		}
+	})
+}
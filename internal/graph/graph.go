@@ -0,0 +1,214 @@
+// Package graph contains the model of a diagram: elements found in the linked paths, with all the rules deciding
+// what is drawn already applied, so that every output format draws the same elements.
+package graph
+
+import "tfsketch/internal/tfpath"
+
+// Kind is a type of element in the graph.
+type Kind string
+
+const (
+	KindRoot          Kind = "root"
+	KindPath          Kind = "path"
+	KindModule        Kind = "module"
+	KindResource      Kind = "resource"
+	KindName          Kind = "name"
+	KindProviderLocks Kind = "provider-locks"
+	KindSharedModule  Kind = "shared-module"
+	KindCycle         Kind = "cycle"
+	KindTruncated     Kind = "truncated"
+	KindDeployment    Kind = "deployment"
+)
+
+// EdgeKind is a type of relation between two elements in the graph.
+type EdgeKind string
+
+const (
+	// EdgeContains links a path or a module with its resources, modules and provider locks.
+	EdgeContains EdgeKind = "contains"
+	// EdgeNamed links a resource with its name.
+	EdgeNamed EdgeKind = "named"
+	// EdgeUses links a path with an external module that is called from more than one root.
+	EdgeUses EdgeKind = "uses"
+	// EdgeDependsOn links a Terragrunt unit with a unit from its 'dependency' block.
+	EdgeDependsOn EdgeKind = "depends-on"
+)
+
+const (
+	idSeparator   = "__"
+	partSeparator = "_"
+)
+
+// Node is an element of the graph.
+type Node struct {
+	// ID is unique in the graph and stays the same between runs for the same code.
+	ID   string `json:"id"`
+	Kind Kind   `json:"kind"`
+
+	// Multiple is set when there can be many instances of the element, as it or any of the modules it is in has
+	// 'for_each'.
+	Multiple bool `json:"multiple,omitempty"`
+
+	// RelPath and Path are set on paths, and Path on roots.
+	RelPath string `json:"relPath,omitempty"`
+	Path    string `json:"path,omitempty"`
+
+	// Root is the name of the root that a path is in, set only when there is more than one root.
+	Root string `json:"root,omitempty"`
+
+	// ProviderLocks are set on paths and provider locks.
+	ProviderLocks map[string]*tfpath.TfProviderLock `json:"providerLocks,omitempty"`
+
+	// Type, Name and DisplayName are set on resources, Name and DisplayName on names, and Name on roots and
+	// deployments.
+	Type        string `json:"type,omitempty"`
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+
+	// Source, Version, Label and Resolution are set on modules, and Source and Version on shared modules; Path is
+	// the path of the module code.
+	Source     string `json:"source,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Label      string `json:"label,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+
+	// Component is set on modules that are Terraform Stacks components.
+	Component bool `json:"component,omitempty"`
+
+	// ForEach, Count and FilePath are set on resources and modules.
+	ForEach  string `json:"forEach,omitempty"`
+	Count    string `json:"count,omitempty"`
+	FilePath string `json:"filePath,omitempty"`
+
+	// Keys are keys of 'for_each' listed in the label, set on resources and modules when 'for_each' is statically
+	// known, and KeysOmitted is number of keys above the limit that are not listed. When every instance is drawn as
+	// an element, Name contains the key instead, eg. 'this["admin"]'.
+	Keys        []string `json:"keys,omitempty"`
+	KeysOmitted int      `json:"keysOmitted,omitempty"`
+
+	// Roots is set on shared modules with names of the roots that call the module.
+	Roots []string `json:"roots,omitempty"`
+
+	// Reason is set on cycles and truncated modules, that are drawn in place of modules that are not followed. They
+	// have Name, Source and Version of the module call set as well.
+	Reason string `json:"reason,omitempty"`
+
+	Children []*Node `json:"children"`
+}
+
+// Edge is a relation between two elements in the graph.
+type Edge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+
+	// Label is set on dependencies, eg. 'dependency.vpc'.
+	Label string `json:"label,omitempty"`
+}
+
+// Graph contains elements to draw. Paths are the roots, each containing its resources, provider locks and modules;
+// modules contain their resources and the modules they call, and resources contain their names. When more than one
+// root is scanned, roots are groups containing their paths, followed by external modules called from many roots,
+// that are linked with the paths calling them. Paths with Terraform Stacks deployments are drawn in a group for every
+// deployment, and Terragrunt units are linked with the units they depend on.
+type Graph struct {
+	Roots []*Node          `json:"roots"`
+	Nodes map[string]*Node `json:"-"`
+	Edges []*Edge          `json:"edges"`
+
+	// ModuleCalls contains 'source@version' of every external module call within the drawn depth, including the
+	// ones that could not be resolved.
+	ModuleCalls []string `json:"moduleCalls"`
+}
+
+// NewGraph returns an empty Graph instance.
+func NewGraph() *Graph {
+	return &Graph{
+		Roots:       []*Node{},
+		Nodes:       map[string]*Node{},
+		Edges:       []*Edge{},
+		ModuleCalls: []string{},
+	}
+}
+
+// Node returns the node with the id, or nil when there is none.
+func (g *Graph) Node(id string) *Node {
+	return g.Nodes[id]
+}
+
+func (g *Graph) addRoot(node *Node) *Node {
+	existing, exists := g.Nodes[node.ID]
+	if exists {
+		return existing
+	}
+
+	node.Children = []*Node{}
+	g.Nodes[node.ID] = node
+	g.Roots = append(g.Roots, node)
+
+	return node
+}
+
+func (g *Graph) addNode(parent, node *Node, edgeKind EdgeKind) *Node {
+	existing, exists := g.Nodes[node.ID]
+	if exists {
+		return existing
+	}
+
+	node.Children = []*Node{}
+	g.Nodes[node.ID] = node
+	parent.Children = append(parent.Children, node)
+	g.Edges = append(g.Edges, &Edge{From: parent.ID, To: node.ID, Kind: edgeKind})
+
+	return node
+}
+
+func (g *Graph) addEdge(from, to *Node, edgeKind EdgeKind, label string) {
+	for _, edge := range g.Edges {
+		if edge.From == from.ID && edge.To == to.ID {
+			return
+		}
+	}
+
+	g.Edges = append(g.Edges, &Edge{From: from.ID, To: to.ID, Kind: edgeKind, Label: label})
+}
+
+// EdgesOfKind returns edges of the kind.
+func (g *Graph) EdgesOfKind(kind EdgeKind) []*Edge {
+	edges := []*Edge{}
+
+	for _, edge := range g.Edges {
+		if edge.Kind == kind {
+			edges = append(edges, edge)
+		}
+	}
+
+	return edges
+}
+
+// IsContainer checks if node groups other elements, rather than being linked to them.
+func (n *Node) IsContainer() bool {
+	return n.Kind == KindRoot || n.Kind == KindDeployment || n.Kind == KindPath || n.Kind == KindModule
+}
+
+// ModuleAddress returns address of the module call, eg. 'module.vpc', or 'component.vpc' for components.
+func (n *Node) ModuleAddress() string {
+	if n.Component {
+		return "component." + n.Name
+	}
+
+	return "module." + n.Name
+}
+
+// ChildrenOfKind returns children of the node that are of the kind.
+func (n *Node) ChildrenOfKind(kind Kind) []*Node {
+	children := []*Node{}
+
+	for _, child := range n.Children {
+		if child.Kind == kind {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
@@ -0,0 +1,142 @@
+// Package layout contains rules classifying directories of the scanned paths, and presets bundling them for common
+// repository layouts.
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"tfsketch/internal/tfpath"
+)
+
+var (
+	ErrUnknownPreset       = errors.New("unknown layout preset")
+	ErrInvalidIgnoreDir    = errors.New("invalid ignore dir regexp")
+	ErrInvalidModuleDir    = errors.New("invalid module dir regexp")
+	ErrInvalidMaxPathDepth = errors.New("max path depth must be at least 1")
+)
+
+// DefaultPreset is the name of the preset used when none is set.
+const DefaultPreset = "default"
+
+// Layout contains rules classifying directories of the scanned paths.
+type Layout struct {
+	// IgnoreDir matches names of directories that are not scanned at all, eg. 'examples'
+	IgnoreDir *regexp.Regexp
+	// ModuleDir matches names of directories containing local modules, eg. 'modules'; they are drawn only when
+	// modules are drawn
+	ModuleDir *regexp.Regexp
+	// MaxPathDepth is the depth of sub-directories drawn, 1 being the first-level sub-directories only
+	MaxPathDepth int
+}
+
+type preset struct {
+	ignoreDirRegexp string
+	moduleDirRegexp string
+	maxPathDepth    int
+	description     string
+}
+
+//nolint:mnd
+var presets = map[string]*preset{
+	DefaultPreset: {
+		ignoreDirRegexp: tfpath.DefaultIgnoreDirRegexp,
+		moduleDirRegexp: tfpath.DefaultModuleDirRegexp,
+		maxPathDepth:    1,
+		description:     "first-level sub-directories, with local modules in 'modules'",
+	},
+	"terragrunt-live": {
+		ignoreDirRegexp: `^(example[s]*|test[s]*|_envcommon|\..*)$`,
+		moduleDirRegexp: tfpath.DefaultModuleDirRegexp,
+		maxPathDepth:    4,
+		description:     "units in 'live/<env>/<region>/<unit>', with shared includes in '_envcommon'",
+	},
+	"monorepo-modules": {
+		ignoreDirRegexp: tfpath.DefaultIgnoreDirRegexp,
+		moduleDirRegexp: `^(modules|components)$`,
+		maxPathDepth:    2,
+		description:     "stacks in 'stacks/<name>', with local modules in 'modules' and 'components'",
+	},
+}
+
+// New returns the Layout of the preset, with its rules replaced by ignoreDirRegexp, moduleDirRegexp and
+// maxPathDepth when they are set (not empty or zero).
+func New(presetName, ignoreDirRegexp, moduleDirRegexp string, maxPathDepth int) (*Layout, error) {
+	if presetName == "" {
+		presetName = DefaultPreset
+	}
+
+	layoutPreset, exists := presets[presetName]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s', available presets: %v", ErrUnknownPreset, presetName, PresetNames())
+	}
+
+	if ignoreDirRegexp == "" {
+		ignoreDirRegexp = layoutPreset.ignoreDirRegexp
+	}
+
+	if moduleDirRegexp == "" {
+		moduleDirRegexp = layoutPreset.moduleDirRegexp
+	}
+
+	if maxPathDepth == 0 {
+		maxPathDepth = layoutPreset.maxPathDepth
+	}
+
+	if maxPathDepth < 1 {
+		return nil, ErrInvalidMaxPathDepth
+	}
+
+	ignoreDir, err := regexp.Compile(ignoreDirRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIgnoreDir, err)
+	}
+
+	moduleDir, err := regexp.Compile(moduleDirRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModuleDir, err)
+	}
+
+	return &Layout{
+		IgnoreDir:    ignoreDir,
+		ModuleDir:    moduleDir,
+		MaxPathDepth: maxPathDepth,
+	}, nil
+}
+
+// Default returns the Layout of the default preset.
+func Default() *Layout {
+	defaultLayout, _ := New(DefaultPreset, "", "", 0)
+
+	return defaultLayout
+}
+
+// PresetNames returns names of the presets sorted alphabetically.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// PresetDescription returns a short description of the preset.
+func PresetDescription(presetName string) string {
+	layoutPreset, exists := presets[presetName]
+	if !exists {
+		return ""
+	}
+
+	return layoutPreset.description
+}
+
+// Apply sets the directory rules on the traverser.
+func (l *Layout) Apply(traverser *tfpath.Traverser) {
+	traverser.RegexpIgnoreDir = l.IgnoreDir
+	traverser.RegexpModuleDir = l.ModuleDir
+}
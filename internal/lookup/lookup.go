@@ -0,0 +1,175 @@
+// Package lookup contains finding where modules and resources are used, following module calls from the scanned
+// paths.
+package lookup
+
+import (
+	"regexp"
+
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	// KindModule is a match of a module call, by its source.
+	KindModule = "module"
+	// KindResource is a match of a resource, by its type.
+	KindResource = "resource"
+)
+
+// Call is a module call ('module' block) on the way from a scanned path to a match.
+type Call struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+	// Component is set when it is a Terraform Stacks component rather than a module.
+	Component bool   `json:"component,omitempty"`
+	File      string `json:"file"`
+	LineStart int    `json:"lineStart"`
+}
+
+// Address returns address of the call, eg. 'module.vpc', or 'component.vpc' for components.
+func (c *Call) Address() string {
+	return moduleAddress(c.Name, c.Component)
+}
+
+// Match is a module call or a resource that matches, along with the module calls it is reached through.
+type Match struct {
+	Kind string `json:"kind"`
+	// Root is the name of the scanned path, "." when there is only one, and RelPath is the directory in it where
+	// the first call is.
+	Root    string `json:"root"`
+	RelPath string `json:"relPath"`
+	// Calls are module calls from the directory to the match, not including the match itself.
+	Calls []*Call `json:"calls"`
+	// Address is 'module.name', 'component.name' or 'type.name'.
+	Address   string `json:"address"`
+	Source    string `json:"source,omitempty"`
+	Version   string `json:"version,omitempty"`
+	File      string `json:"file"`
+	LineStart int    `json:"lineStart"`
+}
+
+// Finder looks for module calls and resources in parsed and linked TfPath trees.
+type Finder struct {
+	sourceRegexp *regexp.Regexp
+	typeRegexp   *regexp.Regexp
+	matches      []*Match
+}
+
+// NewFinder returns a Finder instance. Module calls are matched by source with sourceRegexp, and resources by type
+// with typeRegexp; either can be nil to not look for them.
+func NewFinder(sourceRegexp, typeRegexp *regexp.Regexp) *Finder {
+	return &Finder{
+		sourceRegexp: sourceRegexp,
+		typeRegexp:   typeRegexp,
+	}
+}
+
+// Find returns matches in the root paths and all their sub-directories, following module calls down to any depth.
+// Modules that are already being followed are not followed again, so that cycles end.
+func (f *Finder) Find(rootTfPaths []*tfpath.TfPath) []*Match {
+	f.matches = []*Match{}
+
+	for _, rootTfPath := range rootTfPaths {
+		tfPaths := []*tfpath.TfPath{rootTfPath}
+		for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+			tfPaths = append(tfPaths, rootTfPath.Children[childKey])
+		}
+
+		for _, tfPath := range tfPaths {
+			if tfPath == nil {
+				continue
+			}
+
+			relPath := tfPath.RelPath
+			if relPath == "" {
+				relPath = "."
+			}
+
+			match := &Match{Root: rootTfPath.TraverseName, RelPath: relPath}
+
+			f.findInPath(tfPath, match, []*Call{}, map[*tfpath.TfPath]struct{}{})
+		}
+	}
+
+	return f.matches
+}
+
+// findInPath looks for matches in resources and module calls of the path. match has the root and the directory set,
+// calls are module calls that the path is reached through, and visited contains their paths.
+func (f *Finder) findInPath(
+	tfPath *tfpath.TfPath,
+	match *Match,
+	calls []*Call,
+	visited map[*tfpath.TfPath]struct{},
+) {
+	if f.typeRegexp != nil {
+		for _, resourceKey := range tfPath.ResourceNamesSorted() {
+			resource := tfPath.Resources[resourceKey]
+			if !f.typeRegexp.MatchString(resource.Type) {
+				continue
+			}
+
+			f.addMatch(match, calls, &Match{
+				Kind:      KindResource,
+				Address:   resource.Type + "." + resource.Name,
+				File:      resource.FilePath,
+				LineStart: resource.LineStart,
+			})
+		}
+	}
+
+	for _, moduleKey := range tfPath.ModuleNamesSorted() {
+		module := tfPath.Modules[moduleKey]
+
+		if f.sourceRegexp != nil && f.sourceRegexp.MatchString(module.FieldSource) {
+			f.addMatch(match, calls, &Match{
+				Kind:      KindModule,
+				Address:   moduleAddress(module.Name, module.Component),
+				Source:    module.FieldSource,
+				Version:   module.FieldVersion,
+				File:      module.FilePath,
+				LineStart: module.LineStart,
+			})
+		}
+
+		if module.TfPath == nil {
+			continue
+		}
+
+		_, isVisited := visited[module.TfPath]
+		if isVisited {
+			continue
+		}
+
+		visited[module.TfPath] = struct{}{}
+
+		call := &Call{
+			Name:      module.Name,
+			Source:    module.FieldSource,
+			Version:   module.FieldVersion,
+			Component: module.Component,
+			File:      module.FilePath,
+			LineStart: module.LineStart,
+		}
+
+		f.findInPath(module.TfPath, match, append(append([]*Call{}, calls...), call), visited)
+
+		delete(visited, module.TfPath)
+	}
+}
+
+func moduleAddress(name string, component bool) string {
+	if component {
+		return "component." + name
+	}
+
+	return "module." + name
+}
+
+func (f *Finder) addMatch(match *Match, calls []*Call, found *Match) {
+	found.Root = match.Root
+	found.RelPath = match.RelPath
+	found.Calls = calls
+
+	f.matches = append(f.matches, found)
+}
@@ -0,0 +1,162 @@
+package overrides
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	regexpRegistrySource = regexp.MustCompile(`^([a-zA-Z0-9\-_]+)/([a-zA-Z0-9\-_]+)/([a-zA-Z0-9\-_]+)$`)
+	regexpExactVersion   = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[a-zA-Z0-9\.\-]+)?$`)
+)
+
+// Generate returns a starter overrides file for external modules, passed as 'source@version' strings. Registry
+// modules get a guessed 'cache' source on GitHub, other modules are commented out, and a 'local' entry is added
+// as a comment to each of them. Versions of the same source are grouped into one regular expression.
+func Generate(modules []string, scannedPath string) string {
+	versionsBySource := map[string][]string{}
+
+	for _, module := range modules {
+		// version is always after the last '@' as source can contain it too, eg. 'git::ssh://git@host/repo.git'
+		separatorIndex := strings.LastIndex(module, "@")
+		if separatorIndex == -1 {
+			continue
+		}
+
+		source, version := module[:separatorIndex], module[separatorIndex+1:]
+		if source == "" || strings.HasPrefix(source, ".") {
+			continue
+		}
+
+		if !slices.Contains(versionsBySource[source], version) {
+			versionsBySource[source] = append(versionsBySource[source], version)
+		}
+	}
+
+	sources := make([]string, 0, len(versionsBySource))
+	for source := range versionsBySource {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	builder := &strings.Builder{}
+	_, _ = fmt.Fprintf(builder, "# Generated by 'tfsketch overrides generate' from %s\n", scannedPath)
+	builder.WriteString("# Review the 'cache' guesses, and uncomment 'local' to use a local copy of a module instead.\n")
+	builder.WriteString("version: 2\n")
+	builder.WriteString("externalModules:\n")
+
+	for _, source := range sources {
+		versions := versionsBySource[source]
+		sort.Strings(versions)
+
+		writeGeneratedEntry(builder, source, versions)
+	}
+
+	return builder.String()
+}
+
+func writeGeneratedEntry(builder *strings.Builder, source string, versions []string) {
+	_, _ = fmt.Fprintf(builder, "\n# %s used at versions: %s\n", source, versionsList(versions))
+
+	remote, ref := generatedRemoteAndRef(source, versions)
+	localGuess := "../" + localDirGuess(source)
+
+	matches := regexpRegistrySource.FindStringSubmatch(source)
+	if len(matches) == 0 {
+		builder.WriteString("# not a registry module, set 'local' or 'cache' for it\n")
+		_, _ = fmt.Fprintf(builder, "#- remote: %s\n", remote)
+		_, _ = fmt.Fprintf(builder, "#  local: %s\n", localGuess)
+
+		return
+	}
+
+	namespace, name, provider := matches[1], matches[2], matches[3]
+
+	if ref == "" {
+		builder.WriteString("# no exact version pinned, 'main' branch is a guess\n")
+
+		ref = "main"
+	}
+
+	_, _ = fmt.Fprintf(builder, "- remote: %s\n", remote)
+	_, _ = fmt.Fprintf(
+		builder,
+		"  cache: git::https://github.com/%s/terraform-%s-%s.git?ref=%s\n",
+		namespace,
+		provider,
+		name,
+		ref,
+	)
+	_, _ = fmt.Fprintf(builder, "#  local: %s\n", localGuess)
+}
+
+// generatedRemoteAndRef returns the 'remote' for module source, which is a regular expression when there is more
+// than one exact version, and a git ref the versions translate to.
+func generatedRemoteAndRef(source string, versions []string) (string, string) {
+	exactVersions := []string{}
+
+	for _, version := range versions {
+		if regexpExactVersion.MatchString(version) {
+			exactVersions = append(exactVersions, version)
+		}
+	}
+
+	if len(versions) == 1 {
+		ref := ""
+		if len(exactVersions) == 1 {
+			ref = "v" + strings.TrimPrefix(exactVersions[0], "v")
+		}
+
+		return source + "@" + versions[0], ref
+	}
+
+	// versions that are not exact (eg. constraints or empty) cannot be translated to a ref
+	if len(exactVersions) != len(versions) {
+		quotedVersions := make([]string, 0, len(versions))
+		for _, version := range versions {
+			quotedVersions = append(quotedVersions, regexp.QuoteMeta(version))
+		}
+
+		return fmt.Sprintf("^%s@(%s)$", regexp.QuoteMeta(source), strings.Join(quotedVersions, "|")), ""
+	}
+
+	quotedVersions := make([]string, 0, len(exactVersions))
+	for _, version := range exactVersions {
+		quotedVersions = append(quotedVersions, regexp.QuoteMeta(strings.TrimPrefix(version, "v")))
+	}
+
+	return fmt.Sprintf("^%s@v?(%s)$", regexp.QuoteMeta(source), strings.Join(quotedVersions, "|")), "v{1}"
+}
+
+func localDirGuess(source string) string {
+	matches := regexpRegistrySource.FindStringSubmatch(source)
+	if len(matches) > 0 {
+		return fmt.Sprintf("terraform-%s-%s", matches[3], matches[2])
+	}
+
+	source = strings.TrimPrefix(source, "git::")
+	source, _, _ = strings.Cut(source, "?")
+	source = strings.TrimSuffix(source, ".git")
+
+	parts := strings.Split(strings.TrimRight(source, "/"), "/")
+
+	return parts[len(parts)-1]
+}
+
+func versionsList(versions []string) string {
+	listed := make([]string, 0, len(versions))
+
+	for _, version := range versions {
+		if version == "" {
+			version = "(none)"
+		}
+
+		listed = append(listed, version)
+	}
+
+	return strings.Join(listed, ", ")
+}
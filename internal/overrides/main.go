@@ -6,32 +6,50 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 	"tfsketch/internal/remotetolocal"
 )
 
 // Overrides represents a YAML file that contains local paths where external Terraform module are meant to be found.
 // Application cannot get the source of a module from Terraform registry yet. Hence, it needs to be put locally.
 type Overrides struct {
+	// Version is the version of the file format. Version 2 adds includes and per-module options.
+	Version int `yaml:"version,omitempty"`
+
+	// Include contains paths to other overrides files, relative to the file, whose entries are appended.
+	Include []string `yaml:"include,omitempty"`
+
 	ExternalModules []*remotetolocal.RemoteToLocal `yaml:"externalModules"`
 }
 
 var (
 	ErrRead      = errors.New("error reading file")
 	ErrUnmarshal = errors.New("error unmarshaling yaml file")
+	ErrValidate  = errors.New("error validating file")
 )
 
-// ReadFromFile takes a YAML file and gets its entries.
+// ReadFromFile takes a YAML file and gets its entries, including entries from included files. Environment variables
+// in paths are expanded. All the problems found in the file are returned in one error, with line numbers.
 func (o *Overrides) ReadFromFile(path string) error {
-	fileContents, err := os.ReadFile(filepath.Clean(path))
+	validationErrors := &ValidationErrors{}
+
+	err := o.readFile(path, map[string]struct{}{}, validationErrors)
 	if err != nil {
-		return fmt.Errorf("%w: %w", ErrRead, err)
+		return err
 	}
 
-	err = yaml.Unmarshal(fileContents, &o)
-	if err != nil {
-		return fmt.Errorf("%w: %w", ErrUnmarshal, err)
+	if len(validationErrors.Errors) > 0 {
+		sort.SliceStable(validationErrors.Errors, func(i, j int) bool {
+			if validationErrors.Errors[i].File != validationErrors.Errors[j].File {
+				return validationErrors.Errors[i].File < validationErrors.Errors[j].File
+			}
+
+			return validationErrors.Errors[i].Line < validationErrors.Errors[j].Line
+		})
+
+		return fmt.Errorf("%w: %w", ErrValidate, validationErrors)
 	}
 
 	return nil
@@ -49,3 +67,89 @@ func (o *Overrides) AddExternalModule(remote, local string) {
 		Local:  local,
 	})
 }
+
+// AddExternalModuleEntry adds an externalmodule with all its options
+func (o *Overrides) AddExternalModuleEntry(remoteToLocal *remotetolocal.RemoteToLocal) {
+	o.ExternalModules = append(o.ExternalModules, remoteToLocal)
+}
+
+func (o *Overrides) readFile(path string, visited map[string]struct{}, validationErrors *ValidationErrors) error {
+	absPath, err := filepath.Abs(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	// visited contains files that are being read, so that include cycles can be detected
+	visited[absPath] = struct{}{}
+	defer delete(visited, absPath)
+
+	fileContents, err := os.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	document := &yaml.Node{}
+
+	err = yaml.Unmarshal(fileContents, document)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrUnmarshal, path, err)
+	}
+
+	// empty file
+	if document.Kind == 0 {
+		return nil
+	}
+
+	validator := newValidator(path, validationErrors)
+	fileOverrides := &Overrides{}
+
+	// type errors do not stop decoding so they are reported along with other problems
+	err = document.Decode(fileOverrides)
+	if err != nil {
+		typeError := &yaml.TypeError{}
+		if !errors.As(err, &typeError) {
+			return fmt.Errorf("%w: %s: %w", ErrUnmarshal, path, err)
+		}
+
+		validator.addTypeErrors(typeError)
+	}
+
+	validator.validateDocument(document, fileOverrides)
+
+	o.ExternalModules = append(o.ExternalModules, fileOverrides.ExternalModules...)
+
+	includeLines := validator.includeLines(document)
+
+	for i, includePath := range fileOverrides.Include {
+		line := 0
+		if i < len(includeLines) {
+			line = includeLines[i]
+		}
+
+		includePath = validator.expandEnv(includePath, line)
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), includePath)
+		}
+
+		absIncludePath, err := filepath.Abs(filepath.Clean(includePath))
+		if err != nil {
+			validator.addError(line, "invalid include path %s: %s", includePath, err.Error())
+
+			continue
+		}
+
+		_, isVisited := visited[absIncludePath]
+		if isVisited {
+			validator.addError(line, "include cycle detected: %s", includePath)
+
+			continue
+		}
+
+		err = o.readFile(includePath, visited, validationErrors)
+		if err != nil {
+			validator.addError(line, "error including %s: %s", includePath, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package overrides
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	"tfsketch/internal/remotetolocal"
+)
+
+// ValidationError represents a single problem found in an overrides file.
+type ValidationError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// Error returns the problem prefixed with file name and line.
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", v.File, v.Line, v.Message)
+}
+
+// ValidationErrors contains all the problems found in overrides files.
+type ValidationErrors struct {
+	Errors []*ValidationError
+}
+
+// Error returns all the problems, one per line.
+func (v *ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v.Errors))
+	for _, validationError := range v.Errors {
+		messages = append(messages, validationError.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+var (
+	topLevelFields = map[string]struct{}{
+		"version":         {},
+		"include":         {},
+		"externalModules": {},
+	}
+	externalModuleFields = map[string]struct{}{
+		"remote": {},
+		"local":  {},
+		"cache":  {},
+		"subdir": {},
+		"ref":    {},
+		"ignore": {},
+		"label":  {},
+	}
+)
+
+var regexpTypeErrorLine = regexp.MustCompile(`^line ([0-9]+): (.*)$`)
+
+type validator struct {
+	path             string
+	validationErrors *ValidationErrors
+}
+
+func newValidator(path string, validationErrors *ValidationErrors) *validator {
+	return &validator{
+		path:             path,
+		validationErrors: validationErrors,
+	}
+}
+
+func (v *validator) addError(line int, format string, args ...any) {
+	v.validationErrors.Errors = append(v.validationErrors.Errors, &ValidationError{
+		File:    v.path,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) addTypeErrors(typeError *yaml.TypeError) {
+	for _, message := range typeError.Errors {
+		matches := regexpTypeErrorLine.FindStringSubmatch(message)
+		if len(matches) == 0 {
+			v.addError(0, "%s", message)
+
+			continue
+		}
+
+		line, _ := strconv.Atoi(matches[1])
+		v.addError(line, "%s", matches[2])
+	}
+}
+
+// validateDocument checks fields in the file, sets file and line on each entry, and expands environment
+// variables in paths.
+func (v *validator) validateDocument(document *yaml.Node, fileOverrides *Overrides) {
+	root := v.rootMapping(document)
+	if root == nil {
+		return
+	}
+
+	v.validateFields(root, topLevelFields)
+
+	entryNodes := v.sequenceItems(root, "externalModules")
+
+	for i, externalModule := range fileOverrides.ExternalModules {
+		if externalModule == nil {
+			continue
+		}
+
+		externalModule.File = v.path
+
+		if i < len(entryNodes) {
+			externalModule.Line = entryNodes[i].Line
+			v.validateFields(entryNodes[i], externalModuleFields)
+		}
+
+		v.validateExternalModule(externalModule)
+	}
+}
+
+func (v *validator) validateExternalModule(externalModule *remotetolocal.RemoteToLocal) {
+	line := externalModule.Line
+
+	externalModule.Local = v.expandEnv(externalModule.Local, line)
+	externalModule.Cache = v.expandEnv(externalModule.Cache, line)
+	externalModule.Subdir = v.expandEnv(externalModule.Subdir, line)
+
+	remote := strings.TrimSpace(externalModule.Remote)
+	if remote == "" {
+		v.addError(line, "'remote' is required")
+
+		return
+	}
+
+	if strings.HasPrefix(remote, "^") {
+		_, err := regexp.Compile(remote)
+		if err != nil {
+			v.addError(line, "invalid 'remote' regular expression %s: %s", remote, err.Error())
+		}
+	}
+
+	if externalModule.Ignore {
+		return
+	}
+
+	if strings.TrimSpace(externalModule.Local) == "" && strings.TrimSpace(externalModule.Cache) == "" {
+		v.addError(line, "either 'local' or 'cache' is required for %s", remote)
+	}
+
+	if externalModule.Ref != "" && strings.TrimSpace(externalModule.Cache) == "" {
+		v.addError(line, "'ref' requires 'cache' for %s", remote)
+	}
+}
+
+// expandEnv replaces ${VAR} and $VAR with values of environment variables and reports variables that are not set.
+func (v *validator) expandEnv(value string, line int) string {
+	return os.Expand(value, func(name string) string {
+		envValue, exists := os.LookupEnv(name)
+		if !exists {
+			v.addError(line, "environment variable %s is not set", name)
+		}
+
+		return envValue
+	})
+}
+
+func (v *validator) validateFields(mapping *yaml.Node, knownFields map[string]struct{}) {
+	if mapping.Kind != yaml.MappingNode {
+		v.addError(mapping.Line, "expected a mapping")
+
+		return
+	}
+
+	//nolint:mnd
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode := mapping.Content[i]
+
+		_, known := knownFields[keyNode.Value]
+		if !known {
+			v.addError(keyNode.Line, "unknown field '%s'", keyNode.Value)
+		}
+	}
+}
+
+func (v *validator) includeLines(document *yaml.Node) []int {
+	root := v.rootMapping(document)
+	if root == nil {
+		return []int{}
+	}
+
+	itemNodes := v.sequenceItems(root, "include")
+
+	lines := make([]int, 0, len(itemNodes))
+	for _, itemNode := range itemNodes {
+		lines = append(lines, itemNode.Line)
+	}
+
+	return lines
+}
+
+func (v *validator) rootMapping(document *yaml.Node) *yaml.Node {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil
+	}
+
+	return document.Content[0]
+}
+
+func (v *validator) sequenceItems(mapping *yaml.Node, key string) []*yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return []*yaml.Node{}
+	}
+
+	//nolint:mnd
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+
+		valueNode := mapping.Content[i+1]
+		if valueNode.Kind != yaml.SequenceNode {
+			return []*yaml.Node{}
+		}
+
+		return valueNode.Content
+	}
+
+	return []*yaml.Node{}
+}
@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	// RuleResourceName is reported when display attribute of a resource does not match the pattern.
+	RuleResourceName = "resource-name"
+	// RuleForbiddenModuleSource is reported when a module source is forbidden.
+	RuleForbiddenModuleSource = "forbidden-module-source"
+	// RuleMaxModuleNesting is reported when module calls are nested too deep.
+	RuleMaxModuleNesting = "max-module-nesting"
+	// RuleIteration is reported when a resource uses 'count' instead of 'for_each' or the other way round.
+	RuleIteration = "iteration"
+)
+
+// Violation represents a place in Terraform code that breaks a rule.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Address is a resource ('type.name') or a module ('module.name') that breaks the rule.
+	Address   string `json:"address"`
+	File      string `json:"file"`
+	LineStart int    `json:"lineStart"`
+	LineEnd   int    `json:"lineEnd"`
+}
+
+// Checker evaluates rules against parsed and linked TfPath tree.
+type Checker struct {
+	rules      *Rules
+	violations []*Violation
+}
+
+// NewChecker returns a Checker instance.
+func NewChecker(rules *Rules) *Checker {
+	return &Checker{
+		rules: rules,
+	}
+}
+
+// Check returns violations found in the root path and its sub-directories. Module sources and nesting are checked
+// in all the modules that are called from there, including external ones.
+func (c *Checker) Check(rootTfPath *tfpath.TfPath) []*Violation {
+	c.violations = []*Violation{}
+
+	tfPaths := []*tfpath.TfPath{rootTfPath}
+	for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+		tfPaths = append(tfPaths, rootTfPath.Children[childKey])
+	}
+
+	for _, tfPath := range tfPaths {
+		for _, resourceKey := range tfPath.ResourceNamesSorted() {
+			resource := tfPath.Resources[resourceKey]
+
+			c.checkResourceName(resource)
+			c.checkResourceIteration(resource)
+		}
+
+		for _, moduleKey := range tfPath.ModuleNamesSorted() {
+			module := tfPath.Modules[moduleKey]
+
+			c.checkModuleIteration(module)
+			c.checkModuleCalls(module, []string{"module." + module.Name}, map[*tfpath.TfPath]struct{}{})
+		}
+	}
+
+	sort.SliceStable(c.violations, func(i, j int) bool {
+		if c.violations[i].File != c.violations[j].File {
+			return c.violations[i].File < c.violations[j].File
+		}
+
+		return c.violations[i].LineStart < c.violations[j].LineStart
+	})
+
+	return c.violations
+}
+
+func (c *Checker) checkResourceName(resource *tfpath.TfResource) {
+	address := resource.Type + "." + resource.Name
+
+	for _, rule := range c.rules.ResourceNames {
+		if !rule.regexpType.MatchString(resource.Type) {
+			continue
+		}
+
+		if !resource.HasDisplayAttribute() {
+			c.addViolation(RuleResourceName, rule.Message, "display attribute not found", address, resource.FilePath,
+				resource.LineStart, resource.LineEnd)
+
+			continue
+		}
+
+		// references, eg. 'var.name', cannot be evaluated
+		if !strings.HasPrefix(resource.FieldName, `"`) {
+			slog.Debug(
+				fmt.Sprintf("🔍 Skipped checking name %s of resource %s as it is not a string", resource.FieldName, address),
+			)
+
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(resource.FieldName, `"`), `"`)
+
+		// templates, eg. "role-${each.key}", are not known until they are evaluated either
+		if strings.Contains(name, "${") || strings.Contains(name, "%{") {
+			slog.Debug(
+				fmt.Sprintf("🔍 Skipped checking name %s of resource %s as it is a template", resource.FieldName, address),
+			)
+
+			continue
+		}
+
+		if rule.regexpPattern.MatchString(name) {
+			continue
+		}
+
+		c.addViolation(
+			RuleResourceName,
+			rule.Message,
+			fmt.Sprintf("name %s does not match %s", resource.FieldName, rule.Pattern),
+			address,
+			resource.FilePath,
+			resource.LineStart,
+			resource.LineEnd,
+		)
+	}
+}
+
+func (c *Checker) checkResourceIteration(resource *tfpath.TfResource) {
+	c.checkIteration(resource.Type, resource.Type+"."+resource.Name, resource.FieldForEach, resource.FieldCount,
+		resource.FilePath, resource.LineStart, resource.LineEnd)
+}
+
+func (c *Checker) checkModuleIteration(module *tfpath.TfModule) {
+	c.checkIteration(iterationModuleType, "module."+module.Name, module.FieldForEach, module.FieldCount,
+		module.FilePath, module.LineStart, module.LineEnd)
+}
+
+func (c *Checker) checkIteration(blockType, address, forEach, count, file string, lineStart, lineEnd int) {
+	for _, rule := range c.rules.Iteration {
+		if !rule.regexpType.MatchString(blockType) {
+			continue
+		}
+
+		if rule.Require == IterationForEach && count != "" {
+			c.addViolation(RuleIteration, rule.Message, "count is used instead of for_each", address, file,
+				lineStart, lineEnd)
+		}
+
+		if rule.Require == IterationCount && forEach != "" {
+			c.addViolation(RuleIteration, rule.Message, "for_each is used instead of count", address, file,
+				lineStart, lineEnd)
+		}
+	}
+}
+
+// checkModuleCalls checks source of the module and follows modules it calls. chain contains module calls from
+// the root path, so its length is the nesting depth, and visited contains their paths so that cycles are not
+// followed.
+func (c *Checker) checkModuleCalls(module *tfpath.TfModule, chain []string, visited map[*tfpath.TfPath]struct{}) {
+	for _, rule := range c.rules.ForbiddenModuleSources {
+		if rule.regexpSource.MatchString(module.FieldSource) {
+			c.addViolation(
+				RuleForbiddenModuleSource,
+				rule.Message,
+				fmt.Sprintf("source %s is forbidden (called via %s)", module.FieldSource, strings.Join(chain, " > ")),
+				"module."+module.Name,
+				module.FilePath,
+				module.LineStart,
+				module.LineEnd,
+			)
+		}
+	}
+
+	if c.rules.MaxModuleNesting > 0 && len(chain) > c.rules.MaxModuleNesting {
+		c.addViolation(
+			RuleMaxModuleNesting,
+			"",
+			fmt.Sprintf(
+				"module nesting is %d, more than %d: %s",
+				len(chain),
+				c.rules.MaxModuleNesting,
+				strings.Join(chain, " > "),
+			),
+			"module."+module.Name,
+			module.FilePath,
+			module.LineStart,
+			module.LineEnd,
+		)
+
+		return
+	}
+
+	if module.TfPath == nil {
+		return
+	}
+
+	_, isVisited := visited[module.TfPath]
+	if isVisited {
+		return
+	}
+
+	visited[module.TfPath] = struct{}{}
+	defer delete(visited, module.TfPath)
+
+	for _, childModuleKey := range module.TfPath.ModuleNamesSorted() {
+		childModule := module.TfPath.Modules[childModuleKey]
+
+		c.checkModuleCalls(childModule, append(append([]string{}, chain...), "module."+childModule.Name), visited)
+	}
+}
+
+func (c *Checker) addViolation(rule, ruleMessage, message, address, file string, lineStart, lineEnd int) {
+	if ruleMessage != "" {
+		message = ruleMessage + ": " + message
+	}
+
+	c.violations = append(c.violations, &Violation{
+		Rule:      rule,
+		Message:   message,
+		Address:   address,
+		File:      file,
+		LineStart: lineStart,
+		LineEnd:   lineEnd,
+	})
+}
@@ -0,0 +1,143 @@
+// Package policy contains rules that Terraform code is checked against, and the checker itself.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRead      = errors.New("error reading rules file")
+	ErrUnmarshal = errors.New("error unmarshaling rules file")
+	ErrValidate  = errors.New("error validating rules file")
+)
+
+const (
+	// IterationForEach requires resources to be repeated with 'for_each'.
+	IterationForEach = "for_each"
+	// IterationCount requires resources to be repeated with 'count'.
+	IterationCount = "count"
+
+	// iterationModuleType is used in 'type' of iteration rule to match 'module' blocks.
+	iterationModuleType = "module"
+)
+
+// Rules represents a YAML file with rules that Terraform code is checked against.
+type Rules struct {
+	// ResourceNames contains patterns that display attribute (eg. 'name') of resources must match.
+	ResourceNames []*ResourceNameRule `yaml:"resourceNames"`
+
+	// ForbiddenModuleSources contains module sources that must not be used.
+	ForbiddenModuleSources []*ForbiddenModuleSourceRule `yaml:"forbiddenModuleSources"`
+
+	// MaxModuleNesting is the maximum depth of module calls, 0 means no limit.
+	MaxModuleNesting int `yaml:"maxModuleNesting"`
+
+	// Iteration contains whether resources are meant to use 'for_each' or 'count'.
+	Iteration []*IterationRule `yaml:"iteration"`
+}
+
+// ResourceNameRule requires display attribute of resources of a type to match a pattern.
+type ResourceNameRule struct {
+	Type    string `yaml:"type"`
+	Pattern string `yaml:"pattern"`
+	Message string `yaml:"message"`
+
+	regexpType    *regexp.Regexp
+	regexpPattern *regexp.Regexp
+}
+
+// ForbiddenModuleSourceRule forbids module sources that match a regular expression.
+type ForbiddenModuleSourceRule struct {
+	Source  string `yaml:"source"`
+	Message string `yaml:"message"`
+
+	regexpSource *regexp.Regexp
+}
+
+// IterationRule requires resources of a type (or modules when type is 'module') to use 'for_each' or 'count'
+// when they are repeated.
+type IterationRule struct {
+	Type    string `yaml:"type"`
+	Require string `yaml:"require"`
+	Message string `yaml:"message"`
+
+	regexpType *regexp.Regexp
+}
+
+// ReadFromFile takes a YAML file, gets its rules and compiles regular expressions in them.
+func (r *Rules) ReadFromFile(path string) error {
+	fileContents, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	// unknown fields are errors so that a typo does not silently disable a rule
+	decoder := yaml.NewDecoder(bytes.NewReader(fileContents))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(r)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", ErrUnmarshal, err)
+	}
+
+	return r.compile()
+}
+
+func (r *Rules) compile() error {
+	errs := []error{}
+
+	compile := func(field, expr string) *regexp.Regexp {
+		if expr == "" {
+			errs = append(errs, fmt.Errorf("%s: cannot be empty", field))
+
+			return nil
+		}
+
+		compiled, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+
+			return nil
+		}
+
+		return compiled
+	}
+
+	for i, rule := range r.ResourceNames {
+		rule.regexpType = compile(fmt.Sprintf("resourceNames[%d].type", i), rule.Type)
+		rule.regexpPattern = compile(fmt.Sprintf("resourceNames[%d].pattern", i), rule.Pattern)
+	}
+
+	for i, rule := range r.ForbiddenModuleSources {
+		rule.regexpSource = compile(fmt.Sprintf("forbiddenModuleSources[%d].source", i), rule.Source)
+	}
+
+	for i, rule := range r.Iteration {
+		rule.regexpType = compile(fmt.Sprintf("iteration[%d].type", i), rule.Type)
+
+		if rule.Require != IterationForEach && rule.Require != IterationCount {
+			errs = append(
+				errs,
+				fmt.Errorf("iteration[%d].require: must be '%s' or '%s'", i, IterationForEach, IterationCount),
+			)
+		}
+	}
+
+	if r.MaxModuleNesting < 0 {
+		errs = append(errs, errors.New("maxModuleNesting: cannot be negative"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrValidate, errors.Join(errs...))
+	}
+
+	return nil
+}
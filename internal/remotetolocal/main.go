@@ -26,4 +26,26 @@ type RemoteToLocal struct {
 	// Cache is a source that requires downloading (for example using git) to
 	// cache directory.  Cache has precedence over Local.
 	Cache string `yaml:"cache,omitempty"`
+
+	// Subdir is a path inside the Local or downloaded Cache directory where the
+	// module code is. Captured groups from Remote can be interpolated, as in Local.
+	Subdir string `yaml:"subdir,omitempty"`
+
+	// Ref replaces the git ref (the "?ref=" part) in Cache. Captured groups from
+	// Remote can be interpolated, as in Local.
+	Ref string `yaml:"ref,omitempty"`
+
+	// Ignore marks the module as one that is deliberately skipped. It is neither
+	// downloaded nor reported as missing.
+	Ignore bool `yaml:"ignore,omitempty"`
+
+	// Label is a name that the module is displayed with in the chart, instead of
+	// its source and version.
+	Label string `yaml:"label,omitempty"`
+
+	// File is the overrides file that the mapping was read from.
+	File string `yaml:"-"`
+
+	// Line is the line in File where the mapping starts.
+	Line int `yaml:"-"`
 }
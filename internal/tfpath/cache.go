@@ -1,13 +1,11 @@
 package tfpath
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -21,33 +19,83 @@ var (
 	ErrCreatingModuleDir               = errors.New("error creating module directory")
 	ErrGitCloneFailed                  = errors.New("error running 'git clone' command")
 	ErrGitCheckoutFailed               = errors.New("error running 'git checkout' command")
+	ErrGitRevParseFailed               = errors.New("error running 'git rev-parse' command")
+	ErrModuleNotCachedOffline          = errors.New("module not found in cache and offline mode is enabled")
 )
 
 const (
 	headerWithSource = "X-Terraform-Get"
-	gitCloneTimeout = 120
+	gitCloneTimeout  = 120
 )
 
+// regexpModuleDirName matches names of module directories in the cache, ie. 'source@version' with '/' replaced
+// with '__', so that nothing else is removed from the cache directory.
+var regexpModuleDirName = regexp.MustCompile(`^[^.@][^@]*@[^@]*$`)
+
+// Cache manages external modules downloaded to the cache directory. Downloaded modules are recorded in
+// the manifest file.
 type Cache struct {
-	path string
+	path                 string
+	ttl                  time.Duration
+	offline              bool
 	regexpExternalModule *regexp.Regexp
-	regexpVersion *regexp.Regexp
-	regexpGit *regexp.Regexp
-	downloaded map[string]struct{}
+	regexpVersion        *regexp.Regexp
+	regexpExactVersion   *regexp.Regexp
+	regexpCommit         *regexp.Regexp
+	regexpGit            *regexp.Regexp
+	downloaded           map[string]struct{}
+	manifest             *CacheManifest
+	gitBackend           GitBackend
 }
 
-func NewCache(path string) *Cache {
+// NewCache returns a Cache instance with manifest read from the cache directory. Modules that are not pinned to
+// an exact version are re-fetched when their manifest entry is older than ttl (0 means always). When offline is
+// true, nothing is fetched and missing modules result in an error. Modules are fetched with gitBackend, or 'git'
+// commands when it is nil.
+func NewCache(path string, ttl time.Duration, offline bool, gitBackend GitBackend) *Cache {
+	if gitBackend == nil {
+		gitBackend = &ExecGitBackend{}
+	}
+
 	cache := &Cache{
-		path: path,
+		path:                 path,
+		ttl:                  ttl,
+		offline:              offline,
 		regexpExternalModule: regexp.MustCompile(`^[a-z]+.*$`),
-		regexpVersion: regexp.MustCompile(`^[a-z0-9\.\-_]*$`),
-		regexpGit: regexp.MustCompile(`^git::.*$`),
-		downloaded: map[string]struct{}{},
+		regexpVersion:        regexp.MustCompile(`^[a-z0-9\.\-_]*$`),
+		regexpExactVersion:   regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[a-z0-9\.\-]+)?$`),
+		regexpCommit:         regexp.MustCompile(`^[0-9a-f]{40}$`),
+		regexpGit:            regexp.MustCompile(`^git::.*$`),
+		downloaded:           map[string]struct{}{},
+		manifest:             NewCacheManifest(),
+		gitBackend:           gitBackend,
+	}
+
+	err := cache.manifest.ReadFromFile(cache.manifestPath())
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error reading cache manifest, starting with an empty one: %s", err.Error()))
+
+		cache.manifest = NewCacheManifest()
 	}
 
 	return cache
 }
 
+// Path returns the cache directory.
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Offline returns true when the cache does not fetch anything.
+func (c *Cache) Offline() bool {
+	return c.offline
+}
+
+// Manifest returns the cache manifest.
+func (c *Cache) Manifest() *CacheManifest {
+	return c.manifest
+}
+
 func (c *Cache) WasDownloaded(sourceVersion string) bool {
 	_, exists := c.downloaded[sourceVersion]
 
@@ -89,6 +137,42 @@ func (c *Cache) DownloadModule(sourceVersion string, overrideUrl string) (string
 		source = strings.Replace(sourceVersion, "@", "", 1)
 	}
 
+	moduleDirName := strings.ReplaceAll(source+"@"+version, "/", "__")
+	moduleDirPath := filepath.Join(c.path, moduleDirName)
+
+	if c.isCachedModuleReusable(sourceVersion, version, moduleDirPath, overrideUrl) {
+		slog.Debug(
+			fmt.Sprintf(
+				"🔸 Reusing cached module 📦%s@%s from 📁%s without fetching",
+				source,
+				version,
+				moduleDirPath,
+			),
+		)
+
+		return moduleDirPath, nil
+	}
+
+	if c.offline {
+		dirStat, err := os.Stat(moduleDirPath)
+		if err == nil && dirStat.IsDir() {
+			slog.Warn(
+				fmt.Sprintf(
+					"🔸 Using cached module directory 📁%s for 📦%s@%s that is not in the manifest (offline)",
+					moduleDirPath,
+					source,
+					version,
+				),
+			)
+
+			return moduleDirPath, nil
+		}
+
+		slog.Error(fmt.Sprintf("🚫 Module 📦%s@%s not found in cache 📁%s (offline)", source, version, c.path))
+
+		return "", fmt.Errorf("%w: %s", ErrModuleNotCachedOffline, sourceVersion)
+	}
+
 	slog.Debug(
 		fmt.Sprintf(
 			"🌍 Trying to download module 📦%s@%s",
@@ -140,15 +224,11 @@ func (c *Cache) DownloadModule(sourceVersion string, overrideUrl string) (string
 		return "", nil
 	}
 
-	gitUrl := strings.Replace(sourceGitRepository, "git::", "", 1)
-
-	split = strings.SplitN(gitUrl, "?", 2)
-	if len(split) != 2 {
+	gitUrl, gitCommit, ok := c.splitGitSource(sourceGitRepository)
+	if !ok {
 		return "", nil
 	}
 
-	gitUrl = split[0]
-	gitCommit := strings.Replace(split[1], "ref=", "", 1)
 	slog.Debug(
 		fmt.Sprintf(
 			"🌎 Cloning module 📦%s repository %s commit %s",
@@ -158,9 +238,6 @@ func (c *Cache) DownloadModule(sourceVersion string, overrideUrl string) (string
 		),
 	)
 
-	moduleDirName := strings.ReplaceAll(source+"@"+version, "/", "__")
-	moduleDirPath := filepath.Join(c.path, moduleDirName)
-
 	var nextStepGitClone bool
 	// Check if module directory already exists
 	dirStat, err := os.Stat(moduleDirPath)
@@ -190,39 +267,281 @@ func (c *Cache) DownloadModule(sourceVersion string, overrideUrl string) (string
 		slog.Debug(fmt.Sprintf("🔸 Found cached module directory for 📦%s@%s at 📁%s", source, version, moduleDirPath))
 	}
 
-	cmdName := "git"
+	resolvedCommit, err := c.gitBackend.Checkout(gitUrl, gitCommit, moduleDirPath, nextStepGitClone)
+	if err != nil {
+		if nextStepGitClone {
+			// remove the directory so that next run tries to clone again
+			_ = os.RemoveAll(moduleDirPath)
+		}
+
+		return "", err
+	}
+
+	slog.Info(fmt.Sprintf("🔸 Changed ref for cached module 📦%s@%s in 📁%s to %s", source, version, moduleDirPath, gitCommit))
+
+	c.addManifestEntry(sourceVersion, moduleDirName, gitUrl, gitCommit, resolvedCommit)
+
+	return moduleDirPath, nil
+}
+
+// Prune removes manifest entries fetched earlier than olderThan ago (0 means all entries are kept) and entries
+// whose directories are missing. Directories in the cache that are not in the manifest, eg. put there by hand and
+// still used with offline mode, are removed only when unmanifested is true. It returns a list of removed module
+// directories.
+func (c *Cache) Prune(olderThan time.Duration, unmanifested bool) ([]string, error) {
+	removed := []string{}
+	inManifest := map[string]struct{}{}
+
+	err := c.checkManifestExists()
+	if err != nil {
+		return removed, err
+	}
+
+	for _, entryKey := range c.manifest.EntryNamesSorted() {
+		entry := c.manifest.Entries[entryKey]
+		entryDirPath := filepath.Join(c.path, entry.Dir)
+
+		_, err := os.Stat(entryDirPath)
+		dirMissing := err != nil && os.IsNotExist(err)
+		expired := olderThan > 0 && time.Since(entry.FetchedAt) > olderThan
 
-	if nextStepGitClone {
-		cmdArgs := []string{"clone", gitUrl, moduleDirPath}
+		if !dirMissing && !expired {
+			inManifest[entry.Dir] = struct{}{}
 
-		ctx, cancel := context.WithTimeout(context.Background(), gitCloneTimeout*time.Second)
-		defer cancel()
-		cmd := exec.CommandContext(ctx, cmdName, cmdArgs...)
-		if err := cmd.Run(); err != nil {
-			slog.Error(fmt.Sprintf("🚫 Command '%s %s' failed: %s", cmdName, strings.Join(cmdArgs, " "), err.Error()))
+			continue
+		}
 
-			return "", fmt.Errorf("%w: %w", ErrGitCloneFailed, err)
+		if !dirMissing {
+			err := os.RemoveAll(entryDirPath)
+			if err != nil {
+				return removed, fmt.Errorf("%w: %w", ErrRemovingCacheEntryDir, err)
+			}
 		}
+
+		delete(c.manifest.Entries, entryKey)
+
+		removed = append(removed, entry.Dir)
 	}
 
-	cmdArgsMatrix := [][]string{
-		{"fetch", "--all"},
-		{"checkout", gitCommit},
+	if unmanifested {
+		removedUnmanifested, err := c.pruneUnmanifested(inManifest)
+		removed = append(removed, removedUnmanifested...)
+
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	err = c.manifest.WriteToFile(c.manifestPath())
+	if err != nil {
+		return removed, err
 	}
 
-	for _, cmdArgs := range cmdArgsMatrix {
-		ctx, cancel := context.WithTimeout(context.Background(), gitCloneTimeout*time.Second)
-		defer cancel()
-		cmd := exec.CommandContext(ctx, cmdName, cmdArgs...)
-		cmd.Dir = moduleDirPath
-		if err := cmd.Run(); err != nil {
-			slog.Error(fmt.Sprintf("🚫 Command '%s %s' failed in 📁%s: %s", cmdName, strings.Join(cmdArgs, " "), moduleDirPath, err.Error()))
+	return removed, nil
+}
+
+// pruneUnmanifested removes module directories in the cache that are not in the manifest and returns their names.
+func (c *Cache) pruneUnmanifested(inManifest map[string]struct{}) ([]string, error) {
+	removed := []string{}
 
-			return "", fmt.Errorf("%w: %w", ErrGitCheckoutFailed, err)
+	dirEntries, err := os.ReadDir(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return removed, fmt.Errorf("%w: %w", ErrReadingCacheDirEntries, err)
+	}
+
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
 		}
+
+		_, exists := inManifest[dirEntry.Name()]
+		if exists || !regexpModuleDirName.MatchString(dirEntry.Name()) {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(c.path, dirEntry.Name()))
+		if err != nil {
+			return removed, fmt.Errorf("%w: %w", ErrRemovingCacheEntryDir, err)
+		}
+
+		removed = append(removed, dirEntry.Name())
 	}
 
-	slog.Info(fmt.Sprintf("🔸 Changed ref for cached module 📦%s@%s in 📁%s to %s", source, version, moduleDirPath, gitCommit))
+	return removed, nil
+}
 
-	return moduleDirPath, nil
+// Verify checks that module directories of all manifest entries exist and their checksums match.
+// It returns a map of module names to errors for entries that failed the verification.
+func (c *Cache) Verify() map[string]error {
+	failed := map[string]error{}
+
+	for _, entryKey := range c.manifest.EntryNamesSorted() {
+		entry := c.manifest.Entries[entryKey]
+		entryDirPath := filepath.Join(c.path, entry.Dir)
+
+		dirStat, err := os.Stat(entryDirPath)
+		if err != nil || !dirStat.IsDir() {
+			failed[entryKey] = fmt.Errorf("%w: %s", ErrCacheEntryDirMissing, entryDirPath)
+
+			continue
+		}
+
+		checksum, err := dirChecksum(entryDirPath)
+		if err != nil {
+			failed[entryKey] = err
+
+			continue
+		}
+
+		if checksum != entry.Checksum {
+			failed[entryKey] = fmt.Errorf(
+				"%w: expected %s, got %s",
+				ErrCacheChecksumMismatch,
+				entry.Checksum,
+				checksum,
+			)
+		}
+	}
+
+	return failed
+}
+
+// Clear removes all cached modules and the manifest. Only directories of manifest entries, and directories named
+// as modules in the cache are removed, and nothing is removed when the directory has no manifest.
+func (c *Cache) Clear() ([]string, error) {
+	removed := []string{}
+
+	_, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		return removed, nil
+	}
+
+	err = c.checkManifestExists()
+	if err != nil {
+		return removed, err
+	}
+
+	entryDirs := map[string]struct{}{}
+	for _, entry := range c.manifest.Entries {
+		entryDirs[entry.Dir] = struct{}{}
+	}
+
+	dirEntries, err := os.ReadDir(c.path)
+	if err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrReadingCacheDirEntries, err)
+	}
+
+	for _, dirEntry := range dirEntries {
+		_, isEntryDir := entryDirs[dirEntry.Name()]
+		if !dirEntry.IsDir() || (!isEntryDir && !regexpModuleDirName.MatchString(dirEntry.Name())) {
+			continue
+		}
+
+		err := os.RemoveAll(filepath.Join(c.path, dirEntry.Name()))
+		if err != nil {
+			return removed, fmt.Errorf("%w: %w", ErrRemovingCacheEntryDir, err)
+		}
+
+		removed = append(removed, dirEntry.Name())
+	}
+
+	c.manifest = NewCacheManifest()
+
+	err = os.Remove(c.manifestPath())
+	if err != nil && !os.IsNotExist(err) {
+		return removed, fmt.Errorf("%w: %w", ErrWritingCacheManifest, err)
+	}
+
+	return removed, nil
+}
+
+// checkManifestExists returns ErrNotCacheDir when there is no manifest in the cache directory, so that modules are
+// not removed from a directory that is not a cache, eg. a repository passed by mistake.
+func (c *Cache) checkManifestExists() error {
+	_, err := os.Stat(c.manifestPath())
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotCacheDir, c.path)
+	}
+
+	return nil
+}
+
+func (c *Cache) manifestPath() string {
+	return filepath.Join(c.path, cacheManifestFileName)
+}
+
+// isCachedModuleReusable checks if module can be taken from the cache without any git calls. That is when it is
+// in the manifest, its directory exists, the override URL (if any) has not changed, and it is either pinned to
+// an exact version or commit, or its manifest entry has not expired yet.
+func (c *Cache) isCachedModuleReusable(sourceVersion, version, moduleDirPath, overrideUrl string) bool {
+	entry, exists := c.manifest.Entries[sourceVersion]
+	if !exists {
+		return false
+	}
+
+	dirStat, err := os.Stat(moduleDirPath)
+	if err != nil || !dirStat.IsDir() {
+		return false
+	}
+
+	if overrideUrl != "" {
+		gitUrl, gitCommit, ok := c.splitGitSource(overrideUrl)
+		if !ok || gitUrl != entry.SourceURL || gitCommit != entry.Ref {
+			return false
+		}
+	}
+
+	if c.regexpExactVersion.MatchString(version) ||
+		c.regexpExactVersion.MatchString(entry.Ref) ||
+		c.regexpCommit.MatchString(entry.Ref) {
+		return true
+	}
+
+	if c.offline {
+		return true
+	}
+
+	return c.ttl > 0 && time.Since(entry.FetchedAt) < c.ttl
+}
+
+// splitGitSource takes 'git::URL?ref=REF' source and returns URL and REF from it.
+func (c *Cache) splitGitSource(sourceGitRepository string) (string, string, bool) {
+	gitUrl := strings.Replace(sourceGitRepository, "git::", "", 1)
+
+	split := strings.SplitN(gitUrl, "?", 2)
+	if len(split) != 2 {
+		return "", "", false
+	}
+
+	return split[0], strings.Replace(split[1], "ref=", "", 1), true
+}
+
+func (c *Cache) addManifestEntry(sourceVersion, moduleDirName, gitUrl, gitRef, resolvedCommit string) {
+	moduleDirPath := filepath.Join(c.path, moduleDirName)
+
+	size, err := dirSize(moduleDirPath)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error getting size of cached module 📁%s: %s", moduleDirPath, err.Error()))
+	}
+
+	checksum, err := dirChecksum(moduleDirPath)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error getting checksum of cached module 📁%s: %s", moduleDirPath, err.Error()))
+	}
+
+	c.manifest.Entries[sourceVersion] = &CacheManifestEntry{
+		Module:    sourceVersion,
+		Dir:       moduleDirName,
+		SourceURL: gitUrl,
+		Ref:       gitRef,
+		Commit:    resolvedCommit,
+		FetchedAt: time.Now().UTC(),
+		Size:      size,
+		Checksum:  checksum,
+	}
+
+	err = c.manifest.WriteToFile(c.manifestPath())
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error writing cache manifest: %s", err.Error()))
+	}
 }
@@ -0,0 +1,207 @@
+package tfpath
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrReadingCacheManifest   = errors.New("error reading cache manifest")
+	ErrWritingCacheManifest   = errors.New("error writing cache manifest")
+	ErrCalculatingModuleSize  = errors.New("error calculating module directory size")
+	ErrCalculatingChecksum    = errors.New("error calculating module checksum")
+	ErrCacheEntryDirMissing   = errors.New("cached module directory is missing")
+	ErrCacheChecksumMismatch  = errors.New("cached module checksum mismatch")
+	ErrRemovingCacheEntryDir  = errors.New("error removing cached module directory")
+	ErrReadingCacheDirEntries = errors.New("error reading cache directory entries")
+	ErrNotCacheDir            = errors.New("directory has no cache manifest, so it is not a cache")
+)
+
+const (
+	cacheManifestFileName = "manifest.json"
+	cacheManifestFileMode = 0o600
+	gitDirName            = ".git"
+)
+
+// CacheManifest represents a file in the cache directory that describes all the modules that were downloaded.
+type CacheManifest struct {
+	Entries map[string]*CacheManifestEntry `json:"entries"`
+}
+
+// CacheManifestEntry describes a single module downloaded to the cache directory.
+type CacheManifestEntry struct {
+	// Module is the module source and version, as in the container path key, eg. 'source@version'.
+	Module string `json:"module"`
+	// Dir is the name of the module directory inside the cache directory.
+	Dir string `json:"dir"`
+	// SourceURL is the git repository URL the module was cloned from.
+	SourceURL string `json:"sourceUrl"`
+	// Ref is the git ref that was checked out.
+	Ref string `json:"ref"`
+	// Commit is the commit the ref resolved to.
+	Commit string `json:"commit"`
+	// FetchedAt is the time when the module was fetched.
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Size is the size of the module directory in bytes.
+	Size int64 `json:"size"`
+	// Checksum is a sha256 checksum of the module files, excluding the '.git' directory.
+	Checksum string `json:"checksum"`
+}
+
+// NewCacheManifest returns an empty CacheManifest instance.
+func NewCacheManifest() *CacheManifest {
+	return &CacheManifest{
+		Entries: map[string]*CacheManifestEntry{},
+	}
+}
+
+// ReadFromFile reads manifest entries from a file. Missing file is not an error.
+func (m *CacheManifest) ReadFromFile(path string) error {
+	fileContents, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %w", ErrReadingCacheManifest, err)
+	}
+
+	err = json.Unmarshal(fileContents, m)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadingCacheManifest, err)
+	}
+
+	if m.Entries == nil {
+		m.Entries = map[string]*CacheManifestEntry{}
+	}
+
+	return nil
+}
+
+// WriteToFile writes manifest entries to a file.
+func (m *CacheManifest) WriteToFile(path string) error {
+	manifestBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWritingCacheManifest, err)
+	}
+
+	err = os.WriteFile(filepath.Clean(path), manifestBytes, cacheManifestFileMode)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWritingCacheManifest, err)
+	}
+
+	return nil
+}
+
+// EntryNamesSorted returns a list of manifest entry names sorted alphabetically.
+func (m *CacheManifest) EntryNamesSorted() []string {
+	namesSorted := make([]string, 0, len(m.Entries))
+	for entryKey := range m.Entries {
+		namesSorted = append(namesSorted, entryKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// ModuleVersions returns versions of the module source that are in the manifest.
+func (m *CacheManifest) ModuleVersions(source string) []string {
+	versions := []string{}
+
+	for _, entryKey := range m.EntryNamesSorted() {
+		entrySource, entryVersion, _ := strings.Cut(m.Entries[entryKey].Module, "@")
+		if entrySource == source && entryVersion != "" {
+			versions = append(versions, entryVersion)
+		}
+	}
+
+	return versions
+}
+
+// dirSize returns total size of files in a directory, including the '.git' directory.
+func dirSize(path string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(path, func(_ string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if dirEntry.IsDir() {
+			return nil
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrCalculatingModuleSize, err)
+	}
+
+	return size, nil
+}
+
+// dirChecksum returns a sha256 checksum of relative file paths and their contents in a directory, skipping
+// the '.git' directory.
+func dirChecksum(path string) (string, error) {
+	hash := sha256.New()
+
+	// filepath.WalkDir walks files in lexical order so the checksum is stable
+	err := filepath.WalkDir(path, func(currentPath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if dirEntry.IsDir() {
+			if dirEntry.Name() == gitDirName {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if !dirEntry.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(path, currentPath)
+		if err != nil {
+			return err
+		}
+
+		_, _ = hash.Write([]byte(filepath.ToSlash(relPath) + "\x00"))
+
+		file, err := os.Open(filepath.Clean(currentPath))
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		_, err = io.Copy(hash, file)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrCalculatingChecksum, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
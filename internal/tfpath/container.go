@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"tfsketch/internal/overrides"
+	"tfsketch/internal/remotetolocal"
 )
 
 var (
@@ -17,16 +21,36 @@ var (
 )
 
 const (
-	parsePathMaxDepth = 6
+	// DefaultMaxParseDepth is the default number of rounds of parsing paths and adding external modules found in
+	// them, so it is how deep external modules calling other external modules are followed.
+	DefaultMaxParseDepth = 6
 )
 
-// Container contains TfPath instances for external modules and the root of the local path that is scanned.
+// Container contains TfPath instances for external modules and the roots of the local paths that are scanned.
 type Container struct {
 	// Paths contains mapping of module or path to an TfPath object
 	Paths map[string]*TfPath
 
+	// Roots contains names of the scanned paths in Paths, "." when there is only one
+	Roots map[string]struct{}
+
+	// MaxParseDepth limits how deep external modules are followed, paths found deeper are marked as truncated
+	MaxParseDepth int
+
 	// Overrides contains regular expressions against which module or path can be matched and have its local path assigned
 	Overrides map[string]*Override
+
+	// Mirror is a pre-seeded directory with modules that is consulted before any registry or git access
+	Mirror *Mirror
+
+	// MissingModules contains external modules that could not be found locally, in the mirror or downloaded
+	MissingModules map[string]struct{}
+
+	// Ignored contains external modules that are deliberately skipped
+	Ignored map[string]struct{}
+
+	// Resolutions contains how external modules were found, or why they were not
+	Resolutions map[string]*ModuleResolution
 }
 
 // Override represents remote-to-local mapping
@@ -40,18 +64,48 @@ type Override struct {
 	// Cache is a source URL for the module that it needs to be downloaded from. It takes
 	// precedence before Local.
 	Cache string
+
+	// Subdir is a path inside the local or downloaded directory where the module code is.
+	Subdir string
+
+	// Ref replaces the git ref in Cache.
+	Ref string
+
+	// Ignore marks matching modules as deliberately skipped.
+	Ignore bool
+
+	// Label is a name the module is displayed with in the chart.
+	Label string
 }
 
 // NewContainer returns a new Container.
 func NewContainer() *Container {
 	container := &Container{
-		Paths:     map[string]*TfPath{},
-		Overrides: map[string]*Override{},
+		Paths:          map[string]*TfPath{},
+		Roots:          map[string]struct{}{},
+		MaxParseDepth:  DefaultMaxParseDepth,
+		Overrides:      map[string]*Override{},
+		MissingModules: map[string]struct{}{},
+		Ignored:        map[string]struct{}{},
+		Resolutions:    map[string]*ModuleResolution{},
 	}
 
 	return container
 }
 
+// AddRootPath adds a new TfPath to the container (scanned path).
+func (c *Container) AddRootPath(name string, tfPath *TfPath) {
+	c.Roots[name] = struct{}{}
+	c.AddPath(name, tfPath)
+}
+
+// IsRoot checks if the container path is a scanned path rather than an external module.
+func (c *Container) IsRoot(name string) bool {
+	_, isRoot := c.Roots[name]
+
+	return isRoot
+}
+
 // AddPath adds a new TfPath to the container (external module).
 func (c *Container) AddPath(name string, tfPath *TfPath) {
 	c.Paths[name] = tfPath
@@ -65,9 +119,14 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 		remoteField := strings.TrimSpace(externalModule.Remote)
 		localField := strings.TrimSpace(externalModule.Local)
 		cacheField := strings.TrimSpace(externalModule.Cache)
+		subdirField := strings.TrimSpace(externalModule.Subdir)
+
+		if remoteField == "" {
+			continue
+		}
 
-		// Either local or cache must be present
-		if remoteField == "" || (localField == "" && cacheField == "") {
+		// Either local or cache must be present, unless module is ignored
+		if !externalModule.Ignore && localField == "" && cacheField == "" {
 			continue
 		}
 
@@ -79,16 +138,54 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 				continue
 			}
 
+			remoteRegexp, err := regexp.Compile(remoteField)
+			if err != nil {
+				slog.Error(
+					fmt.Sprintf(
+						"❌ Skipped invalid override regexp 📦%s: %s",
+						remoteField,
+						err.Error(),
+					),
+				)
+
+				continue
+			}
+
 			// add these regular expressions to the container
 			c.Overrides[remoteField] = &Override{
-				Remote: regexp.MustCompile(remoteField),
+				Remote: remoteRegexp,
 				Local:  localField,
 				Cache:  cacheField,
+				Subdir: subdirField,
+				Ref:    strings.TrimSpace(externalModule.Ref),
+				Ignore: externalModule.Ignore,
+				Label:  externalModule.Label,
 			}
 
 			continue
 		}
 
+		if externalModule.Ignore {
+			c.Ignored[remoteField] = struct{}{}
+
+			slog.Info(fmt.Sprintf("🔸 Module ignored: 📦%s", remoteField))
+
+			continue
+		}
+
+		cacheField = applyGitRef(cacheField, strings.TrimSpace(externalModule.Ref))
+
+		// Module from the mirror takes precedence over downloading it
+		if cacheField != "" && c.Mirror != nil {
+			mirrorPath := c.Mirror.FindModule(remoteField)
+			if mirrorPath != "" {
+				localField = mirrorPath
+				cacheField = ""
+
+				c.setResolution(remoteField, ResolutionMirror, mirrorPath)
+			}
+		}
+
 		// If cache is passed then we need to try to download the module first and then pass its local path
 		if cacheField != "" && cache != nil {
 			// If there was an attempt to download this module already then try to get it
@@ -100,6 +197,8 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 				}
 
 				localField = localTfPath.Path
+				// path in the container already points to the sub-directory
+				subdirField = ""
 			} else {
 				// Download the module source code and save it in the cache
 				downloadedPath, err := cache.DownloadModule(remoteField, cacheField)
@@ -112,14 +211,25 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 						),
 					)
 
+					c.setResolutionFailure(remoteField, "error downloading module: "+err.Error())
+
+					// In offline mode, a missing module means the diagram would be incomplete
+					if errors.Is(err, ErrModuleNotCachedOffline) {
+						return fmt.Errorf("%w: %w", ErrWalkingOverrides, err)
+					}
+
 					continue
 				}
 
 				if downloadedPath == "" {
+					c.setResolutionFailure(remoteField, "module could not be downloaded from "+cacheField)
+
 					continue
 				}
 
 				localField = downloadedPath
+
+				c.setResolution(remoteField, ResolutionCache, downloadedPath)
 			}
 		}
 
@@ -127,9 +237,16 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 			continue
 		}
 
+		if subdirField != "" {
+			localField = filepath.Join(localField, subdirField)
+		}
+
 		tfPath := NewTfPath(localField, remoteField)
+		tfPath.Label = externalModule.Label
 		c.AddPath(tfPath.TraverseName, tfPath)
 
+		c.setResolution(remoteField, ResolutionOverride, localField)
+
 		isSubModule := c.isExternalModuleASubModule(remoteField)
 
 		if tfPath.Walked {
@@ -156,7 +273,24 @@ func (c *Container) WalkOverrides(overrides *overrides.Overrides, traverser *Tra
 // ParsePaths runs traverser's ParsePath on each path
 func (c *Container) ParsePaths(traverser *Traverser, cache *Cache, depth int) error {
 	// Limit number of recursive calls
-	if depth == parsePathMaxDepth {
+	if depth >= c.MaxParseDepth {
+		for _, pathName := range c.PathNamesSorted() {
+			tfPath := c.Paths[pathName]
+			if tfPath.Parsed {
+				continue
+			}
+
+			tfPath.Truncated = true
+
+			slog.Warn(
+				fmt.Sprintf(
+					"❗ Module 📦%s is not parsed as parse depth limit %d is reached",
+					pathName,
+					c.MaxParseDepth,
+				),
+			)
+		}
+
 		return nil
 	}
 
@@ -182,7 +316,15 @@ func (c *Container) ParsePaths(traverser *Traverser, cache *Cache, depth int) er
 		}
 	}
 
-	if cache != nil && len(foundModules) > 0 {
+	return c.addFoundModules(traverser, cache, foundModules, depth)
+}
+
+// addFoundModules finds external modules that are not in the container yet, in overrides, mirror or cache, and
+// then parses them.
+//
+//nolint:funlen,gocognit
+func (c *Container) addFoundModules(traverser *Traverser, cache *Cache, foundModules []string, depth int) error {
+	if len(foundModules) > 0 {
 		overrides := &overrides.Overrides{}
 
 		for _, containerPathKey := range foundModules {
@@ -191,15 +333,65 @@ func (c *Container) ParsePaths(traverser *Traverser, cache *Cache, depth int) er
 				continue
 			}
 
-			if cache.WasDownloaded(containerPathKey) {
+			// local modules are linked to paths in the parent
+			if strings.HasPrefix(containerPathKey, ".") {
+				continue
+			}
+
+			if cache != nil && cache.WasDownloaded(containerPathKey) {
+				continue
+			}
+
+			if c.IsIgnored(containerPathKey) {
+				slog.Debug(fmt.Sprintf("🚫 Skipped ignored module 📦%s", containerPathKey))
+
 				continue
 			}
 
-			var localPath, cacheUrl string
+			resolvedEntry := &remotetolocal.RemoteToLocal{Remote: containerPathKey}
+
+			override := c.ResolveOverride(containerPathKey)
+			if override != nil {
+				resolvedEntry.Subdir = override.Subdir
+				resolvedEntry.Label = override.Label
+
+				if override.Local != "" && override.Cache == "" {
+					// local overrides found with regular expressions are applied to modules called by other modules
+					// only when cache is set, the same as before the mirror was added
+					if cache == nil {
+						c.addMissingModule(containerPathKey, "overrides with regular expressions need cache to be set")
+
+						continue
+					}
+
+					resolvedEntry.Local = override.Local
+					overrides.AddExternalModuleEntry(resolvedEntry)
+
+					c.setResolution(containerPathKey, ResolutionOverrideRegexp, override.Local)
+
+					continue
+				}
+			}
+
+			if c.Mirror != nil {
+				mirrorPath := c.Mirror.FindModule(containerPathKey)
+				if mirrorPath != "" {
+					resolvedEntry.Local = mirrorPath
+					overrides.AddExternalModuleEntry(resolvedEntry)
+
+					c.setResolution(containerPathKey, ResolutionMirror, mirrorPath)
+
+					continue
+				}
+			}
+
+			cacheUrl := ""
+			if override != nil {
+				cacheUrl = override.Cache
+			}
 
-			localPath, cacheUrl = c.MatchesOverride(containerPathKey)
-			if localPath != "" {
-				overrides.AddExternalModule(containerPathKey, localPath)
+			if cache == nil {
+				c.addMissingModule(containerPathKey, "module not found in overrides or mirror, and cache is not set")
 				continue
 			}
 
@@ -213,14 +405,29 @@ func (c *Container) ParsePaths(traverser *Traverser, cache *Cache, depth int) er
 					),
 				)
 
+				c.addMissingModule(containerPathKey, "error downloading module: "+err.Error())
+
+				// In offline mode, a missing module means the diagram would be incomplete
+				if errors.Is(err, ErrModuleNotCachedOffline) {
+					return fmt.Errorf("%w: %w", ErrParsingContainerPaths, err)
+				}
+
 				continue
 			}
 
 			if downloadedPath == "" {
+				c.addMissingModule(containerPathKey, "module could not be downloaded")
 				continue
 			}
 
-			overrides.AddExternalModule(containerPathKey, downloadedPath)
+			resolvedEntry.Local = downloadedPath
+			overrides.AddExternalModuleEntry(resolvedEntry)
+
+			if cacheUrl != "" {
+				c.setResolution(containerPathKey, ResolutionCache, downloadedPath)
+			} else {
+				c.setResolution(containerPathKey, ResolutionRegistry, downloadedPath)
+			}
 		}
 
 		if len(overrides.ExternalModules) > 0 {
@@ -260,40 +467,179 @@ func (c *Container) LinkPaths(traverser *Traverser) error {
 	return nil
 }
 
-// MatchesOverride checks if specific module/path is found in overrides.
+// ReparseDirs parses files in dirs again, where each dir is a container path or one of its sub-directories. New
+// sub-directories are walked, removed ones are dropped, external modules found in the new code are added, and all
+// the paths are linked again. It returns dirs that are not in the container.
+//
+//nolint:gocognit
+func (c *Container) ReparseDirs(traverser *Traverser, cache *Cache, dirs []string) ([]string, error) {
+	foundModules := []string{}
+	unknownDirs := []string{}
+
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		found := false
+
+		for _, tfPath := range c.Paths {
+			if filepath.Clean(tfPath.Path) == dir {
+				found = true
+
+				c.reparseTfPath(traverser, tfPath, &foundModules)
+			}
+
+			for childKey, childTfPath := range tfPath.Children {
+				if filepath.Clean(childTfPath.Path) != dir {
+					continue
+				}
+
+				found = true
+
+				_, err := os.Stat(childTfPath.Path)
+				if os.IsNotExist(err) {
+					slog.Info(fmt.Sprintf("🔄 Removed child terraform path 📁%s", childTfPath.Path))
+					delete(tfPath.Children, childKey)
+
+					continue
+				}
+
+				c.reparseTfPath(traverser, childTfPath, &foundModules)
+			}
+
+			// a new sub-directory needs the container path to be walked again
+			if !found && strings.HasPrefix(dir, filepath.Clean(tfPath.Path)+string(filepath.Separator)) {
+				found = true
+
+				err := c.walkNewChildren(traverser, tfPath, &foundModules)
+				if err != nil {
+					return unknownDirs, err
+				}
+			}
+		}
+
+		if !found {
+			unknownDirs = append(unknownDirs, dir)
+		}
+	}
+
+	err := c.addFoundModules(traverser, cache, foundModules, 1)
+	if err != nil {
+		return unknownDirs, err
+	}
+
+	return unknownDirs, c.LinkPaths(traverser)
+}
+
+func (c *Container) reparseTfPath(traverser *Traverser, tfPath *TfPath, foundModules *[]string) {
+	tfPath.ResetParsed()
+
+	err := traverser.parseFiles(tfPath, foundModules)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error parsing terraform path 📁%s: %s", tfPath.Path, err.Error()))
+	}
+
+	tfPath.Parsed = true
+}
+
+// walkNewChildren walks the container path again and parses sub-directories that were not there before.
+func (c *Container) walkNewChildren(traverser *Traverser, tfPath *TfPath, foundModules *[]string) error {
+	existingChildren := map[string]struct{}{}
+	for childKey := range tfPath.Children {
+		existingChildren[childKey] = struct{}{}
+	}
+
+	err := traverser.WalkPath(tfPath, false)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrParsingContainerPaths, err)
+	}
+
+	for childKey, childTfPath := range tfPath.Children {
+		_, exists := existingChildren[childKey]
+		if exists {
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("🔄 Added child terraform path 📁%s", childTfPath.Path))
+		c.reparseTfPath(traverser, childTfPath, foundModules)
+	}
+
+	return nil
+}
+
+// MatchesOverride checks if specific module/path is found in overrides. It returns either a local path or a cache
+// URL for the module.
 func (c *Container) MatchesOverride(containerPathKey string) (string, string) {
+	override := c.ResolveOverride(containerPathKey)
+	if override == nil || override.Ignore {
+		return "", ""
+	}
+
+	if override.Cache != "" {
+		return "", override.Cache
+	}
+
+	return override.Local, ""
+}
+
+// ResolveOverride returns override regexp entry that the module/path matches, with captured groups interpolated
+// in its fields, or nil when there is no match.
+//
+//nolint:funlen
+func (c *Container) ResolveOverride(containerPathKey string) *Override {
 	for regexpString, override := range c.Overrides {
 		matches := override.Remote.FindStringSubmatch(containerPathKey)
 		if len(matches) == 0 {
 			continue
 		}
 
-		localPath := override.Local
-		cacheUrl := override.Cache
+		resolved := &Override{
+			Remote: override.Remote,
+			Local:  override.Local,
+			Cache:  override.Cache,
+			Subdir: override.Subdir,
+			Ref:    override.Ref,
+			Ignore: override.Ignore,
+			Label:  override.Label,
+		}
 
-		if cacheUrl != "" {
-			for i, sub := range matches {
-				cacheUrl = strings.ReplaceAll(cacheUrl, fmt.Sprintf("{%d}", i), sub)
-			}
+		for i, sub := range matches {
+			placeholder := fmt.Sprintf("{%d}", i)
 
+			resolved.Local = strings.ReplaceAll(resolved.Local, placeholder, sub)
+			resolved.Cache = strings.ReplaceAll(resolved.Cache, placeholder, sub)
+			resolved.Subdir = strings.ReplaceAll(resolved.Subdir, placeholder, sub)
+			resolved.Ref = strings.ReplaceAll(resolved.Ref, placeholder, sub)
+			resolved.Label = strings.ReplaceAll(resolved.Label, placeholder, sub)
+		}
+
+		resolved.Cache = applyGitRef(resolved.Cache, resolved.Ref)
+
+		if resolved.Ignore {
 			slog.Debug(
 				fmt.Sprintf(
-					"🧩 Module 📦%s matches override regexp 📦%s and points to source 📁%s",
+					"🧩 Module 📦%s matches override regexp 📦%s and is ignored",
 					containerPathKey,
 					regexpString,
-					cacheUrl,
 				),
 			)
 
-			return "", cacheUrl
+			return resolved
 		}
 
-		if localPath == "" {
-			return "", ""
+		if resolved.Cache != "" {
+			slog.Debug(
+				fmt.Sprintf(
+					"🧩 Module 📦%s matches override regexp 📦%s and points to source 📁%s",
+					containerPathKey,
+					regexpString,
+					resolved.Cache,
+				),
+			)
+
+			return resolved
 		}
 
-		for i, sub := range matches {
-			localPath = strings.ReplaceAll(localPath, fmt.Sprintf("{%d}", i), sub)
+		if resolved.Local == "" {
+			return nil
 		}
 
 		slog.Debug(
@@ -301,16 +647,154 @@ func (c *Container) MatchesOverride(containerPathKey string) (string, string) {
 				"🧩 Module 📦%s matches override regexp 📦%s and points to local path 📁%s",
 				containerPathKey,
 				regexpString,
-				localPath,
+				resolved.Local,
 			),
 		)
 
-		return localPath, ""
+		return resolved
 	}
 
-	return "", ""
+	return nil
+}
+
+// IsIgnored checks if module/path is deliberately skipped in overrides.
+func (c *Container) IsIgnored(containerPathKey string) bool {
+	_, exists := c.Ignored[containerPathKey]
+	if exists {
+		return true
+	}
+
+	override := c.ResolveOverride(containerPathKey)
+
+	return override != nil && override.Ignore
+}
+
+// MissingModuleNamesSorted returns a list of missing external modules sorted alphabetically.
+func (c *Container) MissingModuleNamesSorted() []string {
+	namesSorted := make([]string, 0, len(c.MissingModules))
+	for moduleKey := range c.MissingModules {
+		namesSorted = append(namesSorted, moduleKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// PathNamesSorted returns a list of container paths sorted alphabetically.
+func (c *Container) PathNamesSorted() []string {
+	namesSorted := make([]string, 0, len(c.Paths))
+	for pathKey := range c.Paths {
+		namesSorted = append(namesSorted, pathKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// moduleCycleState is the state of a path when module calls are followed to find cycles.
+type moduleCycleState int
+
+const (
+	// pathNotFollowed is a path whose module calls are not followed yet.
+	pathNotFollowed moduleCycleState = iota
+	// pathFollowing is a path on the way of module calls being followed, so a call to it closes a cycle.
+	pathFollowing
+	// pathFollowed is a path with all its module calls followed, and cycles reachable from it found.
+	pathFollowed
+)
+
+// ModuleCycles returns cycles of linked module calls found from the root paths and their sub-directories, each as
+// the directory it is found from and the calls, eg. 'network: module.a > module.b > module.a'. Modules are followed
+// to any depth, and every path is followed once, so each cycle is returned once, from the first directory it is
+// found from.
+func (c *Container) ModuleCycles() []string {
+	cycles := []string{}
+	states := map[*TfPath]moduleCycleState{}
+
+	var followModules func(tfPath *TfPath, location string, chain []string)
+
+	followModules = func(tfPath *TfPath, location string, chain []string) {
+		states[tfPath] = pathFollowing
+
+		for _, moduleKey := range tfPath.ModuleNamesSorted() {
+			module := tfPath.Modules[moduleKey]
+			if module.TfPath == nil {
+				continue
+			}
+
+			moduleChain := append(chain[:len(chain):len(chain)], "module."+module.Name)
+
+			switch states[module.TfPath] {
+			case pathFollowing:
+				cycles = append(cycles, location+": "+strings.Join(moduleChain, " > "))
+			case pathNotFollowed:
+				followModules(module.TfPath, location, moduleChain)
+			case pathFollowed:
+				// cycles reachable from the module are found already
+			}
+		}
+
+		states[tfPath] = pathFollowed
+	}
+
+	for _, rootName := range c.PathNamesSorted() {
+		if !c.IsRoot(rootName) {
+			continue
+		}
+
+		rootTfPath := c.Paths[rootName]
+
+		tfPaths := []*TfPath{rootTfPath}
+		for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+			tfPaths = append(tfPaths, rootTfPath.Children[childKey])
+		}
+
+		for _, tfPath := range tfPaths {
+			if tfPath == nil || states[tfPath] != pathNotFollowed {
+				continue
+			}
+
+			location := tfPath.RelPath
+			if location == "" {
+				location = "."
+			}
+
+			if len(c.Roots) > 1 {
+				location = rootName + ":" + location
+			}
+
+			followModules(tfPath, location, []string{})
+		}
+	}
+
+	return cycles
+}
+
+func (c *Container) addMissingModule(containerPathKey, reason string) {
+	_, exists := c.MissingModules[containerPathKey]
+	if exists {
+		return
+	}
+
+	c.MissingModules[containerPathKey] = struct{}{}
+	c.setResolutionFailure(containerPathKey, reason)
+
+	slog.Warn(fmt.Sprintf("❗ Module 📦%s could not be found locally, in the mirror or downloaded", containerPathKey))
 }
 
 func (c *Container) isExternalModuleASubModule(module string) bool {
 	return strings.Contains(module, "//modules/")
 }
+
+// applyGitRef replaces the ref in the 'git::URL?ref=REF' source.
+func applyGitRef(cacheUrl, ref string) string {
+	if cacheUrl == "" || ref == "" {
+		return cacheUrl
+	}
+
+	gitUrl, _, _ := strings.Cut(cacheUrl, "?")
+
+	return gitUrl + "?ref=" + ref
+}
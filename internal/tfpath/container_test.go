@@ -0,0 +1,115 @@
+package tfpath_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"tfsketch/internal/tfpath"
+)
+
+// linkModules writes files to a temporary directory and returns the container with it parsed and linked as the root.
+func linkModules(t *testing.T, files map[string]string) *tfpath.Container {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+
+		err := os.MkdirAll(filepath.Dir(filePath), 0o755)
+		if err != nil {
+			t.Fatalf("error creating directory for %s: %s", name, err)
+		}
+
+		err = os.WriteFile(filePath, []byte(content), 0o600)
+		if err != nil {
+			t.Fatalf("error writing %s: %s", name, err)
+		}
+	}
+
+	container := tfpath.NewContainer()
+	traverser := tfpath.NewTraverser(container, "^.*$", "^SillyName$", "^.*$", "^.*$", "", nil)
+
+	rootTfPath := tfpath.NewTfPath(dir, ".")
+	container.AddRootPath(".", rootTfPath)
+
+	err := traverser.WalkPath(rootTfPath, false)
+	if err != nil {
+		t.Fatalf("error walking %s: %s", dir, err)
+	}
+
+	err = container.ParsePaths(traverser, nil, 1)
+	if err != nil {
+		t.Fatalf("error parsing %s: %s", dir, err)
+	}
+
+	err = container.LinkPaths(traverser)
+	if err != nil {
+		t.Fatalf("error linking %s: %s", dir, err)
+	}
+
+	return container
+}
+
+func moduleCall(name, source string) string {
+	return fmt.Sprintf("module %q {\n  source = %q\n}\n", name, source)
+}
+
+func TestModuleCycles(t *testing.T) {
+	t.Parallel()
+
+	container := linkModules(t, map[string]string{
+		"main.tf":           moduleCall("a", "./modules/a") + moduleCall("b", "./modules/b"),
+		"modules/a/main.tf": moduleCall("b", "../b"),
+		"modules/b/main.tf": moduleCall("a", "../a"),
+		"modules/c/main.tf": moduleCall("c", "../c"),
+	})
+
+	// the cycle of 'a' and 'b' is found once, from the root, as their directories are followed already, while 'c'
+	// calling itself is found from its own directory
+	cycles := container.ModuleCycles()
+
+	want := []string{".: module.a > module.b > module.a", "modules/c: module.c"}
+	if !slices.Equal(cycles, want) {
+		t.Errorf("ModuleCycles() = %v, want %v", cycles, want)
+	}
+}
+
+func TestModuleCyclesManyPaths(t *testing.T) {
+	t.Parallel()
+
+	// every module calls the next one twice, so there are 2^depth chains of calls but only depth modules
+	const depth = 40
+
+	files := map[string]string{"main.tf": moduleCall("m", "./modules/m0")}
+	for i := range depth {
+		next := fmt.Sprintf("../m%d", i+1)
+		if i == depth-1 {
+			next = "../m0"
+		}
+
+		files[fmt.Sprintf("modules/m%d/main.tf", i)] = moduleCall("a", next) + moduleCall("b", next)
+	}
+
+	container := linkModules(t, files)
+
+	done := make(chan []string)
+
+	go func() {
+		done <- container.ModuleCycles()
+	}()
+
+	select {
+	case cycles := <-done:
+		// the last module calls the first one twice
+		if len(cycles) != 2 {
+			t.Errorf("ModuleCycles() returned %d cycles, want 2", len(cycles))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ModuleCycles() did not return in 10s")
+	}
+}
@@ -0,0 +1,342 @@
+package tfpath
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+var (
+	// ErrReadingTfvarsFile is returned when a '.tfvars' file cannot be parsed
+	ErrReadingTfvarsFile = errors.New("error reading tfvars file")
+	// ErrUnknownValue is returned when a value depends on something that is not known before apply, eg. a resource
+	// attribute or a variable without a value
+	ErrUnknownValue = errors.New("value is not known statically")
+	// ErrInvalidRepetition is returned when 'for_each' or 'count' evaluates to a value that cannot repeat a block
+	ErrInvalidRepetition = errors.New("invalid for_each or count")
+)
+
+// TfInstance is an instance of a resource or a module call, one for each key of its 'for_each' or index of its
+// 'count', or the only one when it is not repeated.
+type TfInstance struct {
+	// Key is the instance key as in its address, eg. '["admin"]' or '[0]', and empty when the block is not repeated
+	Key string
+	// Name is the value of the display attribute of a resource instance, and empty when it is not known
+	Name string
+}
+
+// ReadTfvarsFile returns values of variables set in a '.tfvars' file.
+func (t *Traverser) ReadTfvarsFile(filePath string) (map[string]cty.Value, error) {
+	hclFile, diags := t.Parser.ParseHCLFile(filePath)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrReadingTfvarsFile, diags.Error())
+	}
+
+	attrs, diags := hclFile.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w: %s", ErrReadingTfvarsFile, diags.Error())
+	}
+
+	values := make(map[string]cty.Value, len(attrs))
+
+	for attrName, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%w: %s: %s", ErrReadingTfvarsFile, attrName, diags.Error())
+		}
+
+		values[attrName] = value
+	}
+
+	return values, nil
+}
+
+// EvalContext returns a context for evaluating expressions of the path. Variables have values from tfvars, or their
+// defaults, locals are evaluated from them, and 'terraform.workspace' is set to workspace. Anything else, eg.
+// resource attributes or variables without a value, is unknown.
+func (t *TfPath) EvalContext(tfvars map[string]cty.Value, workspace string) *hcl.EvalContext {
+	variables := make(map[string]cty.Value, len(t.Variables))
+
+	for variableName, variable := range t.Variables {
+		value, exists := tfvars[variableName]
+
+		switch {
+		case exists:
+			variables[variableName] = value
+		case variable.Default != cty.NilVal:
+			variables[variableName] = variable.Default
+		default:
+			variables[variableName] = cty.DynamicVal
+		}
+	}
+
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":       cty.ObjectVal(variables),
+			"terraform": cty.ObjectVal(map[string]cty.Value{"workspace": cty.StringVal(workspace)}),
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"module": cty.StringVal(t.Path),
+				"root":   cty.StringVal(t.Path),
+				"cwd":    cty.StringVal(t.Path),
+			}),
+		},
+		Functions: terraformFunctions(),
+	}
+
+	evalContext.Variables["local"] = t.evalLocals(evalContext)
+
+	return evalContext
+}
+
+// evalLocals evaluates locals that refer to other locals in as many passes as needed. Locals that cannot be evaluated,
+// eg. as they refer to resources, are unknown.
+func (t *TfPath) evalLocals(evalContext *hcl.EvalContext) cty.Value {
+	locals := make(map[string]cty.Value, len(t.Locals))
+	for localName := range t.Locals {
+		locals[localName] = cty.DynamicVal
+	}
+
+	evaluated := map[string]struct{}{}
+
+	for len(evaluated) < len(t.Locals) {
+		evalContext.Variables["local"] = cty.ObjectVal(locals)
+		found := false
+
+		for localName, expr := range t.Locals {
+			_, done := evaluated[localName]
+			if done {
+				continue
+			}
+
+			value, diags := expr.Value(evalContext)
+			if diags.HasErrors() || !value.IsWhollyKnown() {
+				continue
+			}
+
+			locals[localName] = value
+			evaluated[localName] = struct{}{}
+			found = true
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return cty.ObjectVal(locals)
+}
+
+// Instances evaluates 'for_each' or 'count' of the resource and returns up to maxInstances of its instances, with
+// names evaluated for each of them, and the number of all its instances.
+func (r *TfResource) Instances(evalContext *hcl.EvalContext, maxInstances int) ([]*TfInstance, int, error) {
+	return instances(r.ForEachExpr, r.CountExpr, r.NameExpr, evalContext, maxInstances)
+}
+
+// Instances evaluates 'for_each' or 'count' of the module call and returns up to maxInstances of its instances, and
+// the number of all its instances.
+func (m *TfModule) Instances(evalContext *hcl.EvalContext, maxInstances int) ([]*TfInstance, int, error) {
+	return instances(m.ForEachExpr, m.CountExpr, nil, evalContext, maxInstances)
+}
+
+// ArgumentValues returns values of the variables set in the module call, evaluated in the context of the caller.
+// Values that are not known are unknown, so that the defaults of the variables are not used instead.
+func (m *TfModule) ArgumentValues(evalContext *hcl.EvalContext) map[string]cty.Value {
+	values := make(map[string]cty.Value, len(m.Arguments))
+
+	for argumentName, expr := range m.Arguments {
+		value, diags := expr.Value(evalContext)
+		if diags.HasErrors() || !value.IsWhollyKnown() {
+			value = cty.DynamicVal
+		}
+
+		values[argumentName] = value
+	}
+
+	return values
+}
+
+// instances returns up to maxInstances instances, so that a huge 'count' is not allocated, and the number of all of
+// them, which is more than the number of instances returned when there are too many of them.
+//
+//nolint:gocognit,funlen
+func instances(
+	forEachExpr, countExpr, nameExpr hcl.Expression,
+	evalContext *hcl.EvalContext,
+	maxInstances int,
+) ([]*TfInstance, int, error) {
+	switch {
+	case forEachExpr != nil:
+		value, err := evalKnown(forEachExpr, evalContext)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if !value.Type().IsSetType() && !value.Type().IsMapType() && !value.Type().IsObjectType() {
+			return nil, 0, fmt.Errorf("%w: for_each must be a map or a set, got %s", ErrInvalidRepetition,
+				value.Type().FriendlyName())
+		}
+
+		count := value.LengthInt()
+		found := make([]*TfInstance, 0, min(count, maxInstances))
+
+		for iterator := value.ElementIterator(); iterator.Next() && len(found) < maxInstances; {
+			key, element := iterator.Element()
+			if value.Type().IsSetType() {
+				key = element
+			}
+
+			key, convertErr := convert.Convert(key, cty.String)
+			if convertErr != nil || key.IsNull() {
+				return nil, 0, fmt.Errorf("%w: for_each keys must be strings", ErrInvalidRepetition)
+			}
+
+			found = append(found, &TfInstance{
+				Key: "[" + strconv.Quote(key.AsString()) + "]",
+				Name: evalName(nameExpr, evalContext, "each", map[string]cty.Value{
+					"key":   key,
+					"value": element,
+				}),
+			})
+		}
+
+		return found, count, nil
+	case countExpr != nil:
+		value, err := evalKnown(countExpr, evalContext)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value, err = convert.Convert(value, cty.Number)
+		if err != nil || value.IsNull() {
+			return nil, 0, fmt.Errorf("%w: count must be a number", ErrInvalidRepetition)
+		}
+
+		count, _ := value.AsBigFloat().Int64()
+		if count < 0 {
+			return nil, 0, fmt.Errorf("%w: count must not be negative", ErrInvalidRepetition)
+		}
+
+		found := make([]*TfInstance, 0, min(count, int64(maxInstances)))
+
+		for index := range min(count, int64(maxInstances)) {
+			found = append(found, &TfInstance{
+				Key: "[" + strconv.FormatInt(index, 10) + "]",
+				Name: evalName(nameExpr, evalContext, "count", map[string]cty.Value{
+					"index": cty.NumberIntVal(index),
+				}),
+			})
+		}
+
+		return found, int(min(count, math.MaxInt)), nil
+	default:
+		return []*TfInstance{{Name: evalName(nameExpr, evalContext, "", nil)}}, 1, nil
+	}
+}
+
+// evalKnown returns value of the expression, or ErrUnknownValue when it is not known.
+func evalKnown(expr hcl.Expression, evalContext *hcl.EvalContext) (cty.Value, error) {
+	value, diags := expr.Value(evalContext)
+	if diags.HasErrors() || !value.IsWhollyKnown() {
+		return cty.NilVal, fmt.Errorf("%w: %s", ErrUnknownValue, expr.Range().String())
+	}
+
+	if value.IsNull() {
+		return cty.NilVal, fmt.Errorf("%w: value is null: %s", ErrInvalidRepetition, expr.Range().String())
+	}
+
+	return value, nil
+}
+
+// evalName returns the name evaluated as a string, with 'each' or 'count' of the instance, or an empty string when
+// it is not known.
+func evalName(
+	nameExpr hcl.Expression,
+	evalContext *hcl.EvalContext,
+	objectName string,
+	object map[string]cty.Value,
+) string {
+	if nameExpr == nil {
+		return ""
+	}
+
+	if objectName != "" {
+		evalContext = evalContext.NewChild()
+		evalContext.Variables = map[string]cty.Value{objectName: cty.ObjectVal(object)}
+	}
+
+	value, diags := nameExpr.Value(evalContext)
+	if diags.HasErrors() || !value.IsWhollyKnown() || value.IsNull() {
+		return ""
+	}
+
+	value, err := convert.Convert(value, cty.String)
+	if err != nil {
+		return ""
+	}
+
+	return value.AsString()
+}
+
+// terraformFunctions returns the Terraform functions used in 'for_each', 'count' and names the most.
+func terraformFunctions() map[string]function.Function {
+	return map[string]function.Function{
+		"abs":             stdlib.AbsoluteFunc,
+		"ceil":            stdlib.CeilFunc,
+		"chomp":           stdlib.ChompFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"flatten":         stdlib.FlattenFunc,
+		"floor":           stdlib.FloorFunc,
+		"format":          stdlib.FormatFunc,
+		"formatlist":      stdlib.FormatListFunc,
+		"join":            stdlib.JoinFunc,
+		"jsondecode":      stdlib.JSONDecodeFunc,
+		"jsonencode":      stdlib.JSONEncodeFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          stdlib.LengthFunc,
+		"lookup":          stdlib.LookupFunc,
+		"lower":           stdlib.LowerFunc,
+		"max":             stdlib.MaxFunc,
+		"merge":           stdlib.MergeFunc,
+		"min":             stdlib.MinFunc,
+		"range":           stdlib.RangeFunc,
+		"regex":           stdlib.RegexFunc,
+		"regexall":        stdlib.RegexAllFunc,
+		"replace":         stdlib.ReplaceFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"split":           stdlib.SplitFunc,
+		"substr":          stdlib.SubstrFunc,
+		"title":           stdlib.TitleFunc,
+		"tobool":          stdlib.MakeToFunc(cty.Bool),
+		"tolist":          stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":           stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber":        stdlib.MakeToFunc(cty.Number),
+		"toset":           stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring":        stdlib.MakeToFunc(cty.String),
+		"trim":            stdlib.TrimFunc,
+		"trimprefix":      stdlib.TrimPrefixFunc,
+		"trimspace":       stdlib.TrimSpaceFunc,
+		"trimsuffix":      stdlib.TrimSuffixFunc,
+		"upper":           stdlib.UpperFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+	}
+}
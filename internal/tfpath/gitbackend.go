@@ -0,0 +1,264 @@
+package tfpath
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	ErrUnknownGitBackend      = errors.New("unknown git backend")
+	ErrGitFetchFailed         = errors.New("error fetching git ref")
+	ErrGitRefNotFound         = errors.New("git ref not found")
+	ErrGitOpeningRepository   = errors.New("error opening git repository")
+	ErrGitCreatingRepository  = errors.New("error creating git repository")
+	ErrGitGettingWorktree     = errors.New("error getting git worktree")
+	ErrGitResolvingRevision   = errors.New("error resolving git revision")
+	ErrGitCheckoutWorktree    = errors.New("error checking out git worktree")
+	ErrGitCreatingRemote      = errors.New("error creating git remote")
+	ErrGitGettingRemoteConfig = errors.New("error getting git remote config")
+)
+
+const (
+	// GitBackendExec runs 'git' commands installed in the system.
+	GitBackendExec = "exec"
+	// GitBackendGoGit uses an in-process git implementation with shallow fetches.
+	GitBackendGoGit = "go-git"
+
+	gitRemoteName = "origin"
+)
+
+// GitBackend gets module source code from a git repository.
+type GitBackend interface {
+	// Checkout makes dir contain url repository at ref and returns the commit that ref resolved to.
+	// When clone is true, dir is an empty directory that was just created.
+	Checkout(url, ref, dir string, clone bool) (string, error)
+}
+
+// NewGitBackend returns a GitBackend with specific name.
+func NewGitBackend(name string) (GitBackend, error) {
+	switch name {
+	case "", GitBackendExec:
+		return &ExecGitBackend{}, nil
+	case GitBackendGoGit:
+		return NewGoGitBackend(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownGitBackend, name)
+	}
+}
+
+// ExecGitBackend runs 'git clone', 'git fetch --all' and 'git checkout' commands.
+type ExecGitBackend struct{}
+
+// Checkout clones repository (when needed), fetches all refs and checks out ref.
+func (e *ExecGitBackend) Checkout(url, ref, dir string, clone bool) (string, error) {
+	if clone {
+		_, err := e.run("", "clone", url, dir)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrGitCloneFailed, err)
+		}
+	}
+
+	cmdArgsMatrix := [][]string{
+		{"fetch", "--all"},
+		{"checkout", ref},
+	}
+
+	for _, cmdArgs := range cmdArgsMatrix {
+		_, err := e.run(dir, cmdArgs...)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrGitCheckoutFailed, err)
+		}
+	}
+
+	resolvedCommit, err := e.run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrGitRevParseFailed, err)
+	}
+
+	return resolvedCommit, nil
+}
+
+func (e *ExecGitBackend) run(dir string, cmdArgs ...string) (string, error) {
+	cmdName := "git"
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitCloneTimeout*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cmdName, cmdArgs...)
+	cmd.Dir = dir
+
+	output, err := cmd.Output()
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf(
+				"🚫 Command '%s %s' failed in 📁%s: %s",
+				cmdName,
+				strings.Join(cmdArgs, " "),
+				dir,
+				err.Error(),
+			),
+		)
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GoGitBackend uses go-git to fetch only the needed ref, with depth of 1 when possible, so that git does not need
+// to be installed and the cache does not contain full history.
+type GoGitBackend struct {
+	regexpCommit *regexp.Regexp
+}
+
+// NewGoGitBackend returns a GoGitBackend instance.
+func NewGoGitBackend() *GoGitBackend {
+	return &GoGitBackend{
+		regexpCommit: regexp.MustCompile(`^[0-9a-f]{7,40}$`),
+	}
+}
+
+// Checkout initialises repository (when needed), fetches ref and checks it out.
+func (g *GoGitBackend) Checkout(url, ref, dir string, clone bool) (string, error) {
+	repository, err := g.openRepository(url, dir, clone)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitCloneTimeout*time.Second)
+	defer cancel()
+
+	hash, err := g.fetchRef(ctx, repository, ref)
+	if err != nil {
+		return "", err
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrGitGettingWorktree, err)
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrGitCheckoutWorktree, err)
+	}
+
+	return hash.String(), nil
+}
+
+func (g *GoGitBackend) openRepository(url, dir string, clone bool) (*git.Repository, error) {
+	_, err := os.Stat(filepath.Join(dir, gitDirName))
+	if clone || os.IsNotExist(err) {
+		repository, err := git.PlainInit(dir, false)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrGitCreatingRepository, err)
+		}
+
+		_, err = repository.CreateRemote(&config.RemoteConfig{Name: gitRemoteName, URLs: []string{url}})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrGitCreatingRemote, err)
+		}
+
+		return repository, nil
+	}
+
+	repository, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGitOpeningRepository, err)
+	}
+
+	remote, err := repository.Remote(gitRemoteName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGitGettingRemoteConfig, err)
+	}
+
+	// repository url might have changed in the overrides
+	if len(remote.Config().URLs) == 0 || remote.Config().URLs[0] != url {
+		err := repository.DeleteRemote(gitRemoteName)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrGitCreatingRemote, err)
+		}
+
+		_, err = repository.CreateRemote(&config.RemoteConfig{Name: gitRemoteName, URLs: []string{url}})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrGitCreatingRemote, err)
+		}
+	}
+
+	return repository, nil
+}
+
+// fetchRef fetches a tag or a branch with depth of 1. When ref is neither, it is considered a commit and all
+// branches and tags are fetched with their history so that the commit can be found.
+func (g *GoGitBackend) fetchRef(ctx context.Context, repository *git.Repository, ref string) (plumbing.Hash, error) {
+	candidates := []struct {
+		refSpec config.RefSpec
+		refName plumbing.ReferenceName
+	}{
+		{
+			refSpec: config.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%s", ref, ref)),
+			refName: plumbing.NewTagReferenceName(ref),
+		},
+		{
+			refSpec: config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", ref, gitRemoteName, ref)),
+			refName: plumbing.NewRemoteReferenceName(gitRemoteName, ref),
+		},
+	}
+
+	for _, candidate := range candidates {
+		err := repository.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: gitRemoteName,
+			RefSpecs:   []config.RefSpec{candidate.refSpec},
+			Depth:      1,
+			Tags:       git.NoTags,
+			Force:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			slog.Debug(fmt.Sprintf("🌎 Fetching %s failed: %s", candidate.refSpec, err.Error()))
+
+			continue
+		}
+
+		hash, err := repository.ResolveRevision(plumbing.Revision(candidate.refName))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("%w: %w", ErrGitResolvingRevision, err)
+		}
+
+		return *hash, nil
+	}
+
+	if !g.regexpCommit.MatchString(ref) {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %s", ErrGitRefNotFound, ref)
+	}
+
+	err := repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: gitRemoteName,
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", gitRemoteName)),
+			config.RefSpec("+refs/tags/*:refs/tags/*"),
+		},
+		Force: true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %w", ErrGitFetchFailed, err)
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %w", ErrGitResolvingRevision, err)
+	}
+
+	return *hash, nil
+}
@@ -0,0 +1,192 @@
+package tfpath_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tfsketch/internal/tfpath"
+)
+
+const testModuleFile = "main.tf"
+
+// testRepository is a bare repository with a tag, a branch and commits that are not at the tip of any of them.
+type testRepository struct {
+	url     string
+	commits map[string]string
+}
+
+// newTestRepository creates a bare repository with three commits on 'main', tagged 'v1.0.0' at the first one,
+// and a 'feature' branch with a commit on top of the second one. Each commit writes its name to main.tf.
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+
+	_, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+
+	workDir := filepath.Join(t.TempDir(), "work")
+	bareDir := filepath.Join(t.TempDir(), "module.git")
+
+	runGit(t, "", "init", "--quiet", "--initial-branch=main", workDir)
+
+	commits := map[string]string{}
+
+	commit := func(name string) {
+		err := os.WriteFile(filepath.Join(workDir, testModuleFile), []byte(name+"\n"), 0o600)
+		if err != nil {
+			t.Fatalf("error writing %s: %s", testModuleFile, err)
+		}
+
+		runGit(t, workDir, "add", testModuleFile)
+		runGit(t, workDir, "commit", "--quiet", "--message", name)
+
+		commits[name] = runGit(t, workDir, "rev-parse", "HEAD")
+	}
+
+	commit("first")
+	runGit(t, workDir, "tag", "v1.0.0")
+	commit("second")
+	runGit(t, workDir, "checkout", "--quiet", "-b", "feature")
+	commit("feature")
+	runGit(t, workDir, "checkout", "--quiet", "main")
+	commit("third")
+
+	runGit(t, "", "clone", "--quiet", "--bare", workDir, bareDir)
+
+	return &testRepository{url: "file://" + bareDir, commits: commits}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_AUTHOR_NAME=tfsketch",
+		"GIT_AUTHOR_EMAIL=tfsketch@example.com",
+		"GIT_COMMITTER_NAME=tfsketch",
+		"GIT_COMMITTER_EMAIL=tfsketch@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+func TestGitBackendCheckout(t *testing.T) {
+	t.Parallel()
+
+	repository := newTestRepository(t)
+
+	backends := map[string]func() tfpath.GitBackend{
+		tfpath.GitBackendExec:  func() tfpath.GitBackend { return &tfpath.ExecGitBackend{} },
+		tfpath.GitBackendGoGit: func() tfpath.GitBackend { return tfpath.NewGoGitBackend() },
+	}
+
+	tests := []struct {
+		name       string
+		ref        string
+		wantCommit string
+	}{
+		{name: "tag", ref: "v1.0.0", wantCommit: "first"},
+		{name: "branch", ref: "feature", wantCommit: "feature"},
+		// not the tip of any branch, so a shallow fetch cannot find it and the whole history is fetched
+		{name: "commit", ref: repository.commits["second"], wantCommit: "second"},
+	}
+
+	for backendName, newBackend := range backends {
+		for _, test := range tests {
+			t.Run(backendName+"/"+test.name, func(t *testing.T) {
+				t.Parallel()
+
+				dir := filepath.Join(t.TempDir(), "module")
+
+				err := os.MkdirAll(dir, 0o755)
+				if err != nil {
+					t.Fatalf("error creating %s: %s", dir, err)
+				}
+
+				resolvedCommit, err := newBackend().Checkout(repository.url, test.ref, dir, true)
+				if err != nil {
+					t.Fatalf("Checkout(%s) returned error: %s", test.ref, err)
+				}
+
+				if resolvedCommit != repository.commits[test.wantCommit] {
+					t.Errorf("Checkout(%s) = %s, want %s", test.ref, resolvedCommit, repository.commits[test.wantCommit])
+				}
+
+				content, err := os.ReadFile(filepath.Join(dir, testModuleFile))
+				if err != nil {
+					t.Fatalf("error reading checked out %s: %s", testModuleFile, err)
+				}
+
+				if strings.TrimSpace(string(content)) != test.wantCommit {
+					t.Errorf("checked out %s contains %q, want %q", testModuleFile, content, test.wantCommit)
+				}
+			})
+		}
+	}
+}
+
+func TestGitBackendCheckoutExistingDir(t *testing.T) {
+	t.Parallel()
+
+	repository := newTestRepository(t)
+
+	backends := map[string]func() tfpath.GitBackend{
+		tfpath.GitBackendExec:  func() tfpath.GitBackend { return &tfpath.ExecGitBackend{} },
+		tfpath.GitBackendGoGit: func() tfpath.GitBackend { return tfpath.NewGoGitBackend() },
+	}
+
+	for backendName, newBackend := range backends {
+		t.Run(backendName, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "module")
+
+			err := os.MkdirAll(dir, 0o755)
+			if err != nil {
+				t.Fatalf("error creating %s: %s", dir, err)
+			}
+
+			backend := newBackend()
+
+			_, err = backend.Checkout(repository.url, "v1.0.0", dir, true)
+			if err != nil {
+				t.Fatalf("first Checkout returned error: %s", err)
+			}
+
+			// the cached directory is reused and moved to another ref
+			resolvedCommit, err := backend.Checkout(repository.url, "main", dir, false)
+			if err != nil {
+				t.Fatalf("second Checkout returned error: %s", err)
+			}
+
+			if resolvedCommit != repository.commits["third"] {
+				t.Errorf("Checkout(main) = %s, want %s", resolvedCommit, repository.commits["third"])
+			}
+		})
+	}
+}
+
+func TestGoGitBackendCheckoutUnknownRef(t *testing.T) {
+	t.Parallel()
+
+	repository := newTestRepository(t)
+	dir := t.TempDir()
+
+	_, err := tfpath.NewGoGitBackend().Checkout(repository.url, "no-such-ref", dir, true)
+	if !errors.Is(err, tfpath.ErrGitRefNotFound) {
+		t.Errorf("Checkout(no-such-ref) returned %v, want %v", err, tfpath.ErrGitRefNotFound)
+	}
+}
@@ -21,7 +21,7 @@ func NewHCLBodySchema(displayAttributes []string) *hcl.BodySchema {
 	}
 
 	// Other attributes (for modules and syntax)
-	for _, attribute := range []string{"source", "version", "for_each"} {
+	for _, attribute := range []string{"source", "version", "for_each", "count"} {
 		hclBodySchema.Attributes = append(hclBodySchema.Attributes, hcl.AttributeSchema{
 			Name:     attribute,
 			Required: false,
@@ -0,0 +1,266 @@
+package tfpath
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrModuleNotInRegistryFormat = errors.New("module source is not in registry format")
+	ErrModuleVersionUnknown      = errors.New("module version is unknown")
+	ErrCopyingModuleToMirror     = errors.New("error copying module to mirror")
+)
+
+const (
+	mirrorDefaultHost = "registry.terraform.io"
+	mirrorDirMode     = 0o755
+)
+
+// Mirror represents a pre-seeded directory with modules, laid out similarly to Terraform's provider mirror:
+// '<host>/<namespace>/<name>/<provider>/<version>/'. It is consulted before any registry or git access.
+type Mirror struct {
+	path                 string
+	regexpRegistrySource *regexp.Regexp
+	regexpVersion        *regexp.Regexp
+}
+
+// NewMirror returns a Mirror instance.
+func NewMirror(path string) *Mirror {
+	mirror := &Mirror{
+		path: path,
+		regexpRegistrySource: regexp.MustCompile(
+			`^(([a-z0-9\-]+\.)+[a-z0-9\-]+/)?[a-zA-Z0-9\-_]+/[a-zA-Z0-9\-_]+/[a-zA-Z0-9\-_]+$`,
+		),
+		regexpVersion: regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)(-[a-zA-Z0-9\.\-]+)?$`),
+	}
+
+	return mirror
+}
+
+// Path returns the mirror directory.
+func (m *Mirror) Path() string {
+	return m.path
+}
+
+// FindModule returns a path to the module in the mirror, or empty string when it is not there. When version is
+// empty, the latest version found in the mirror is used.
+func (m *Mirror) FindModule(sourceVersion string) string {
+	source, version := m.splitSourceVersion(sourceVersion)
+
+	sourceDir, ok := m.sourceDir(source)
+	if !ok {
+		return ""
+	}
+
+	if version == "" {
+		version = m.latestVersion(sourceDir)
+		if version == "" {
+			return ""
+		}
+	}
+
+	moduleDir := filepath.Join(sourceDir, version)
+
+	dirStat, err := os.Stat(moduleDir)
+	if err != nil || !dirStat.IsDir() {
+		slog.Debug(fmt.Sprintf("🪞 Module 📦%s not found in mirror 📁%s", sourceVersion, moduleDir))
+
+		return ""
+	}
+
+	slog.Debug(fmt.Sprintf("🪞 Module 📦%s found in mirror 📁%s", sourceVersion, moduleDir))
+
+	return moduleDir
+}
+
+// AddModule copies module files (without the '.git' directory) from srcDir to the mirror. When version in
+// sourceVersion is empty, ref is used as the version (eg. a tag that the registry pointed to).
+func (m *Mirror) AddModule(sourceVersion, ref, srcDir string) (string, error) {
+	source, version := m.splitSourceVersion(sourceVersion)
+
+	sourceDir, ok := m.sourceDir(source)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrModuleNotInRegistryFormat, sourceVersion)
+	}
+
+	if version == "" {
+		if !m.regexpVersion.MatchString(ref) {
+			return "", fmt.Errorf("%w: %s", ErrModuleVersionUnknown, sourceVersion)
+		}
+
+		version = strings.TrimPrefix(ref, "v")
+	}
+
+	moduleDir := filepath.Join(sourceDir, version)
+
+	err := os.RemoveAll(moduleDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrCopyingModuleToMirror, err)
+	}
+
+	err = copyDir(srcDir, moduleDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrCopyingModuleToMirror, err)
+	}
+
+	return moduleDir, nil
+}
+
+// IsRegistrySource checks if module source can be stored in the mirror.
+func (m *Mirror) IsRegistrySource(sourceVersion string) bool {
+	source, _ := m.splitSourceVersion(sourceVersion)
+
+	return m.regexpRegistrySource.MatchString(source)
+}
+
+func (m *Mirror) splitSourceVersion(sourceVersion string) (string, string) {
+	source, version, _ := strings.Cut(sourceVersion, "@")
+
+	// sub-modules are taken from the module directory
+	source, _, _ = strings.Cut(source, "//")
+
+	return source, version
+}
+
+// sourceDir returns '<mirror>/<host>/<namespace>/<name>/<provider>' directory for module source.
+func (m *Mirror) sourceDir(source string) (string, bool) {
+	if !m.regexpRegistrySource.MatchString(source) {
+		return "", false
+	}
+
+	parts := strings.Split(source, "/")
+
+	//nolint:mnd
+	if len(parts) == 3 {
+		parts = append([]string{mirrorDefaultHost}, parts...)
+	}
+
+	return filepath.Join(append([]string{m.path}, parts...)...), true
+}
+
+// latestVersion returns the highest version found in the source directory.
+func (m *Mirror) latestVersion(sourceDir string) string {
+	dirEntries, err := os.ReadDir(sourceDir)
+	if err != nil {
+		return ""
+	}
+
+	versions := []string{}
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() && m.regexpVersion.MatchString(dirEntry.Name()) {
+			versions = append(versions, dirEntry.Name())
+		}
+	}
+
+	if len(versions) == 0 {
+		return ""
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return m.compareVersions(versions[i], versions[j]) < 0
+	})
+
+	return versions[len(versions)-1]
+}
+
+func (m *Mirror) compareVersions(versionA, versionB string) int {
+	matchesA := m.regexpVersion.FindStringSubmatch(versionA)
+	matchesB := m.regexpVersion.FindStringSubmatch(versionB)
+
+	//nolint:mnd
+	for i := 1; i <= 3; i++ {
+		numA, _ := strconv.Atoi(matchesA[i])
+		numB, _ := strconv.Atoi(matchesB[i])
+
+		if numA != numB {
+			return numA - numB
+		}
+	}
+
+	// version without pre-release suffix is higher
+	preA, preB := matchesA[4], matchesB[4]
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	default:
+		return strings.Compare(preA, preB)
+	}
+}
+
+// copyDir copies regular files from srcDir to dstDir, skipping the '.git' directory.
+func copyDir(srcDir, dstDir string) error {
+	err := filepath.WalkDir(srcDir, func(currentPath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, currentPath)
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(dstDir, relPath)
+
+		if dirEntry.IsDir() {
+			if dirEntry.Name() == gitDirName {
+				return fs.SkipDir
+			}
+
+			return os.MkdirAll(dstPath, mirrorDirMode)
+		}
+
+		if !dirEntry.Type().IsRegular() {
+			return nil
+		}
+
+		return copyFile(currentPath, dstPath)
+	})
+	if err != nil {
+		return fmt.Errorf("error copying %s to %s: %w", srcDir, dstDir, err)
+	}
+
+	return nil
+}
+
+func copyFile(srcPath, dstPath string) error {
+	srcFile, err := os.Open(filepath.Clean(srcPath))
+	if err != nil {
+		return err
+	}
+
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := os.OpenFile(filepath.Clean(dstPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dstFile, srcFile)
+	if err != nil {
+		_ = dstFile.Close()
+
+		return err
+	}
+
+	return dstFile.Close()
+}
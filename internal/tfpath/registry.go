@@ -0,0 +1,77 @@
+package tfpath
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrGettingRegistryVersions = errors.New("error getting module versions from registry")
+	ErrNotRegistryModule       = errors.New("module source is not a registry module")
+)
+
+const registryTimeout = 30
+
+var regexpRegistryModule = regexp.MustCompile(`^[a-zA-Z0-9\-_]+/[a-zA-Z0-9\-_]+/[a-zA-Z0-9\-_]+$`)
+
+var registryClient = &http.Client{Timeout: registryTimeout * time.Second}
+
+// registryVersions represents the registry response with versions of a module.
+type registryVersions struct {
+	Modules []struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	} `json:"modules"`
+}
+
+// RegistryModuleVersions returns versions of a module in the Terraform registry. Source can point to
+// a sub-directory, eg. 'namespace/name/provider//modules/sub', and the versions of the module are returned then.
+// The request is cancelled with ctx, or when it takes longer than the registry timeout.
+func RegistryModuleVersions(ctx context.Context, source string) ([]string, error) {
+	source, _, _ = strings.Cut(source, "//")
+	if !regexpRegistryModule.MatchString(source) {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegistryModule, source)
+	}
+
+	url := fmt.Sprintf("https://registry.terraform.io/v1/modules/%s/versions", source)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGettingRegistryVersions, err)
+	}
+
+	resp, err := registryClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGettingRegistryVersions, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %s", ErrGettingRegistryVersions, url, resp.Status)
+	}
+
+	response := &registryVersions{}
+
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGettingRegistryVersions, err)
+	}
+
+	versions := []string{}
+
+	for _, module := range response.Modules {
+		for _, version := range module.Versions {
+			versions = append(versions, version.Version)
+		}
+	}
+
+	return versions, nil
+}
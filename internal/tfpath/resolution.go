@@ -0,0 +1,224 @@
+package tfpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrWritingResolutionReport = errors.New("error writing resolution report")
+
+const (
+	// ResolutionLocal is a module with a relative source found in the scanned path.
+	ResolutionLocal = "local"
+	// ResolutionOverride is a module found in a local path set in the overrides file.
+	ResolutionOverride = "override"
+	// ResolutionOverrideRegexp is a module found in a local path set with a regular expression in the overrides file.
+	ResolutionOverrideRegexp = "override-regexp"
+	// ResolutionMirror is a module found in the module mirror.
+	ResolutionMirror = "mirror"
+	// ResolutionCache is a module downloaded to the cache from a source set in the overrides file.
+	ResolutionCache = "cache"
+	// ResolutionRegistry is a module downloaded to the cache from a source returned by the registry.
+	ResolutionRegistry = "registry"
+	// ResolutionIgnored is a module deliberately skipped in the overrides file.
+	ResolutionIgnored = "ignored"
+
+	resolutionReportFileMode = 0o600
+)
+
+// ModuleResolution describes how an external module (container path key) was found, or why it was not.
+type ModuleResolution struct {
+	Method string
+	Path   string
+	Reason string
+}
+
+// ModuleCallResolution describes how a single module call ('module' block) was resolved.
+type ModuleCallResolution struct {
+	// Path is the container path (module, or name of the scanned path, "." when there is only one) that the call
+	// is in.
+	Path string `json:"path"`
+	// RelPath is the directory, relative to Path, that the call is in.
+	RelPath string `json:"relPath"`
+	// File is the file that the call is in.
+	File string `json:"file"`
+	// LineStart and LineEnd are lines of the 'module' block in the file.
+	LineStart int `json:"lineStart"`
+	LineEnd   int `json:"lineEnd"`
+	// Module is the name of the module.
+	Module  string `json:"module"`
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+	// Method is how the module was resolved, eg. local, override, override-regexp, mirror, cache or registry.
+	Method   string `json:"method,omitempty"`
+	Resolved bool   `json:"resolved"`
+	// ResolvedPath is the local directory of the module.
+	ResolvedPath string `json:"resolvedPath,omitempty"`
+	// Reason is why module could not be resolved.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ResolutionReport contains resolution of all module calls found in the container.
+type ResolutionReport struct {
+	Calls      []*ModuleCallResolution `json:"calls"`
+	Methods    map[string]int          `json:"methods"`
+	Unresolved int                     `json:"unresolved"`
+}
+
+// ResolutionReport returns resolution of all module calls in container paths and their sub-directories.
+// It should be called after paths are linked.
+func (c *Container) ResolutionReport() *ResolutionReport {
+	report := &ResolutionReport{
+		Calls:   []*ModuleCallResolution{},
+		Methods: map[string]int{},
+	}
+
+	pathNames := make([]string, 0, len(c.Paths))
+	for pathName := range c.Paths {
+		pathNames = append(pathNames, pathName)
+	}
+
+	sort.Strings(pathNames)
+
+	for _, pathName := range pathNames {
+		tfPath := c.Paths[pathName]
+
+		c.addPathToResolutionReport(report, pathName, tfPath)
+
+		for _, childKey := range tfPath.ChildrenNamesSorted() {
+			childTfPath := tfPath.Children[childKey]
+			if childTfPath == nil {
+				continue
+			}
+
+			c.addPathToResolutionReport(report, pathName, childTfPath)
+		}
+	}
+
+	return report
+}
+
+// UnresolvedCalls returns module calls that were not resolved, excluding ignored ones.
+func (r *ResolutionReport) UnresolvedCalls() []*ModuleCallResolution {
+	unresolved := []*ModuleCallResolution{}
+
+	for _, call := range r.Calls {
+		if !call.Resolved && call.Method != ResolutionIgnored {
+			unresolved = append(unresolved, call)
+		}
+	}
+
+	return unresolved
+}
+
+// WriteToFile writes the report as JSON.
+func (r *ResolutionReport) WriteToFile(path string) error {
+	reportBytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWritingResolutionReport, err)
+	}
+
+	err = os.WriteFile(filepath.Clean(path), reportBytes, resolutionReportFileMode)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWritingResolutionReport, err)
+	}
+
+	return nil
+}
+
+func (c *Container) addPathToResolutionReport(report *ResolutionReport, pathName string, tfPath *TfPath) {
+	relPath := tfPath.RelPath
+	if relPath == "" {
+		relPath = "."
+	}
+
+	for _, moduleKey := range tfPath.ModuleNamesSorted() {
+		module := tfPath.Modules[moduleKey]
+		if module == nil {
+			continue
+		}
+
+		call := &ModuleCallResolution{
+			Path:      pathName,
+			RelPath:   relPath,
+			File:      module.FilePath,
+			LineStart: module.LineStart,
+			LineEnd:   module.LineEnd,
+			Module:    module.Name,
+			Source:    module.FieldSource,
+			Version:   module.FieldVersion,
+			Method:    module.Resolution,
+			Resolved:  module.TfPath != nil,
+			Reason:    module.ResolutionError,
+		}
+
+		if module.TfPath != nil {
+			call.ResolvedPath = module.TfPath.Path
+		} else if call.Reason == "" && call.Method != ResolutionIgnored {
+			call.Reason = "module was not linked"
+		}
+
+		report.Calls = append(report.Calls, call)
+
+		if call.Method != "" {
+			report.Methods[call.Method]++
+		}
+
+		if !call.Resolved && call.Method != ResolutionIgnored {
+			report.Unresolved++
+		}
+	}
+}
+
+// setResolution records how an external module was found, unless it is recorded already.
+func (c *Container) setResolution(containerPathKey, method, path string) {
+	resolution, exists := c.Resolutions[containerPathKey]
+	if exists && resolution.Method != "" {
+		return
+	}
+
+	c.Resolutions[containerPathKey] = &ModuleResolution{
+		Method: method,
+		Path:   path,
+	}
+}
+
+// setResolutionFailure records why an external module could not be found.
+func (c *Container) setResolutionFailure(containerPathKey, reason string) {
+	c.Resolutions[containerPathKey] = &ModuleResolution{
+		Reason: reason,
+	}
+}
+
+// resolutionFor returns resolution of an external module. Sub-modules ('source//modules/name@version') take
+// resolution of their module.
+func (c *Container) resolutionFor(containerPathKey string) *ModuleResolution {
+	resolution, exists := c.Resolutions[containerPathKey]
+	if exists {
+		return resolution
+	}
+
+	source, version := containerPathKey, ""
+
+	separatorIndex := strings.LastIndex(containerPathKey, "@")
+	if separatorIndex != -1 {
+		source, version = containerPathKey[:separatorIndex], containerPathKey[separatorIndex+1:]
+	}
+
+	baseSource, _, isSubModule := strings.Cut(source, "//")
+	if !isSubModule {
+		return nil
+	}
+
+	resolution, exists = c.Resolutions[baseSource+"@"+version]
+	if !exists {
+		return nil
+	}
+
+	return resolution
+}
@@ -0,0 +1,111 @@
+package tfpath
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	tfComponentExtension = ".tfcomponent.hcl"
+	// tfStackExtension is the extension of component files in early versions of Terraform Stacks
+	tfStackExtension    = ".tfstack.hcl"
+	tfDeployExtension   = ".tfdeploy.hcl"
+	componentBlockType  = "component"
+	deploymentBlockType = "deployment"
+)
+
+// isStackFile checks if the file contains Terraform Stacks components or deployments.
+func isStackFile(fileName string) bool {
+	return strings.HasSuffix(fileName, tfComponentExtension) ||
+		strings.HasSuffix(fileName, tfStackExtension) ||
+		strings.HasSuffix(fileName, tfDeployExtension)
+}
+
+// parseStackFile parses a Terraform Stacks file. Its 'component' blocks are added as module calls, as they call
+// modules in the same way, and its 'deployment' blocks as deployments of the path.
+func (t *Traverser) parseStackFile(tfPath *TfPath, fileName string, foundModules *[]string) error {
+	filePath := filepath.Join(tfPath.Path, fileName)
+
+	hclFile, diags := t.Parser.ParseHCLFile(filePath)
+	if diags.HasErrors() {
+		return fmt.Errorf("error parsing hcl file: %s", diags.Error())
+	}
+
+	content, _, _ := hclFile.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: componentBlockType, LabelNames: []string{"name"}},
+			{Type: deploymentBlockType, LabelNames: []string{"name"}},
+		},
+	})
+
+	for _, block := range content.Blocks {
+		if len(block.Labels) != 1 {
+			continue
+		}
+
+		switch block.Type {
+		case componentBlockType:
+			component := t.parseHCLBlockModule(block)
+			component.Component = true
+			component.Arguments = componentInputs(block)
+			component.FileName = fileName
+			component.FilePath = filePath
+
+			t.addModule(tfPath, component, foundModules)
+		case deploymentBlockType:
+			deployment := &TfDeployment{
+				Name:     block.Labels[0],
+				FileName: fileName,
+				FilePath: filePath,
+			}
+
+			deployment.LineStart, deployment.LineEnd = blockLines(block)
+			tfPath.Deployments[deployment.Name] = deployment
+
+			slog.Info(
+				fmt.Sprintf(
+					"🟢 Found deployment %s in file 📄%s (📦%s)",
+					deployment.Name,
+					filePath,
+					tfPath.TraverseName,
+				),
+			)
+		}
+	}
+
+	return nil
+}
+
+// componentInputs returns expressions of the variables set in 'inputs' of the component, or nil when they are not
+// known, as 'inputs' is not an object.
+func componentInputs(block *hcl.Block) map[string]hcl.Expression {
+	attrs, _ := block.Body.JustAttributes()
+
+	inputsAttr, exists := attrs["inputs"]
+	if !exists {
+		return map[string]hcl.Expression{}
+	}
+
+	pairs, diags := hcl.ExprMap(inputsAttr.Expr)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	inputs := make(map[string]hcl.Expression, len(pairs))
+
+	for _, pair := range pairs {
+		key, diags := pair.Key.Value(nil)
+		if diags.HasErrors() || key.Type() != cty.String || key.IsNull() {
+			return nil
+		}
+
+		inputs[key.AsString()] = pair.Value
+	}
+
+	return inputs
+}
@@ -0,0 +1,348 @@
+package tfpath
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+const (
+	terragruntFileName = "terragrunt.hcl"
+	// TerragruntModuleName is the name of the module call made from the 'terraform' block of a Terragrunt unit
+	TerragruntModuleName = "terragrunt"
+	// terragruntMaxIncludes limits how many files are included, so that includes including each other end
+	terragruntMaxIncludes = 10
+)
+
+var (
+	ErrParsingTerragruntFile = errors.New("error parsing terragrunt file")
+	ErrFileNotInParents      = errors.New("file not found in parent folders")
+)
+
+// terragruntConfig contains what is drawn from a Terragrunt file, merged with the files it includes.
+type terragruntConfig struct {
+	module       *TfModule
+	dependencies map[string]*TfDependency
+}
+
+// parseTerragruntFile parses the 'terragrunt.hcl' file found in the path, when there is one. Source of its
+// 'terraform' block is added as a module call, and its 'dependency' blocks as dependencies. Included files are
+// parsed first, and what is set in the file takes precedence.
+func (t *Traverser) parseTerragruntFile(tfPath *TfPath, foundModules *[]string) error {
+	filePath := filepath.Join(tfPath.Path, terragruntFileName)
+
+	_, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("error checking terragrunt file: %s", err.Error())
+	}
+
+	unitDir, err := filepath.Abs(tfPath.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrParsingTerragruntFile, err)
+	}
+
+	config := &terragruntConfig{dependencies: map[string]*TfDependency{}}
+
+	err = t.readTerragruntFile(config, unitDir, filePath, 0)
+	if err != nil {
+		return err
+	}
+
+	for dependencyName, dependency := range config.dependencies {
+		tfPath.Dependencies[dependencyName] = dependency
+	}
+
+	for _, dependencyName := range tfPath.DependencyNamesSorted() {
+		dependency := tfPath.Dependencies[dependencyName]
+
+		slog.Info(
+			fmt.Sprintf(
+				"🔗 Found dependency %s on 📁%s in file 📄%s (📦%s)",
+				dependency.Name,
+				dependency.ConfigPath,
+				dependency.FilePath,
+				tfPath.TraverseName,
+			),
+		)
+	}
+
+	if config.module == nil {
+		return nil
+	}
+
+	config.module.FieldSource, config.module.FieldVersion = terragruntModuleSource(config.module.FieldSource, unitDir)
+
+	t.addModule(tfPath, config.module, foundModules)
+
+	return nil
+}
+
+// readTerragruntFile reads the 'terraform' and 'dependency' blocks of the file into config, after the files it
+// includes.
+//
+//nolint:funlen,gocognit
+func (t *Traverser) readTerragruntFile(config *terragruntConfig, unitDir, filePath string, includes int) error {
+	if includes > terragruntMaxIncludes {
+		return fmt.Errorf("%w: more than %d files included", ErrParsingTerragruntFile, terragruntMaxIncludes)
+	}
+
+	hclFile, diags := t.Parser.ParseHCLFile(filePath)
+	if diags.HasErrors() {
+		return fmt.Errorf("%w: %s", ErrParsingTerragruntFile, diags.Error())
+	}
+
+	body, ok := hclFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return fmt.Errorf("%w: %s is not in native syntax", ErrParsingTerragruntFile, filePath)
+	}
+
+	evalContext := terragruntEvalContext(unitDir, filepath.Dir(filePath))
+
+	// included files are read first so that the file overrides them
+	for _, block := range body.Blocks {
+		if block.Type != "include" {
+			continue
+		}
+
+		includePath, ok := terragruntAttribute(block.Body, "path", evalContext)
+		if !ok {
+			slog.Debug(fmt.Sprintf("🚫 Skipped include with path that cannot be evaluated in 📄%s", filePath))
+
+			continue
+		}
+
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(filePath), includePath)
+		}
+
+		slog.Debug(fmt.Sprintf("🔸 Including 📄%s in 📄%s", includePath, filePath))
+
+		err := t.readTerragruntFile(config, unitDir, includePath, includes+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, block := range body.Blocks {
+		lineStart, lineEnd := blockLines(block.AsHCLBlock())
+
+		switch block.Type {
+		case "terraform":
+			source, ok := terragruntAttribute(block.Body, "source", evalContext)
+			if !ok {
+				continue
+			}
+
+			config.module = &TfModule{
+				Name:        TerragruntModuleName,
+				FileName:    filepath.Base(filePath),
+				FilePath:    filePath,
+				FieldSource: source,
+				LineStart:   lineStart,
+				LineEnd:     lineEnd,
+			}
+		case "dependency":
+			if len(block.Labels) != 1 {
+				continue
+			}
+
+			configPath, ok := terragruntAttribute(block.Body, "config_path", evalContext)
+			if !ok {
+				slog.Debug(
+					fmt.Sprintf(
+						"🚫 Skipped dependency %s with config_path that cannot be evaluated in 📄%s",
+						block.Labels[0],
+						filePath,
+					),
+				)
+
+				continue
+			}
+
+			if filepath.IsAbs(configPath) {
+				relPath, err := filepath.Rel(unitDir, configPath)
+				if err != nil {
+					continue
+				}
+
+				configPath = relPath
+			}
+
+			config.dependencies[block.Labels[0]] = &TfDependency{
+				Name:       block.Labels[0],
+				FileName:   filepath.Base(filePath),
+				FilePath:   filePath,
+				ConfigPath: configPath,
+				LineStart:  lineStart,
+				LineEnd:    lineEnd,
+			}
+		}
+	}
+
+	return nil
+}
+
+// terragruntAttribute returns value of the attribute when it is a string that can be evaluated, ie. it does not
+// refer to locals, inputs or outputs of other units.
+func terragruntAttribute(body *hclsyntax.Body, name string, evalContext *hcl.EvalContext) (string, bool) {
+	attr, exists := body.Attributes[name]
+	if !exists {
+		return "", false
+	}
+
+	value, diags := attr.Expr.Value(evalContext)
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+		return "", false
+	}
+
+	return value.AsString(), true
+}
+
+// terragruntModuleSource takes source of the 'terraform' block and returns it as a module source and version:
+// registry sources ('tfr:///namespace/name/provider?version=1.0.0') as in a 'module' block, and local paths relative
+// to the unit directory. Other sources, eg. 'git::', are returned as they are.
+func terragruntModuleSource(source, unitDir string) (string, string) {
+	if strings.HasPrefix(source, "tfr://") {
+		sourceURL, err := url.Parse(source)
+		if err != nil {
+			return source, ""
+		}
+
+		registrySource := strings.ReplaceAll(strings.Trim(sourceURL.Path, "/"), "//", "/")
+		if sourceURL.Host != "" && sourceURL.Host != "registry.terraform.io" {
+			registrySource = sourceURL.Host + "/" + registrySource
+		}
+
+		return registrySource, sourceURL.Query().Get("version")
+	}
+
+	if !filepath.IsAbs(source) && !strings.HasPrefix(source, ".") {
+		return source, ""
+	}
+
+	// the double slash separates the directory that is copied from the module in it
+	localPath := strings.ReplaceAll(source, "//", "/")
+	if filepath.IsAbs(localPath) {
+		relPath, err := filepath.Rel(unitDir, localPath)
+		if err != nil {
+			return source, ""
+		}
+
+		localPath = relPath
+	}
+
+	localPath = filepath.ToSlash(filepath.Clean(localPath))
+	if !strings.HasPrefix(localPath, ".") {
+		localPath = "./" + localPath
+	}
+
+	return localPath, ""
+}
+
+// terragruntEvalContext returns functions used in paths of Terragrunt files, evaluated for the unit in unitDir, and
+// the Terraform functions that are used with them the most.
+// fileDir is the directory of the file being read, which is the included file when the unit includes it.
+//
+//nolint:funlen
+func terragruntEvalContext(unitDir, fileDir string) *hcl.EvalContext {
+	stringFunction := func(impl func(args []string) (string, error)) function.Function {
+		return function.New(&function.Spec{
+			VarParam: &function.Parameter{Name: "args", Type: cty.String},
+			Type:     function.StaticReturnType(cty.String),
+			Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+				stringArgs := make([]string, 0, len(args))
+				for _, arg := range args {
+					stringArgs = append(stringArgs, arg.AsString())
+				}
+
+				value, err := impl(stringArgs)
+				if err != nil {
+					return cty.NilVal, err
+				}
+
+				return cty.StringVal(value), nil
+			},
+		})
+	}
+
+	repoRoot := unitDir
+
+	for dir := unitDir; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		_, err := os.Stat(filepath.Join(dir, ".git"))
+		if err == nil {
+			repoRoot = dir
+
+			break
+		}
+	}
+
+	return &hcl.EvalContext{
+		Functions: map[string]function.Function{
+			"find_in_parent_folders": stringFunction(func(args []string) (string, error) {
+				name := terragruntFileName
+				if len(args) > 0 {
+					name = args[0]
+				}
+
+				for dir := filepath.Dir(unitDir); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+					_, err := os.Stat(filepath.Join(dir, name))
+					if err == nil {
+						return filepath.Join(dir, name), nil
+					}
+				}
+
+				//nolint:mnd
+				if len(args) > 1 {
+					return args[1], nil
+				}
+
+				return "", fmt.Errorf("%w: %s", ErrFileNotInParents, name)
+			}),
+			"get_terragrunt_dir": stringFunction(func(_ []string) (string, error) {
+				return unitDir, nil
+			}),
+			"get_parent_terragrunt_dir": stringFunction(func(_ []string) (string, error) {
+				return fileDir, nil
+			}),
+			"path_relative_to_include": stringFunction(func(_ []string) (string, error) {
+				return filepath.Rel(fileDir, unitDir)
+			}),
+			"path_relative_from_include": stringFunction(func(_ []string) (string, error) {
+				return filepath.Rel(unitDir, fileDir)
+			}),
+			"get_repo_root": stringFunction(func(_ []string) (string, error) {
+				return repoRoot, nil
+			}),
+			"get_path_to_repo_root": stringFunction(func(_ []string) (string, error) {
+				return filepath.Rel(unitDir, repoRoot)
+			}),
+			"dirname": stringFunction(func(args []string) (string, error) {
+				if len(args) == 0 {
+					return "", nil
+				}
+
+				return filepath.Dir(args[0]), nil
+			}),
+			"basename": stringFunction(func(args []string) (string, error) {
+				if len(args) == 0 {
+					return "", nil
+				}
+
+				return filepath.Base(args[0]), nil
+			}),
+		},
+	}
+}
@@ -0,0 +1,13 @@
+package tfpath
+
+// TfDependency represents a 'dependency' block in a Terragrunt file, pointing at another Terragrunt unit.
+type TfDependency struct {
+	Name     string
+	FileName string
+	FilePath string
+	// ConfigPath is the directory of the other unit, relative to the unit the dependency is in
+	ConfigPath string
+	// LineStart and LineEnd are lines of the 'dependency' block in the file
+	LineStart int
+	LineEnd   int
+}
@@ -0,0 +1,11 @@
+package tfpath
+
+// TfDeployment represents a 'deployment' block in a Terraform Stacks deployment file ('.tfdeploy.hcl').
+type TfDeployment struct {
+	Name     string
+	FileName string
+	FilePath string
+	// LineStart and LineEnd are lines of the 'deployment' block in the file
+	LineStart int
+	LineEnd   int
+}
@@ -1,5 +1,7 @@
 package tfpath
 
+import "github.com/hashicorp/hcl/v2"
+
 // TfModule represents a reference to a module ('module' resource in Terraform).
 type TfModule struct {
 	Name         string
@@ -8,5 +10,24 @@ type TfModule struct {
 	FieldSource  string
 	FieldVersion string
 	FieldForEach string
-	TfPath       *TfPath
+	FieldCount   string
+	// ForEachExpr and CountExpr are expressions of 'for_each' and 'count', kept to evaluate them with values of
+	// variables
+	ForEachExpr hcl.Expression
+	CountExpr   hcl.Expression
+	// Arguments are expressions of the variables set in the module call, or in 'inputs' of a component
+	Arguments map[string]hcl.Expression
+	// ForEachSource is the whole 'for_each' expression as it is in the code, while FieldForEach is set only for
+	// lists and references
+	ForEachSource string
+	// Component is set when it is a 'component' block of Terraform Stacks rather than a 'module' block
+	Component bool
+	// LineStart and LineEnd are lines of the 'module' block in the file
+	LineStart int
+	LineEnd   int
+	TfPath    *TfPath
+	// Resolution is how the module was found, eg. local, override, mirror, cache or registry
+	Resolution string
+	// ResolutionError is why the module could not be linked
+	ResolutionError string
 }
@@ -2,7 +2,11 @@
 package tfpath
 
 import (
+	"regexp"
 	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
 )
 
 // TfPath represents a path that contains terraform code.
@@ -16,6 +20,9 @@ type TfPath struct {
 	// RelPath is a relative path - hence does not contain the parent/base.
 	RelPath string
 
+	// Label is a name the path is displayed with in the chart when it is an external module (set in overrides).
+	Label string
+
 	// Children contains directories found in the path.
 	Children map[string]*TfPath
 
@@ -28,11 +35,30 @@ type TfPath struct {
 	// Modules contains tf modules found in the code
 	Modules map[string]*TfModule
 
+	// Variables contains tf variables found in the code
+	Variables map[string]*TfVariable
+
+	// Locals contains expressions of tf locals found in the code, evaluated only when their values are needed
+	Locals map[string]hcl.Expression
+
+	// ProviderLocks contains providers locked in the '.terraform.lock.hcl' file found in the path
+	ProviderLocks map[string]*TfProviderLock
+
+	// Dependencies contains 'dependency' blocks of the 'terragrunt.hcl' file found in the path, and the files it
+	// includes
+	Dependencies map[string]*TfDependency
+
+	// Deployments contains 'deployment' blocks of the Terraform Stacks files found in the path
+	Deployments map[string]*TfDeployment
+
 	// Walked indicates whether a path has been "walked" already
 	Walked bool
 
 	// Parsed indicates whether a path has been "parsed" already
 	Parsed bool
+
+	// Truncated indicates that the path was not parsed as it was found deeper than the parse depth limit
+	Truncated bool
 }
 
 // NewTfPath returns new TfPath instance containing name and a path.
@@ -44,11 +70,29 @@ func NewTfPath(path string, name string) *TfPath {
 		IsChildModule: map[string]struct{}{},
 		Resources:     map[string]*TfResource{},
 		Modules:       map[string]*TfModule{},
+		Variables:     map[string]*TfVariable{},
+		Locals:        map[string]hcl.Expression{},
+		ProviderLocks: map[string]*TfProviderLock{},
+		Dependencies:  map[string]*TfDependency{},
+		Deployments:   map[string]*TfDeployment{},
 	}
 
 	return tfPath
 }
 
+// ResetParsed removes resources, modules, variables, locals and provider locks found in the code so that the path
+// can be parsed again.
+func (t *TfPath) ResetParsed() {
+	t.Resources = map[string]*TfResource{}
+	t.Modules = map[string]*TfModule{}
+	t.Variables = map[string]*TfVariable{}
+	t.Locals = map[string]hcl.Expression{}
+	t.ProviderLocks = map[string]*TfProviderLock{}
+	t.Dependencies = map[string]*TfDependency{}
+	t.Deployments = map[string]*TfDeployment{}
+	t.Parsed = false
+}
+
 // ChildrenNamesSorted returns a list of names of sub-paths sorted alphabetically.
 func (t *TfPath) ChildrenNamesSorted() []string {
 	namesSorted := make([]string, 0, len(t.Children))
@@ -84,3 +128,188 @@ func (t *TfPath) ModuleNamesSorted() []string {
 
 	return namesSorted
 }
+
+// DependencyNamesSorted returns a list of names of Terragrunt dependencies sorted alphabetically.
+func (t *TfPath) DependencyNamesSorted() []string {
+	namesSorted := make([]string, 0, len(t.Dependencies))
+	for dependencyKey := range t.Dependencies {
+		namesSorted = append(namesSorted, dependencyKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// DeploymentNamesSorted returns a list of names of Terraform Stacks deployments sorted alphabetically.
+func (t *TfPath) DeploymentNamesSorted() []string {
+	namesSorted := make([]string, 0, len(t.Deployments))
+	for deploymentKey := range t.Deployments {
+		namesSorted = append(namesSorted, deploymentKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// ProviderLockNamesSorted returns a list of sources of locked providers sorted alphabetically.
+func (t *TfPath) ProviderLockNamesSorted() []string {
+	namesSorted := make([]string, 0, len(t.ProviderLocks))
+	for providerKey := range t.ProviderLocks {
+		namesSorted = append(namesSorted, providerKey)
+	}
+
+	sort.Strings(namesSorted)
+
+	return namesSorted
+}
+
+// ProviderLockDiscrepancies compares provider versions locked in the path and its first-level sub-directories
+// (sibling roots) and returns providers that are locked at more than one version, along with the relative paths
+// that use each of the versions.
+func (t *TfPath) ProviderLockDiscrepancies() map[string]map[string][]string {
+	return ProviderLockDiscrepanciesAcross([]*TfPath{t})
+}
+
+// ProviderLockDiscrepanciesAcross does the same as ProviderLockDiscrepancies for many root paths, comparing versions
+// across all of them. When there is more than one root, relative paths are prefixed with the root name
+// (TraverseName).
+func ProviderLockDiscrepanciesAcross(rootTfPaths []*TfPath) map[string]map[string][]string {
+	versions := map[string]map[string][]string{}
+
+	addLocks := func(tfPath *TfPath, rootName string) {
+		relPath := tfPath.RelPath
+		if relPath == "" {
+			relPath = "."
+		}
+
+		if len(rootTfPaths) > 1 {
+			relPath = rootName + ":" + relPath
+		}
+
+		for _, providerKey := range tfPath.ProviderLockNamesSorted() {
+			providerLock := tfPath.ProviderLocks[providerKey]
+
+			_, exists := versions[providerKey]
+			if !exists {
+				versions[providerKey] = map[string][]string{}
+			}
+
+			versions[providerKey][providerLock.Version] = append(
+				versions[providerKey][providerLock.Version],
+				relPath,
+			)
+		}
+	}
+
+	for _, rootTfPath := range rootTfPaths {
+		addLocks(rootTfPath, rootTfPath.TraverseName)
+
+		for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+			childTfPath := rootTfPath.Children[childKey]
+			if childTfPath == nil || strings.Contains(childTfPath.RelPath, "/") {
+				continue
+			}
+
+			addLocks(childTfPath, rootTfPath.TraverseName)
+		}
+	}
+
+	discrepancies := map[string]map[string][]string{}
+
+	for providerKey, providerVersions := range versions {
+		if len(providerVersions) > 1 {
+			discrepancies[providerKey] = providerVersions
+		}
+	}
+
+	return discrepancies
+}
+
+// FilterChildren returns a copy of the path with only the sub-directories whose relative path, and relative paths of
+// all the directories they are in, match include and do not match exclude regular expression, the same way paths are
+// skipped whilst walking.
+func (t *TfPath) FilterChildren(includeRegexp, excludeRegexp *regexp.Regexp) *TfPath {
+	filtered := *t
+	filtered.Children = map[string]*TfPath{}
+
+	for childKey, childTfPath := range t.Children {
+		if childTfPath == nil {
+			continue
+		}
+
+		isIncluded := true
+
+		relPath := ""
+		for _, dir := range strings.Split(childTfPath.RelPath, "/") {
+			relPath = strings.TrimPrefix(relPath+"/"+dir, "/")
+
+			if !includeRegexp.MatchString(relPath) || excludeRegexp.MatchString(relPath) {
+				isIncluded = false
+
+				break
+			}
+		}
+
+		if isIncluded {
+			filtered.Children[childKey] = childTfPath
+		}
+	}
+
+	return &filtered
+}
+
+// FilterResources returns a copy of the path, its sub-directories and linked modules with only the resources whose
+// type and name match regular expressions. Paths are copied once, so modules linked from many places (or from
+// themselves) share the copy.
+func (t *TfPath) FilterResources(typeRegexp, nameRegexp *regexp.Regexp) *TfPath {
+	return t.filterResources(typeRegexp, nameRegexp, map[*TfPath]*TfPath{})
+}
+
+func (t *TfPath) filterResources(typeRegexp, nameRegexp *regexp.Regexp, copies map[*TfPath]*TfPath) *TfPath {
+	filtered, exists := copies[t]
+	if exists {
+		return filtered
+	}
+
+	filtered = NewTfPath(t.Path, t.TraverseName)
+	filtered.RelPath = t.RelPath
+	filtered.Label = t.Label
+	filtered.IsChildModule = t.IsChildModule
+	filtered.Variables = t.Variables
+	filtered.Locals = t.Locals
+	filtered.ProviderLocks = t.ProviderLocks
+	filtered.Dependencies = t.Dependencies
+	filtered.Deployments = t.Deployments
+	filtered.Truncated = t.Truncated
+	filtered.Walked = t.Walked
+	filtered.Parsed = t.Parsed
+
+	copies[t] = filtered
+
+	for resourceKey, resource := range t.Resources {
+		if typeRegexp.MatchString(resource.Type) && nameRegexp.MatchString(resource.Name) {
+			filtered.Resources[resourceKey] = resource
+		}
+	}
+
+	for moduleKey, module := range t.Modules {
+		filteredModule := *module
+		if module.TfPath != nil {
+			filteredModule.TfPath = module.TfPath.filterResources(typeRegexp, nameRegexp, copies)
+		}
+
+		filtered.Modules[moduleKey] = &filteredModule
+	}
+
+	for childKey, childTfPath := range t.Children {
+		if childTfPath == nil {
+			continue
+		}
+
+		filtered.Children[childKey] = childTfPath.filterResources(typeRegexp, nameRegexp, copies)
+	}
+
+	return filtered
+}
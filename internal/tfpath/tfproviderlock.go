@@ -0,0 +1,9 @@
+package tfpath
+
+// TfProviderLock represents a provider entry found in the '.terraform.lock.hcl' file.
+type TfProviderLock struct {
+	Source      string   `json:"-"`
+	Version     string   `json:"version"`
+	Constraints string   `json:"constraints,omitempty"`
+	Hashes      []string `json:"hashes,omitempty"`
+}
@@ -1,5 +1,7 @@
 package tfpath
 
+import "github.com/hashicorp/hcl/v2"
+
 // TfResource represents a Terraform resource.
 type TfResource struct {
 	Type         string
@@ -8,4 +10,21 @@ type TfResource struct {
 	FilePath     string
 	FieldName    string
 	FieldForEach string
+	FieldCount   string
+	// ForEachExpr, CountExpr and NameExpr are expressions of 'for_each', 'count' and the display attribute, kept
+	// to evaluate them with values of variables
+	ForEachExpr hcl.Expression
+	CountExpr   hcl.Expression
+	NameExpr    hcl.Expression
+	// ForEachSource is the whole 'for_each' expression as it is in the code, while FieldForEach is set only for
+	// lists and references
+	ForEachSource string
+	// LineStart and LineEnd are lines of the 'resource' block in the file
+	LineStart int
+	LineEnd   int
+}
+
+// HasDisplayAttribute checks if any of the display attributes was found in the resource and was not empty.
+func (r *TfResource) HasDisplayAttribute() bool {
+	return r.FieldName != "" && r.FieldName != labelNoFieldName && r.FieldName != labelFieldNameEmpty
 }
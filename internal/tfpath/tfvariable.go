@@ -0,0 +1,15 @@
+package tfpath
+
+import "github.com/zclconf/go-cty/cty"
+
+// TfVariable represents a 'variable' block of Terraform code.
+type TfVariable struct {
+	Name     string
+	FileName string
+	FilePath string
+	// Default is the value of the 'default' attribute, or cty.NilVal when it is not set or cannot be evaluated
+	Default cty.Value
+	// LineStart and LineEnd are lines of the 'variable' block in the file
+	LineStart int
+	LineEnd   int
+}
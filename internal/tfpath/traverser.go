@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
@@ -17,11 +18,22 @@ import (
 
 const (
 	tfExtension             = ".tf"
+	tfLockFileName          = ".terraform.lock.hcl"
 	linkModulesMaxRecursion = 5
 	labelNoFieldName        = "no-attr!"
 	labelFieldNameEmpty     = "empty!"
 )
 
+const (
+	// DefaultIgnoreDirRegexp matches names of directories that are not scanned by default
+	DefaultIgnoreDirRegexp = `^(example[s]*|test[s]*|\..*)$`
+	// DefaultModuleDirRegexp matches names of directories containing modules by default
+	DefaultModuleDirRegexp = `^modules$`
+)
+
+// moduleMetaArguments are attributes of a module block that are not variables of the module.
+var moduleMetaArguments = []string{"source", "version", "for_each", "count", "providers", "depends_on"}
+
 // Traverser represents functionality for scanning a Terraform directory.
 type Traverser struct {
 	// RegexpIgnoreDir is a regular expression used to check if directory name should be ignored
@@ -56,8 +68,8 @@ func NewTraverser(
 ) *Traverser {
 	traverser := &Traverser{
 		Parser:             hclparse.NewParser(),
-		RegexpIgnoreDir:    regexp.MustCompile(`^(example[s]*|test[s]*|\..*)$`),
-		RegexpModuleDir:    regexp.MustCompile(`^modules$`),
+		RegexpIgnoreDir:    regexp.MustCompile(DefaultIgnoreDirRegexp),
+		RegexpModuleDir:    regexp.MustCompile(DefaultModuleDirRegexp),
 		Container:          container,
 		RegexpIncludePath:  regexp.MustCompile(pathIncludeRegexp),
 		RegexpExcludePath:  regexp.MustCompile(pathExcludeRegexp),
@@ -270,6 +282,22 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 			containerPathKey := fmt.Sprintf("%s@%s", source, version)
 
 			containerTfPath, exists := t.Container.Paths[containerPathKey]
+			if !exists && t.Container.IsIgnored(containerPathKey) {
+				module.Resolution = ResolutionIgnored
+
+				slog.Debug(
+					fmt.Sprintf(
+						"🚫 Skipped linking child terraform path 📁%s (📦%s) module %s due to source 📦%s being ignored",
+						childTfPath.Path,
+						childTfPath.TraverseName,
+						moduleName,
+						containerPathKey,
+					),
+				)
+
+				continue
+			}
+
 			if !exists {
 				slog.Info(
 					fmt.Sprintf(
@@ -281,10 +309,24 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 					),
 				)
 
+				module.ResolutionError = "source " + containerPathKey + " not found in the container"
+
+				resolution := t.Container.resolutionFor(containerPathKey)
+				if resolution != nil && resolution.Reason != "" {
+					module.ResolutionError = resolution.Reason
+				}
+
 				continue
 			}
 
 			module.TfPath = containerTfPath
+			module.Resolution = ResolutionOverride
+
+			resolution := t.Container.resolutionFor(containerPathKey)
+			if resolution != nil && resolution.Method != "" {
+				module.Resolution = resolution.Method
+			}
+
 			slog.Debug(
 				fmt.Sprintf(
 					"🟡 Linked module %s in path 📁%s (📦%s) to container path 📁%s (📦%s)",
@@ -313,10 +355,14 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 				),
 			)
 
+			module.ResolutionError = "problem with relative path: " + err.Error()
+
 			continue
 		}
 
 		if relPath == "" || relPath == "." {
+			module.ResolutionError = "module source points to its own path"
+
 			continue
 		}
 
@@ -327,6 +373,7 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 			for _, containerTfPath := range t.Container.Paths {
 				if containerTfPath.Path == cleanPath {
 					module.TfPath = containerTfPath
+					module.Resolution = ResolutionLocal
 
 					slog.Debug(
 						fmt.Sprintf(
@@ -354,6 +401,7 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 		if exists {
 			if module.TfPath == nil {
 				module.TfPath = moduleTfPath
+				module.Resolution = ResolutionLocal
 
 				slog.Debug(
 					fmt.Sprintf(
@@ -371,7 +419,7 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 		}
 
 		// if inside a module and module path does not contain '//modules' already then search in the container
-		if rootTfParent.TraverseName != "." &&
+		if !t.Container.IsRoot(rootTfParent.TraverseName) &&
 			!strings.Contains(rootTfParent.TraverseName, "//modules/") &&
 			strings.HasPrefix(source, "./modules/") {
 			rootTfPathModule := strings.Split(rootTfParent.TraverseName, "@")
@@ -383,6 +431,7 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 			containerTfPath, exists := t.Container.Paths[moduleToSearch]
 			if exists {
 				module.TfPath = containerTfPath
+				module.Resolution = ResolutionLocal
 
 				slog.Debug(
 					fmt.Sprintf(
@@ -408,6 +457,10 @@ func (t *Traverser) link(rootTfParent *TfPath, childTfPath *TfPath) {
 				relPath,
 			),
 		)
+
+		if module.TfPath == nil {
+			module.ResolutionError = "relative path " + relPath + " not found in its parent"
+		}
 	}
 }
 
@@ -418,12 +471,25 @@ func (t *Traverser) parseFiles(tfPath *TfPath, foundModules *[]string) error {
 	}
 
 	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), tfExtension) {
+		if file.IsDir() {
 			continue
 		}
 
 		fileFullPath := filepath.Join(tfPath.Path, file.Name())
 
+		if isStackFile(file.Name()) {
+			err := t.parseStackFile(tfPath, file.Name(), foundModules)
+			if err != nil {
+				slog.Error(fmt.Sprintf("❌ Error parsing stack file 📄%s: %s", fileFullPath, err.Error()))
+			}
+
+			continue
+		}
+
+		if !strings.HasSuffix(file.Name(), tfExtension) {
+			continue
+		}
+
 		err := t.parseFile(tfPath, file.Name(), foundModules)
 		if err != nil {
 			slog.Error(fmt.Sprintf("❌ Error parsing file 📄%s: %s", fileFullPath, err.Error()))
@@ -433,6 +499,73 @@ func (t *Traverser) parseFiles(tfPath *TfPath, foundModules *[]string) error {
 		}
 	}
 
+	err = t.parseTerragruntFile(tfPath, foundModules)
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf(
+				"❌ Error parsing terragrunt file 📄%s: %s",
+				filepath.Join(tfPath.Path, terragruntFileName),
+				err.Error(),
+			),
+		)
+	}
+
+	err = t.parseLockFile(tfPath)
+	if err != nil {
+		slog.Error(
+			fmt.Sprintf(
+				"❌ Error parsing lock file 📄%s: %s",
+				filepath.Join(tfPath.Path, tfLockFileName),
+				err.Error(),
+			),
+		)
+	}
+
+	return nil
+}
+
+func (t *Traverser) parseLockFile(tfPath *TfPath) error {
+	filePath := filepath.Join(tfPath.Path, tfLockFileName)
+
+	_, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("error checking lock file: %s", err.Error())
+	}
+
+	hclFile, diags := t.Parser.ParseHCLFile(filePath)
+	if diags.HasErrors() {
+		return fmt.Errorf("error parsing hcl file: %s", diags.Error())
+	}
+
+	content, _, _ := hclFile.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "provider", LabelNames: []string{"source"}},
+		},
+	})
+
+	for _, block := range content.Blocks {
+		if len(block.Labels) != 1 || block.Type != "provider" {
+			continue
+		}
+
+		providerLock := t.parseHCLBlockProviderLock(block)
+		tfPath.ProviderLocks[providerLock.Source] = providerLock
+
+		slog.Info(
+			fmt.Sprintf(
+				"🔒 Found provider lock %s at version %s in file 📄%s (📦%s)",
+				providerLock.Source,
+				providerLock.Version,
+				filePath,
+				tfPath.TraverseName,
+			),
+		)
+	}
+
 	return nil
 }
 
@@ -449,10 +582,30 @@ func (t *Traverser) parseFile(tfPath *TfPath, fileName string, foundModules *[]s
 		Blocks: []hcl.BlockHeaderSchema{
 			{Type: "resource", LabelNames: []string{"kind", "name"}},
 			{Type: "module", LabelNames: []string{"name"}},
+			{Type: "variable", LabelNames: []string{"name"}},
+			{Type: "locals"},
 		},
 	})
 
 	for _, block := range content.Blocks {
+		if len(block.Labels) == 1 && block.Type == "variable" {
+			variable := parseHCLBlockVariable(block)
+			variable.FileName = fileName
+			variable.FilePath = filePath
+			tfPath.Variables[variable.Name] = variable
+
+			continue
+		}
+
+		if block.Type == "locals" {
+			attrs, _ := block.Body.JustAttributes()
+			for attrName, attr := range attrs {
+				tfPath.Locals[attrName] = attr.Expr
+			}
+
+			continue
+		}
+
 		if len(block.Labels) == 2 && block.Type == "resource" {
 			resource := t.parseHCLBlockResource(block)
 			if resource == nil {
@@ -489,51 +642,13 @@ func (t *Traverser) parseFile(tfPath *TfPath, fileName string, foundModules *[]s
 				continue
 			}
 
-			if module.FieldSource == "../" {
-				slog.Debug(
-					fmt.Sprintf(
-						"🚫 Ignoring module %s with source '../' in 📄%s",
-						module.Name,
-						filePath,
-					),
-				)
-
-				continue
-			}
-
-			foundModuleSource := module.FieldSource
-			foundModuleVersion := module.FieldVersion
-
-			// If source field targets a module then we need to cut it out
-			if strings.Contains(module.FieldSource, "//modules") {
-				sourceSplit := strings.Split(module.FieldSource, "//")
-				if sourceSplit[0] == "" {
-					continue
-				}
-
-				foundModuleSource = sourceSplit[0]
-			}
-
 			module.FileName = fileName
 			module.FilePath = filePath
 
-			tfPath.Modules[module.Name] = module
-
-			if foundModules != nil {
-				*foundModules = append(*foundModules, foundModuleSource + "@" + foundModuleVersion)
+			if !t.addModule(tfPath, module, foundModules) {
+				continue
 			}
 
-			slog.Info(
-				fmt.Sprintf(
-					"🔵 Found module %s [%s@%s] in file 📄%s (📦%s)",
-					module.Name,
-					module.FieldSource,
-					module.FieldVersion,
-					filePath,
-					tfPath.TraverseName,
-				),
-			)
-
 			if module.FieldForEach != "" {
 				slog.Info(
 					fmt.Sprintf(
@@ -549,6 +664,54 @@ func (t *Traverser) parseFile(tfPath *TfPath, fileName string, foundModules *[]s
 	return nil
 }
 
+// addModule adds the module call to the path, and its source to found modules so that it is added to the container,
+// and returns false when the module is ignored.
+func (t *Traverser) addModule(tfPath *TfPath, module *TfModule, foundModules *[]string) bool {
+	if module.FieldSource == "../" {
+		slog.Debug(
+			fmt.Sprintf(
+				"🚫 Ignoring module %s with source '../' in 📄%s",
+				module.Name,
+				module.FilePath,
+			),
+		)
+
+		return false
+	}
+
+	foundModuleSource := module.FieldSource
+	foundModuleVersion := module.FieldVersion
+
+	// If source field targets a module then we need to cut it out
+	if strings.Contains(module.FieldSource, "//modules") {
+		sourceSplit := strings.Split(module.FieldSource, "//")
+		if sourceSplit[0] == "" {
+			return false
+		}
+
+		foundModuleSource = sourceSplit[0]
+	}
+
+	tfPath.Modules[module.Name] = module
+
+	if foundModules != nil {
+		*foundModules = append(*foundModules, foundModuleSource+"@"+foundModuleVersion)
+	}
+
+	slog.Info(
+		fmt.Sprintf(
+			"🔵 Found module %s [%s@%s] in file 📄%s (📦%s)",
+			module.Name,
+			module.FieldSource,
+			module.FieldVersion,
+			module.FilePath,
+			tfPath.TraverseName,
+		),
+	)
+
+	return true
+}
+
 func (t *Traverser) parseHCLBlockResource(block *hcl.Block) *TfResource {
 	resourceType := block.Labels[0]
 
@@ -573,6 +736,16 @@ func (t *Traverser) parseHCLBlockResource(block *hcl.Block) *TfResource {
 	forEachField, _ := t.getForEachFromHCLBlock(block)
 	resourceInstance.FieldForEach = forEachField
 
+	countField, _ := t.getCountFromHCLBlock(block)
+	resourceInstance.FieldCount = countField
+
+	resourceInstance.ForEachExpr = t.getExprFromHCLBlock(block, "for_each")
+	resourceInstance.ForEachSource = exprSource(resourceInstance.ForEachExpr)
+	resourceInstance.CountExpr = t.getExprFromHCLBlock(block, "count")
+	resourceInstance.NameExpr = t.getExprFromHCLBlock(block, t.DisplayAttributes...)
+
+	resourceInstance.LineStart, resourceInstance.LineEnd = blockLines(block)
+
 	return resourceInstance
 }
 
@@ -590,9 +763,89 @@ func (t *Traverser) parseHCLBlockModule(block *hcl.Block) *TfModule {
 	forEachField, _ := t.getForEachFromHCLBlock(block)
 	moduleInstance.FieldForEach = forEachField
 
+	countField, _ := t.getCountFromHCLBlock(block)
+	moduleInstance.FieldCount = countField
+
+	moduleInstance.ForEachExpr = t.getExprFromHCLBlock(block, "for_each")
+	moduleInstance.ForEachSource = exprSource(moduleInstance.ForEachExpr)
+	moduleInstance.Arguments = moduleArguments(block)
+	moduleInstance.CountExpr = t.getExprFromHCLBlock(block, "count")
+
+	moduleInstance.LineStart, moduleInstance.LineEnd = blockLines(block)
+
 	return moduleInstance
 }
 
+func parseHCLBlockVariable(block *hcl.Block) *TfVariable {
+	variable := &TfVariable{
+		Name:    block.Labels[0],
+		Default: cty.NilVal,
+	}
+
+	bodyContent, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "default", Required: false},
+		},
+	})
+
+	attr, exists := bodyContent.Attributes["default"]
+	if exists {
+		value, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && value.IsWhollyKnown() {
+			variable.Default = value
+		}
+	}
+
+	variable.LineStart, variable.LineEnd = blockLines(block)
+
+	return variable
+}
+
+func (t *Traverser) parseHCLBlockProviderLock(block *hcl.Block) *TfProviderLock {
+	providerLock := &TfProviderLock{
+		Source: block.Labels[0],
+		Hashes: []string{},
+	}
+
+	bodyContent, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "version", Required: false},
+			{Name: "constraints", Required: false},
+			{Name: "hashes", Required: false},
+		},
+	})
+
+	for attrName, attr := range bodyContent.Attributes {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || value.IsNull() || !value.IsKnown() {
+			continue
+		}
+
+		switch attrName {
+		case "version":
+			if value.Type() == cty.String {
+				providerLock.Version = value.AsString()
+			}
+		case "constraints":
+			if value.Type() == cty.String {
+				providerLock.Constraints = value.AsString()
+			}
+		case "hashes":
+			if !value.CanIterateElements() {
+				continue
+			}
+
+			for _, hash := range value.AsValueSlice() {
+				if hash.Type() == cty.String && hash.IsKnown() && !hash.IsNull() {
+					providerLock.Hashes = append(providerLock.Hashes, hash.AsString())
+				}
+			}
+		}
+	}
+
+	return providerLock
+}
+
 //nolint:funlen
 func (t *Traverser) getNameFromHCLBlock(block *hcl.Block) (string, error) {
 	name := block.Labels[0]
@@ -718,6 +971,104 @@ func (t *Traverser) getForEachFromHCLBlock(block *hcl.Block) (string, error) {
 	return forEachField, nil
 }
 
+// getCountFromHCLBlock returns raw 'count' expression of the block.
+func (t *Traverser) getCountFromHCLBlock(block *hcl.Block) (string, error) {
+	name := block.Labels[0]
+
+	bodyContent, _, diags := block.Body.PartialContent(t.HCLBodySchema)
+	if diags.HasErrors() {
+		return "", fmt.Errorf(
+			"error getting partial content: %s.%s: %s",
+			block.Type,
+			name,
+			diags.Error(),
+		)
+	}
+
+	attr, exists := bodyContent.Attributes["count"]
+	if !exists {
+		return "", nil
+	}
+
+	srcRange := attr.Expr.Range()
+
+	source, err := os.ReadFile(srcRange.Filename)
+	if err != nil {
+		return "", fmt.Errorf("error reading count of %s.%s: %w", block.Type, name, err)
+	}
+
+	return string(source[srcRange.Start.Byte:srcRange.End.Byte]), nil
+}
+
+// getExprFromHCLBlock returns expression of the first of the attributes found in the block, or nil when there is
+// none.
+func (t *Traverser) getExprFromHCLBlock(block *hcl.Block, attrNames ...string) hcl.Expression {
+	bodyContent, _, diags := block.Body.PartialContent(t.HCLBodySchema)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	for _, attrName := range attrNames {
+		attr, exists := bodyContent.Attributes[attrName]
+		if exists {
+			return attr.Expr
+		}
+	}
+
+	return nil
+}
+
+// moduleArguments returns expressions of the attributes of the module block that are not meta-arguments, ie.
+// of the variables set in the module call.
+func moduleArguments(block *hcl.Block) map[string]hcl.Expression {
+	arguments := map[string]hcl.Expression{}
+
+	// nested blocks, eg. 'lifecycle', do not matter, so attributes are taken from the syntax tree
+	body, ok := block.Body.(*hclsyntax.Body)
+	if !ok {
+		return arguments
+	}
+
+	for attrName, attr := range body.Attributes {
+		if slices.Contains(moduleMetaArguments, attrName) {
+			continue
+		}
+
+		arguments[attrName] = attr.Expr
+	}
+
+	return arguments
+}
+
+// exprSource returns the expression as it is in the code, or an empty string when there is no expression.
+func exprSource(expr hcl.Expression) string {
+	if expr == nil {
+		return ""
+	}
+
+	srcRange := expr.Range()
+
+	source, err := os.ReadFile(srcRange.Filename)
+	if err != nil || srcRange.End.Byte > len(source) {
+		return ""
+	}
+
+	return string(source[srcRange.Start.Byte:srcRange.End.Byte])
+}
+
+// blockLines returns the first and the last line of the block.
+func blockLines(block *hcl.Block) (int, int) {
+	lineStart := block.DefRange.Start.Line
+	lineEnd := block.DefRange.End.Line
+
+	body, ok := block.Body.(*hclsyntax.Body)
+	if ok {
+		lineEnd = body.SrcRange.End.Line
+	}
+
+	return lineStart, lineEnd
+}
+
 func (t *Traverser) getSourceFromHCLBlock(block *hcl.Block) (string, string, error) {
 	name := block.Labels[0]
 
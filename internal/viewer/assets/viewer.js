@@ -0,0 +1,252 @@
+// tfsketch viewer: renders the tree returned by /api/tree, and the Mermaid diagram with the mermaid served by tfsketch.
+(function () {
+  "use strict";
+
+  var zoom = 1;
+  var currentView = "tree";
+  var lastResponse = null;
+  var mermaidLoaded = false;
+
+  var content = document.getElementById("content");
+  var treeView = document.getElementById("tree");
+  var mermaidView = document.getElementById("mermaid");
+  var sourceView = document.getElementById("source");
+  var details = document.getElementById("details");
+  var errorBox = document.getElementById("error");
+  var search = document.getElementById("search");
+  var filters = document.getElementById("filters");
+
+  function showError(message) {
+    errorBox.textContent = message;
+    errorBox.hidden = !message;
+  }
+
+  function setZoom(value) {
+    zoom = Math.min(4, Math.max(0.2, value));
+    content.style.transform = "scale(" + zoom + ")";
+    document.querySelector("[data-zoom=reset]").textContent = Math.round(zoom * 100) + "%";
+  }
+
+  function showDetails(node, element) {
+    var selected = document.querySelector(".node.selected");
+    if (selected) {
+      selected.classList.remove("selected");
+    }
+    element.classList.add("selected");
+
+    details.innerHTML = "";
+    var title = document.createElement("h3");
+    title.textContent = node.kind + ": " + node.label;
+    details.appendChild(title);
+
+    var list = document.createElement("dl");
+    Object.keys(node.details || {}).sort().forEach(function (key) {
+      var dt = document.createElement("dt");
+      dt.textContent = key;
+      var dd = document.createElement("dd");
+      dd.textContent = node.details[key];
+      list.appendChild(dt);
+      list.appendChild(dd);
+    });
+    details.appendChild(list);
+  }
+
+  function renderNode(node) {
+    var item = document.createElement("li");
+    item.dataset.label = node.label.toLowerCase();
+
+    var toggle = document.createElement("span");
+    toggle.className = "toggle";
+    item.appendChild(toggle);
+
+    var element = document.createElement("span");
+    element.className = "node " + node.kind;
+    element.textContent = node.label;
+    element.addEventListener("click", function () {
+      showDetails(node, element);
+    });
+    item.appendChild(element);
+
+    if (node.children && node.children.length > 0) {
+      toggle.textContent = "▾";
+      toggle.addEventListener("click", function () {
+        item.classList.toggle("collapsed");
+        toggle.textContent = item.classList.contains("collapsed") ? "▸" : "▾";
+      });
+
+      var list = document.createElement("ul");
+      list.className = "tree";
+      node.children.forEach(function (child) {
+        list.appendChild(renderNode(child));
+      });
+      item.appendChild(list);
+
+      // modules are collapsed at first so that big trees stay readable
+      if (node.kind === "module") {
+        item.classList.add("collapsed");
+        toggle.textContent = "▸";
+      }
+    }
+
+    return item;
+  }
+
+  function renderTree(tree) {
+    treeView.innerHTML = "";
+    var list = document.createElement("ul");
+    list.className = "tree";
+    list.appendChild(renderNode(tree));
+    treeView.appendChild(list);
+    applySearch();
+  }
+
+  // applySearch hides elements that do not match, and expands the ones with matching descendants
+  function applySearch() {
+    var text = search.value.trim().toLowerCase();
+
+    function visit(item) {
+      var element = item.querySelector(":scope > .node");
+      var matches = text !== "" && item.dataset.label.indexOf(text) !== -1;
+      var childMatches = false;
+
+      item.querySelectorAll(":scope > ul > li").forEach(function (child) {
+        if (visit(child)) {
+          childMatches = true;
+        }
+      });
+
+      element.classList.toggle("match", matches);
+      item.classList.toggle("hidden", text !== "" && !matches && !childMatches);
+
+      if (text !== "" && childMatches) {
+        item.classList.remove("collapsed");
+      }
+
+      return matches || childMatches;
+    }
+
+    treeView.querySelectorAll(":scope > ul > li").forEach(visit);
+
+    if (currentView === "mermaid") {
+      mermaidView.querySelectorAll("g.node").forEach(function (element) {
+        var matches = text !== "" && element.textContent.toLowerCase().indexOf(text) !== -1;
+        element.style.opacity = text === "" || matches ? "1" : "0.25";
+      });
+    }
+  }
+
+  function loadMermaid(callback) {
+    if (mermaidLoaded) {
+      callback();
+      return;
+    }
+
+    var script = document.createElement("script");
+    script.src = "/static/mermaid.min.js";
+    script.onload = function () {
+      mermaidLoaded = true;
+      window.mermaid.initialize({ startOnLoad: false, maxTextSize: 10000000, maxEdges: 100000 });
+      callback();
+    };
+    script.onerror = function () {
+      showError("Cannot load mermaid.min.js");
+    };
+    document.head.appendChild(script);
+  }
+
+  function renderMermaid() {
+    if (!lastResponse) {
+      return;
+    }
+
+    loadMermaid(function () {
+      window.mermaid.render("tfsketch-diagram", lastResponse.mermaid).then(function (result) {
+        mermaidView.innerHTML = result.svg;
+        applySearch();
+      }).catch(function (err) {
+        showError("Error rendering diagram: " + err);
+      });
+    });
+  }
+
+  function showView(view) {
+    currentView = view;
+    document.querySelectorAll(".tabs button").forEach(function (button) {
+      button.classList.toggle("active", button.dataset.view === view);
+    });
+    treeView.hidden = view !== "tree";
+    mermaidView.hidden = view !== "mermaid";
+    sourceView.hidden = view !== "source";
+
+    if (view === "mermaid") {
+      renderMermaid();
+    }
+  }
+
+  function load() {
+    var query = window.location.search;
+    var params = new URLSearchParams(query);
+    filters.elements.type.value = params.get("type") || "";
+    filters.elements.name.value = params.get("name") || "";
+
+    fetch("/api/tree" + query).then(function (response) {
+      return response.json();
+    }).then(function (data) {
+      if (data.error) {
+        showError(data.error);
+        return;
+      }
+
+      showError("");
+      lastResponse = data;
+      sourceView.textContent = data.mermaid;
+      renderTree(data.tree);
+
+      if (currentView === "mermaid") {
+        renderMermaid();
+      }
+    }).catch(function (err) {
+      showError("Error loading tree: " + err);
+    });
+  }
+
+  filters.addEventListener("submit", function (event) {
+    event.preventDefault();
+    var params = new URLSearchParams();
+    ["type", "name"].forEach(function (name) {
+      if (filters.elements[name].value) {
+        params.set(name, filters.elements[name].value);
+      }
+    });
+    var query = params.toString();
+    window.history.pushState(null, "", query ? "?" + query : window.location.pathname);
+    load();
+  });
+
+  search.addEventListener("input", applySearch);
+
+  document.querySelectorAll("[data-zoom]").forEach(function (button) {
+    button.addEventListener("click", function () {
+      var action = button.dataset.zoom;
+      setZoom(action === "in" ? zoom * 1.25 : action === "out" ? zoom / 1.25 : 1);
+    });
+  });
+
+  document.getElementById("canvas").addEventListener("wheel", function (event) {
+    if (!event.ctrlKey) {
+      return;
+    }
+    event.preventDefault();
+    setZoom(event.deltaY < 0 ? zoom * 1.1 : zoom / 1.1);
+  }, { passive: false });
+
+  document.querySelectorAll(".tabs button").forEach(function (button) {
+    button.addEventListener("click", function () {
+      showView(button.dataset.view);
+    });
+  });
+
+  window.addEventListener("popstate", load);
+
+  load();
+})();
@@ -0,0 +1,168 @@
+package viewer
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"tfsketch/internal/chart"
+	"tfsketch/internal/graph"
+	"tfsketch/internal/tfpath"
+)
+
+// mermaid.min.js is bundled so that the viewer works offline, and it is updated with 'go generate'.
+//
+//go:generate curl -fsSL -o assets/mermaid.min.js https://cdn.jsdelivr.net/npm/mermaid@11.4.1/dist/mermaid.min.js
+
+//go:embed assets
+var assets embed.FS
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	anyRegexp         = "^.*$"
+	// mermaidJSFile is the bundled Mermaid in assets, served from '/static/'
+	mermaidJSFile = "mermaid.min.js"
+)
+
+// ErrMermaidJSMissing is returned when mermaid.min.js is not bundled, as 'go generate' was not run before building,
+// and no other file is passed.
+var ErrMermaidJSMissing = errors.New("mermaid.min.js is not bundled, run 'go generate ./internal/viewer' and build " +
+	"again, or pass a local file")
+
+// Server serves the viewer page and the tree of the scanned path, filtered with regular expressions passed in the
+// query, from the in-memory paths.
+type Server struct {
+	rootTfPath *tfpath.TfPath
+	options    chart.Options
+	mermaidJS  []byte
+}
+
+// treeResponse is returned by the tree endpoint.
+type treeResponse struct {
+	Tree    *Node  `json:"tree"`
+	Mermaid string `json:"mermaid"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewServer returns a Server instance drawing the tree and the chart as set in options. Mermaid is rendered in the
+// browser with the bundled 'mermaid.min.js', or with a local file when mermaidJSPath is set.
+func NewServer(rootTfPath *tfpath.TfPath, options chart.Options, mermaidJSPath string) (*Server, error) {
+	server := &Server{
+		rootTfPath: rootTfPath,
+		options:    options,
+	}
+
+	var err error
+
+	if mermaidJSPath != "" {
+		server.mermaidJS, err = os.ReadFile(filepath.Clean(mermaidJSPath))
+		if err != nil {
+			return nil, fmt.Errorf("error reading mermaid js: %w", err)
+		}
+
+		return server, nil
+	}
+
+	server.mermaidJS, err = assets.ReadFile(path.Join("assets", mermaidJSFile))
+	if err != nil {
+		return nil, ErrMermaidJSMissing
+	}
+
+	return server, nil
+}
+
+// Handler returns the HTTP handler with the page, its static files and the tree endpoint.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	staticFS, _ := fs.Sub(assets, "assets")
+
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(staticFS)))
+	mux.HandleFunc("GET /static/"+mermaidJSFile, s.handleMermaidJS)
+	mux.HandleFunc("GET /api/tree", s.handleTree)
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, staticFS, "index.html")
+	})
+
+	return mux
+}
+
+// ListenAndServe serves the viewer on the address until the server fails.
+func (s *Server) ListenAndServe(address string) error {
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	err := httpServer.ListenAndServe()
+	if err != nil {
+		return fmt.Errorf("error serving viewer: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) handleMermaidJS(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	_, _ = w.Write(s.mermaidJS)
+}
+
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	typeRegexp, err := queryRegexp(r, "type")
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, &treeResponse{Error: "invalid type regexp: " + err.Error()})
+
+		return
+	}
+
+	nameRegexp, err := queryRegexp(r, "name")
+	if err != nil {
+		s.writeJSON(w, http.StatusBadRequest, &treeResponse{Error: "invalid name regexp: " + err.Error()})
+
+		return
+	}
+
+	filteredTfPath := s.rootTfPath.FilterResources(typeRegexp, nameRegexp)
+
+	chartGraph := graph.NewBuilder(s.options.Options)
+	flowchart := chart.NewMermaidFlowChart(s.options)
+
+	s.writeJSON(w, http.StatusOK, &treeResponse{
+		Tree:    newTree(chartGraph.Build([]*tfpath.TfPath{filteredTfPath})),
+		Mermaid: flowchart.Render([]*tfpath.TfPath{filteredTfPath}),
+	})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, response *treeResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		slog.Error("❌ Error writing response: " + err.Error())
+	}
+}
+
+func queryRegexp(r *http.Request, name string) (*regexp.Regexp, error) {
+	expr := r.URL.Query().Get(name)
+	if expr == "" {
+		expr = anyRegexp
+	}
+
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,85 @@
+// Package viewer contains an HTTP server with an interactive diagram viewer.
+package viewer
+
+import (
+	"tfsketch/internal/graph"
+)
+
+// Node is an element of the tree shown in the viewer: a path, a module or a resource.
+type Node struct {
+	ID       string            `json:"id"`
+	Kind     string            `json:"kind"`
+	Label    string            `json:"label"`
+	Details  map[string]string `json:"details"`
+	Children []*Node           `json:"children"`
+}
+
+// newTree returns the tree with the same paths and modules that are drawn on the chart.
+func newTree(chartGraph *graph.Graph) *Node {
+	root := treeNode(chartGraph.Roots[0])
+
+	for _, pathNode := range chartGraph.Roots[1:] {
+		root.Children = append(root.Children, treeNode(pathNode))
+	}
+
+	return root
+}
+
+func treeNode(graphNode *graph.Node) *Node {
+	node := &Node{
+		ID:       graphNode.ID,
+		Kind:     string(graphNode.Kind),
+		Details:  map[string]string{},
+		Children: []*Node{},
+	}
+
+	details := map[string]string{
+		"path":       graphNode.Path,
+		"type":       graphNode.Type,
+		"name":       graphNode.Name,
+		"file":       graphNode.FilePath,
+		"source":     graphNode.Source,
+		"version":    graphNode.Version,
+		"resolution": graphNode.Resolution,
+		"for_each":   graphNode.ForEach,
+		"count":      graphNode.Count,
+		"reason":     graphNode.Reason,
+	}
+
+	for key, value := range details {
+		if value != "" {
+			node.Details[key] = value
+		}
+	}
+
+	switch graphNode.Kind {
+	case graph.KindPath:
+		node.Label = graphNode.RelPath
+		if node.Label == "" {
+			node.Label = "."
+		}
+	case graph.KindModule:
+		node.Label = graphNode.ModuleAddress() + " " + graphNode.Source
+		if graphNode.Label != "" {
+			node.Label = graphNode.ModuleAddress() + " " + graphNode.Label
+		}
+	case graph.KindCycle, graph.KindTruncated:
+		node.Label = graphNode.ModuleAddress() + " " + graphNode.Source + " (" + graphNode.Reason + ")"
+	case graph.KindDeployment:
+		node.Label = "deployment." + graphNode.Name
+	default:
+		node.Label = graphNode.Type + "." + graphNode.Name + " " + graphNode.DisplayName
+		node.Details["displayName"] = graphNode.DisplayName
+	}
+
+	for _, child := range graphNode.Children {
+		// names are shown in resource labels
+		if child.Kind == graph.KindName {
+			continue
+		}
+
+		node.Children = append(node.Children, treeNode(child))
+	}
+
+	return node
+}
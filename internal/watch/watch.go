@@ -0,0 +1,77 @@
+// Package watch contains code that reports directories with changed Terraform files.
+package watch
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	tfExtension = ".tf"
+	// hclExtension covers lock files, Terragrunt files and Terraform Stacks files
+	hclExtension = ".hcl"
+
+	// idleTimeout is how often cancellation is checked when nothing changes.
+	idleTimeout = 200 * time.Millisecond
+)
+
+// OnChange is called with directories that have changed files, sorted alphabetically.
+type OnChange func(dirs []string)
+
+// isWatchedFile checks if a change of the file should trigger a re-scan.
+func isWatchedFile(name string) bool {
+	return strings.HasSuffix(name, tfExtension) || strings.HasSuffix(name, hclExtension)
+}
+
+// debouncer collects changed directories until there are no changes for a while.
+type debouncer struct {
+	delay      time.Duration
+	dirs       map[string]struct{}
+	lastChange time.Time
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{
+		delay: delay,
+		dirs:  map[string]struct{}{},
+	}
+}
+
+func (d *debouncer) add(dir string) {
+	d.dirs[filepath.Clean(dir)] = struct{}{}
+	d.lastChange = time.Now()
+}
+
+// timeout returns how long to wait for more changes.
+func (d *debouncer) timeout() time.Duration {
+	if len(d.dirs) == 0 {
+		return idleTimeout
+	}
+
+	remaining := d.delay - time.Since(d.lastChange)
+	if remaining < 0 {
+		return 0
+	}
+
+	return min(remaining, idleTimeout)
+}
+
+// flush returns collected directories when there were no changes for the delay.
+func (d *debouncer) flush() []string {
+	if len(d.dirs) == 0 || time.Since(d.lastChange) < d.delay {
+		return nil
+	}
+
+	dirs := make([]string, 0, len(d.dirs))
+	for dir := range d.dirs {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	d.dirs = map[string]struct{}{}
+
+	return dirs
+}
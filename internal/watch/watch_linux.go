@@ -0,0 +1,116 @@
+//go:build linux
+
+package watch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrInitialisingInotify = errors.New("error initialising inotify")
+	ErrReadingInotify      = errors.New("error reading inotify events")
+)
+
+const (
+	inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM |
+		unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+	inotifyBufferSize = 64 * (unix.SizeofInotifyEvent + unix.NAME_MAX + 1)
+)
+
+// Watch uses inotify to watch dirs (not recursively), and calls onChange with directories where Terraform files
+// changed, once there were no more changes for the debounce delay. New sub-directories are watched too. It blocks
+// until ctx is cancelled.
+//
+//nolint:funlen,gocognit
+func Watch(ctx context.Context, dirs []string, debounce time.Duration, onChange OnChange) error {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInitialisingInotify, err)
+	}
+
+	defer unix.Close(fd)
+
+	watchedDirs := map[int]string{}
+
+	addWatch := func(dir string) {
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("❗ Cannot watch 📁%s: %s", dir, err.Error()))
+
+			return
+		}
+
+		watchedDirs[wd] = filepath.Clean(dir)
+
+		slog.Debug(fmt.Sprintf("👀 Watching 📁%s", dir))
+	}
+
+	for _, dir := range dirs {
+		addWatch(dir)
+	}
+
+	debouncer := newDebouncer(debounce)
+	buffer := make([]byte, inotifyBufferSize)
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		pollFds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}} //nolint:gosec
+
+		_, err := unix.Poll(pollFds, int(debouncer.timeout().Milliseconds()))
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return fmt.Errorf("%w: %w", ErrReadingInotify, err)
+		}
+
+		if pollFds[0].Revents&unix.POLLIN != 0 {
+			readBytes, err := unix.Read(fd, buffer)
+			if err != nil && !errors.Is(err, unix.EAGAIN) {
+				return fmt.Errorf("%w: %w", ErrReadingInotify, err)
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= readBytes; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset])) //nolint:gosec
+				nameBytes := buffer[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				name := string(bytes.TrimRight(nameBytes, "\x00"))
+
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				dir, exists := watchedDirs[int(event.Wd)]
+				if !exists {
+					continue
+				}
+
+				switch {
+				case event.Mask&unix.IN_DELETE_SELF != 0:
+					delete(watchedDirs, int(event.Wd))
+					debouncer.add(dir)
+				case event.Mask&unix.IN_ISDIR != 0:
+					// new directory is reported as itself, so that it is walked, and removed one as itself too
+					if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+						addWatch(filepath.Join(dir, name))
+					}
+
+					debouncer.add(filepath.Join(dir, name))
+				case isWatchedFile(name):
+					debouncer.add(dir)
+				}
+			}
+		}
+
+		changedDirs := debouncer.flush()
+		if len(changedDirs) > 0 {
+			onChange(changedDirs)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+//go:build !linux
+
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watch checks modification times of Terraform files in dirs (not recursively), and calls onChange with
+// directories where they changed, once there were no more changes for the debounce delay. It blocks until ctx is
+// cancelled. inotify is used on Linux instead.
+func Watch(ctx context.Context, dirs []string, debounce time.Duration, onChange OnChange) error {
+	snapshots := map[string]map[string]time.Time{}
+	for _, dir := range dirs {
+		snapshots[dir] = dirSnapshot(dir)
+	}
+
+	debouncer := newDebouncer(debounce)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(debouncer.timeout()):
+		}
+
+		for _, dir := range dirs {
+			snapshot := dirSnapshot(dir)
+			if !isSameSnapshot(snapshots[dir], snapshot) {
+				snapshots[dir] = snapshot
+				debouncer.add(dir)
+			}
+		}
+
+		changedDirs := debouncer.flush()
+		if len(changedDirs) > 0 {
+			onChange(changedDirs)
+		}
+	}
+}
+
+func dirSnapshot(dir string) map[string]time.Time {
+	snapshot := map[string]time.Time{}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return snapshot
+	}
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			snapshot[dirEntry.Name()+string(filepath.Separator)] = time.Time{}
+
+			continue
+		}
+
+		if !isWatchedFile(dirEntry.Name()) {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		snapshot[dirEntry.Name()] = info.ModTime()
+	}
+
+	return snapshot
+}
+
+func isSameSnapshot(snapshotA, snapshotB map[string]time.Time) bool {
+	if len(snapshotA) != len(snapshotB) {
+		return false
+	}
+
+	for name, modTime := range snapshotA {
+		otherModTime, exists := snapshotB[name]
+		if !exists || !otherModTime.Equal(modTime) {
+			return false
+		}
+	}
+
+	return true
+}
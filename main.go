@@ -6,21 +6,108 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"tfsketch/internal/chart"
+	"tfsketch/internal/config"
+	"tfsketch/internal/findings"
+	"tfsketch/internal/graph"
+	"tfsketch/internal/layout"
 	"tfsketch/internal/overrides"
 	"tfsketch/internal/tfpath"
 )
 
+const newFilesMode = 0o600
+
+const (
+	genFormatMermaid  = "mermaid"
+	genFormatHTML     = "html"
+	genFormatPlantUML = "plantuml"
+	genFormatD2       = "d2"
+)
+
 const (
 	exitCodeErrReadingOverridesFromFile = 10
 	exitCodeErrTraversingOverrides      = 11
+	exitCodeErrWritingOverridesFile     = 12
 	exitCodeErrParsingContainerPaths    = 21
 	exitCodeErrLinkingContainerPaths    = 22
+	exitCodeErrUnresolvedModules        = 23
+	exitCodeErrWritingResolutionReport  = 24
 	exitCodeErrGeneratingChart          = 41
+	exitCodeErrCreatingGitBackend       = 42
+	exitCodeErrWatchingPaths            = 43
+	exitCodeErrServingViewer            = 44
+	exitCodeErrInvalidServeArgs         = 45
+	exitCodeErrPruningCache             = 51
+	exitCodeErrVerifyingCache           = 52
+	exitCodeErrClearingCache            = 53
+	exitCodeErrCreatingTempCache        = 61
+	exitCodeErrMirrorIncomplete         = 62
+	exitCodeErrReadingRules             = 71
+	exitCodeErrRuleViolations           = 72
+	exitCodeErrWritingCheckOutput       = 73
+	exitCodeErrInvalidCheckArgs         = 74
+	exitCodeErrInvalidDiffArgs          = 81
+	exitCodeErrCheckingOutDiffRef       = 82
+	exitCodeErrReadingConfig            = 91
+	exitCodeErrInvalidGenArgs           = 92
+	exitCodeErrInvalidWhereArgs         = 101
+	exitCodeErrWritingWhereOutput       = 102
+	exitCodeErrInvalidDriftArgs         = 111
+	exitCodeErrWritingDriftOutput       = 112
+	exitCodeErrInvalidMatrixArgs        = 121
+	exitCodeErrReadingTfvars            = 122
+	exitCodeErrWritingMatrixOutput      = 123
 )
 
+// genOptions are values of the 'gen' flags passed to its handler.
+type genOptions struct {
+	outputFile   string
+	outputFormat string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+	typeRegexp        string
+	nameRegexp        string
+	displayAttributes string
+
+	overridesPath          string
+	cachePath              string
+	modulesMirrorPath      string
+	gitBackendName         string
+	resolutionReportPath   string
+	resolutionReportFormat string
+
+	cacheTTL      time.Duration
+	watchDebounce time.Duration
+
+	layoutName      string
+	ignoreDirRegexp string
+	moduleDirRegexp string
+	forEachMode     string
+
+	maxModulesDepth int
+	maxParseDepth   int
+	maxPathDepth    int
+	maxInstances    int
+
+	debug            bool
+	onlyRoot         bool
+	includeFilenames bool
+	minify           bool
+	module           bool
+	providerLocks    bool
+	offline          bool
+	strict           bool
+	watchPaths       bool
+}
+
 //nolint:funlen
 func main() {
 	rootCmd := &cobra.Command{
@@ -29,47 +116,96 @@ func main() {
 		Long:  "tfsketch generates diagrams from Terraform files",
 	}
 
-	var terraformPath string
-	var outputFile string
-	var pathIncludeRegexp, pathExcludeRegexp, typeRegexp, nameRegexp, displayAttributes string
-	var overridesPath, cachePath string
-	var debug, onlyRoot, includeFilenames, minify, module bool
+	var configPath string
+	var terraformPaths, viewNames []string
+	var allViews bool
+	var options genOptions
 
 	genCmd := &cobra.Command{
 		Use:   "gen",
 		Short: "Generate diagram",
 		Long:  "Generate diagrams based on Terraform files",
 		Run: func(cmd *cobra.Command, args []string) {
-			os.Exit(genHandler(cmd.Context(), debug, terraformPath, pathIncludeRegexp, pathExcludeRegexp, typeRegexp, nameRegexp, displayAttributes, outputFile, overridesPath, cachePath, onlyRoot, includeFilenames, minify, module))
+			rootNames, rootPaths, exitCode := parseRootPaths(terraformPaths)
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+
+			// with many paths, none of them is the project, so the config file is looked up where tfsketch is run
+			configDir := rootPaths[0]
+			if len(rootPaths) > 1 {
+				configDir = "."
+			}
+
+			views, exitCode := loadGenConfig(cmd, configDir, configPath, viewNames, allViews)
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+
+			os.Exit(genHandler(cmd.Context(), &options, rootNames, rootPaths, views))
 		},
 	}
 
-	genCmd.PersistentFlags().StringVarP(&terraformPath, "path", "", "", "Path to directory with terraform code (required)")
+	genCmd.PersistentFlags().StringSliceVarP(&terraformPaths, "path", "", []string{}, "Paths to directories with terraform code, optionally as 'name=path', drawn as groups when there are many (required)")
 	genCmd.MarkPersistentFlagRequired("path")
 	genCmd.MarkPersistentFlagDirname("path")
 
-	genCmd.PersistentFlags().StringVarP(&outputFile, "output", "", "", "Path to an output file (required)")
-	genCmd.MarkPersistentFlagRequired("output")
+	genCmd.PersistentFlags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (required unless views are generated)")
 	genCmd.MarkPersistentFlagFilename("output")
+	genCmd.Flags().StringVarP(&options.outputFormat, "format", "", genFormatMermaid, "Format of the output file: 'mermaid', 'html' (self-contained report), 'plantuml' or 'd2'")
+
+	genCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	genCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+	genCmd.Flags().StringVarP(&options.typeRegexp, "type-regexp", "t", "^.*$", "Regular expression to filter type of the resource")
+	genCmd.Flags().StringVarP(&options.nameRegexp, "name-regexp", "n", "^.*$", "Regular expression to filter name of the resource")
 
-	genCmd.Flags().StringVarP(&pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
-	genCmd.Flags().StringVarP(&pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
-	genCmd.Flags().StringVarP(&typeRegexp, "type-regexp", "t", "^.*$", "Regular expression to filter type of the resource")
-	genCmd.Flags().StringVarP(&nameRegexp, "name-regexp", "n", "^.*$", "Regular expression to filter name of the resource")
+	genCmd.Flags().StringVarP(&options.layoutName, "layout", "", layout.DefaultPreset, "Layout preset classifying directories: "+layoutPresetsHelp())
+	genCmd.Flags().StringVarP(&options.ignoreDirRegexp, "ignore-dir-regexp", "", "", "Regular expression matching names of directories that are not scanned (default from layout)")
+	genCmd.Flags().StringVarP(&options.moduleDirRegexp, "module-dir-regexp", "", "", "Regular expression matching names of directories with local modules (default from layout)")
+	genCmd.Flags().IntVarP(&options.maxPathDepth, "max-path-depth", "", 0, "Depth of sub-directories drawn, 1 being the first-level ones only (default from layout)")
 
 	genCmd.Flags().StringVarP(
-		&displayAttributes, "display-attributes", "a", "",
+		&options.displayAttributes, "display-attributes", "a", "",
 		"Comma-separated resource attributes; the first found is used as the chart’s display name",
 	)
-	genCmd.Flags().StringVarP(&overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
-	genCmd.Flags().StringVarP(&cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
-
-	genCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
-	genCmd.Flags().BoolVarP(&onlyRoot, "only-root", "r", false, "Draw only root directory")
-	genCmd.Flags().BoolVarP(&includeFilenames, "include-filenames", "f", false, "Display source filenames on the diagram")
-	genCmd.Flags().BoolVarP(&minify, "minify", "s", false, "Minify element names in the chart to save space")
-	genCmd.Flags().BoolVarP(&module, "module", "m", false, "Treat path as module and draw 'modules' sub-directory")
+	genCmd.Flags().StringVarP(&options.forEachMode, "for-each", "", graph.ForEachRaw, "How 'for_each' known from literals and variable defaults is drawn: 'raw' (as in the code), 'keys' (listed in the label) or 'instances' (an element for every instance)")
+	genCmd.Flags().IntVarP(&options.maxInstances, "max-instances", "", graph.DefaultMaxInstances, "Number of instances drawn, or keys listed, for a single 'for_each'; more instances are listed as keys")
+	genCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	genCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	genCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	genCmd.Flags().DurationVarP(&options.cacheTTL, "cache-ttl", "", 0, "Reuse cached modules not pinned to an exact version for this long (0 means always fetch)")
+	genCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	genCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+	genCmd.Flags().StringVarP(&options.resolutionReportPath, "resolution-report", "", "", "Path to a JSON file with resolution of every module call")
+	genCmd.Flags().StringVarP(&options.resolutionReportFormat, "resolution-report-format", "", checkFormatJSON, "Format of the resolution report: 'json', 'sarif' or 'junit'")
+	genCmd.Flags().BoolVarP(&options.strict, "strict", "", false, "Exit with non-zero code when any module call is unresolved")
+	genCmd.Flags().IntVarP(&options.maxModulesDepth, "max-module-depth", "", graph.DefaultMaxModulesDepth, "Depth of module calls drawn from a path, deeper ones are drawn as truncated")
+	genCmd.Flags().IntVarP(&options.maxParseDepth, "max-parse-depth", "", tfpath.DefaultMaxParseDepth, "Depth of external modules calling other external modules that are downloaded and parsed")
+
+	genCmd.Flags().BoolVarP(&options.watchPaths, "watch", "w", false, "Watch Terraform files and regenerate the diagram when they change")
+	genCmd.Flags().DurationVarP(&options.watchDebounce, "watch-debounce", "", 300*time.Millisecond, "Time without changes to wait for before regenerating the diagram")
+
+	genCmd.Flags().StringVarP(&configPath, "config", "", "", "Path to a config file (default '"+config.FileName+"' in the scanned path, or in the current directory when there are many)")
+	genCmd.MarkFlagFilename("config")
+	genCmd.Flags().StringSliceVarP(&viewNames, "view", "", []string{}, "Names of views from the config file to generate")
+	genCmd.Flags().BoolVarP(&allViews, "all-views", "", false, "Generate all views from the config file")
+
+	genCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+	genCmd.Flags().BoolVarP(&options.onlyRoot, "only-root", "r", false, "Draw only root directory")
+	genCmd.Flags().BoolVarP(&options.includeFilenames, "include-filenames", "f", false, "Display source filenames on the diagram")
+	genCmd.Flags().BoolVarP(&options.minify, "minify", "s", false, "Minify element names in the chart to save space")
+	genCmd.Flags().BoolVarP(&options.module, "module", "m", false, "Treat path as module and draw 'modules' sub-directory")
+	genCmd.Flags().BoolVarP(&options.providerLocks, "provider-locks", "l", false, "Draw providers locked in '.terraform.lock.hcl' files")
 	rootCmd.AddCommand(genCmd)
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newWhereCmd())
+	rootCmd.AddCommand(newDriftCmd())
+	rootCmd.AddCommand(newMatrixCmd())
+	rootCmd.AddCommand(newCacheCmd())
+	rootCmd.AddCommand(newMirrorCmd())
+	rootCmd.AddCommand(newOverridesCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println("Command execution error:", err)
@@ -78,60 +214,316 @@ func main() {
 }
 
 //nolint:funlen
-func genHandler(_ context.Context, debug bool, terraformPath, pathIncludeRegexp, pathExcludeRegexp, typeRegexp, nameRegexp,
-	displayAttributes, outputFile, overridesPath, cachePath string, onlyRoot, includeFilenames,
-	minify, module bool) int {
+func genHandler(ctx context.Context, options *genOptions, rootNames, rootPaths []string, views []*config.View) int {
 	slog.Info("🚀 tfsketch starting...")
 
-	if typeRegexp == "" {
-		typeRegexp = "^.*$"
+	if options.typeRegexp == "" {
+		options.typeRegexp = "^.*$"
+	}
+
+	if options.nameRegexp == "" {
+		options.nameRegexp = "^.*$"
+	}
+
+	if options.pathIncludeRegexp == "" {
+		options.pathIncludeRegexp = "^.*$"
+	}
+
+	if options.pathExcludeRegexp == "" {
+		options.pathExcludeRegexp = "^SillyName$"
+	}
+
+	slog.Info("✨ Terraform paths to scan:         " + strings.Join(rootPaths, ", "))
+	slog.Info("✨ Include path regexp:             " + options.pathIncludeRegexp)
+	slog.Info("✨ Exclude path regexp:             " + options.pathExcludeRegexp)
+	slog.Info("✨ Resource type regexp:            " + options.typeRegexp)
+	slog.Info("✨ Resource name regexp:            " + options.nameRegexp)
+	slog.Info("✨ Display attributes:              " + options.displayAttributes)
+	slog.Info("✨ Output diagram destination:      " + options.outputFile)
+	slog.Info("✨ Output format:                   " + options.outputFormat)
+	slog.Info("✨ External modules overrides file: " + options.overridesPath)
+	slog.Info("✨ Draw only root path:             " + fmt.Sprintf("%v", options.onlyRoot))
+	slog.Info("✨ Include source filename:         " + fmt.Sprintf("%v", options.includeFilenames))
+	slog.Info("✨ Minify element names:            " + fmt.Sprintf("%v", options.minify))
+	slog.Info("✨ Draw 'modules' sub-directory:    " + fmt.Sprintf("%v", options.module))
+	slog.Info("✨ Layout:                          " + options.layoutName)
+	slog.Info("✨ Ignore dir regexp:               " + options.ignoreDirRegexp)
+	slog.Info("✨ Module dir regexp:               " + options.moduleDirRegexp)
+	slog.Info("✨ Max path depth:                  " + fmt.Sprintf("%d", options.maxPathDepth))
+	slog.Info("✨ Max module depth:                " + fmt.Sprintf("%d", options.maxModulesDepth))
+	slog.Info("✨ Max parse depth:                 " + fmt.Sprintf("%d", options.maxParseDepth))
+	slog.Info("✨ For each:                        " + options.forEachMode)
+	slog.Info("✨ Max instances:                   " + fmt.Sprintf("%d", options.maxInstances))
+	slog.Info("✨ Cache path:                      " + options.cachePath)
+	slog.Info("✨ Module mirror path:              " + options.modulesMirrorPath)
+	slog.Info("✨ Cache TTL:                       " + options.cacheTTL.String())
+	slog.Info("✨ Git backend:                     " + options.gitBackendName)
+	slog.Info("✨ Offline:                         " + fmt.Sprintf("%v", options.offline))
+	slog.Info("✨ Draw provider locks:             " + fmt.Sprintf("%v", options.providerLocks))
+	slog.Info("✨ Resolution report file:          " + options.resolutionReportPath)
+	slog.Info("✨ Resolution report format:        " + options.resolutionReportFormat)
+	slog.Info("✨ Strict:                          " + fmt.Sprintf("%v", options.strict))
+	slog.Info("✨ Watch:                           " + fmt.Sprintf("%v", options.watchPaths))
+	slog.Info("✨ Views:                           " + viewNamesList(views))
+
+	setLogger(options.debug)
+
+	if len(views) == 0 && options.outputFile == "" {
+		slog.Error("❌ Either --output, or views with --view or --all-views must be set")
+
+		return exitCodeErrInvalidGenArgs
+	}
+
+	if options.maxModulesDepth < 1 || options.maxParseDepth < 1 {
+		slog.Error("❌ Max module depth and max parse depth must be at least 1")
+
+		return exitCodeErrInvalidGenArgs
 	}
 
-	if nameRegexp == "" {
-		nameRegexp = "^.*$"
+	if !slices.Contains([]string{graph.ForEachRaw, graph.ForEachKeys, graph.ForEachInstances}, options.forEachMode) {
+		slog.Error("❌ Unknown for_each mode, expected 'raw', 'keys' or 'instances': " + options.forEachMode)
+
+		return exitCodeErrInvalidGenArgs
 	}
 
-	if pathIncludeRegexp == "" {
-		pathIncludeRegexp = "^.*$"
+	if options.maxInstances < 1 {
+		slog.Error("❌ Max instances must be at least 1")
+
+		return exitCodeErrInvalidGenArgs
 	}
 
-	if pathExcludeRegexp == "" {
-		pathExcludeRegexp = "^SillyName$"
+	dirLayout, err := layout.New(options.layoutName, options.ignoreDirRegexp, options.moduleDirRegexp, options.maxPathDepth)
+	if err != nil {
+		slog.Error("❌ Error creating layout: " + err.Error())
+
+		return exitCodeErrInvalidGenArgs
 	}
 
-	slog.Info("✨ Terraform path to scan:          " + terraformPath)
-	slog.Info("✨ Include path regexp:             " + pathIncludeRegexp)
-	slog.Info("✨ Exclude path regexp:             " + pathExcludeRegexp)
-	slog.Info("✨ Resource type regexp:            " + typeRegexp)
-	slog.Info("✨ Resource name regexp:            " + nameRegexp)
-	slog.Info("✨ Display attributes:              " + displayAttributes)
-	slog.Info("✨ Output diagram destination:      " + outputFile)
-	slog.Info("✨ External modules overrides file: " + overridesPath)
-	slog.Info("✨ Draw only root path:             " + fmt.Sprintf("%v", onlyRoot))
-	slog.Info("✨ Include source filename:         " + fmt.Sprintf("%v", includeFilenames))
-	slog.Info("✨ Minify element names:            " + fmt.Sprintf("%v", minify))
-	slog.Info("✨ Draw 'modules' sub-directory:    " + fmt.Sprintf("%v", module))
-	slog.Info("✨ Cache path:                      " + cachePath)
+	if len(views) > 0 && options.watchPaths {
+		slog.Error("❌ Views cannot be watched, generate them with --output instead")
 
-	setLogger(debug)
+		return exitCodeErrInvalidGenArgs
+	}
+
+	for _, format := range append([]string{options.outputFormat}, viewFormats(views)...) {
+		if !isGenFormat(format) {
+			slog.Error("❌ Unknown output format: " + format)
+
+			return exitCodeErrInvalidGenArgs
+		}
+	}
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
 
 	var cache *tfpath.Cache
-	if cachePath != "" {
-		cache = tfpath.NewCache(cachePath)
+	if options.cachePath != "" {
+		cache = tfpath.NewCache(options.cachePath, options.cacheTTL, options.offline, gitBackend)
 	}
 
 	container := tfpath.NewContainer()
+	container.MaxParseDepth = options.maxParseDepth
+
+	// views filter resources of the same parsed code, so all of them are parsed
+	parseTypeRegexp, parseNameRegexp := options.typeRegexp, options.nameRegexp
+	if len(views) > 0 {
+		parseTypeRegexp, parseNameRegexp = "^.*$", "^.*$"
+	}
 
 	traverser := tfpath.NewTraverser(
 		container,
-		pathIncludeRegexp,
-		pathExcludeRegexp,
-		typeRegexp,
-		nameRegexp,
-		displayAttributes,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		parseTypeRegexp,
+		parseNameRegexp,
+		options.displayAttributes,
+		cache,
+	)
+
+	dirLayout.Apply(traverser)
+
+	if options.modulesMirrorPath != "" {
+		container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+	}
+
+	rootTfPaths, exitCode := scanTerraformPaths(container, traverser, cache, options.overridesPath, rootNames, rootPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	logMissingModules(container)
+
+	flowchart := chart.NewMermaidFlowChart(chart.Options{
+		Options: graph.Options{
+			OnlyRoot:        options.onlyRoot,
+			Module:          options.module,
+			ProviderLocks:   options.providerLocks,
+			MaxModulesDepth: options.maxModulesDepth,
+			Layout:          dirLayout,
+			ForEachMode:     options.forEachMode,
+			MaxInstances:    options.maxInstances,
+		},
+		IncludeFilenames: options.includeFilenames,
+		Minify:           options.minify,
+	})
+
+	if len(views) > 0 {
+		exitCode = generateViews(flowchart, rootTfPaths, views, options.typeRegexp, options.nameRegexp, options.outputFormat)
+		if exitCode != 0 {
+			return exitCode
+		}
+	} else {
+		err = generateChart(flowchart, rootTfPaths, options.outputFile, options.outputFormat)
+		if err != nil {
+			slog.Error(
+				fmt.Sprintf(
+					"❌ Error generating chart from terraform paths 📁%s : %s",
+					strings.Join(rootPaths, ", "),
+					err.Error(),
+				),
+			)
+
+			return exitCodeErrGeneratingChart
+		}
+	}
+
+	resolutionReport := container.ResolutionReport()
+	logResolutionReport(resolutionReport)
+
+	if options.resolutionReportPath != "" {
+		err = writeResolutionReport(
+			resolutionReport,
+			options.resolutionReportPath,
+			options.resolutionReportFormat,
+			rootNames,
+			rootPaths,
+		)
+		if err != nil {
+			slog.Error("❌ Error writing resolution report: " + err.Error())
+
+			return exitCodeErrWritingResolutionReport
+		}
+	}
+
+	if options.watchPaths {
+		return watchTerraformPaths(ctx, container, traverser, cache, flowchart, rootTfPaths, options.outputFile, options.outputFormat, options.watchDebounce)
+	}
+
+	if options.strict && resolutionReport.Unresolved > 0 {
+		slog.Error(fmt.Sprintf("❌ %d module calls are unresolved and strict mode is on", resolutionReport.Unresolved))
+
+		return exitCodeErrUnresolvedModules
+	}
+
+	return 0
+}
+
+// layoutPresetsHelp returns names of the layout presets with their descriptions.
+func layoutPresetsHelp() string {
+	presetsHelp := []string{}
+	for _, presetName := range layout.PresetNames() {
+		presetsHelp = append(presetsHelp, fmt.Sprintf("'%s' (%s)", presetName, layout.PresetDescription(presetName)))
+	}
+
+	return strings.Join(presetsHelp, ", ")
+}
+
+// isGenFormat checks if format is one of the chart output formats.
+func isGenFormat(format string) bool {
+	switch format {
+	case genFormatMermaid, genFormatHTML, genFormatPlantUML, genFormatD2:
+		return true
+	default:
+		return false
+	}
+}
+
+// generateChart writes the chart of the root paths to the output file in the given format.
+func generateChart(flowchart *chart.MermaidFlowChart, rootTfPaths []*tfpath.TfPath, outputFile, outputFormat string) error {
+	switch outputFormat {
+	case genFormatHTML:
+		return flowchart.GenerateHTML(rootTfPaths, outputFile)
+	case genFormatPlantUML:
+		return flowchart.GeneratePlantUML(rootTfPaths, outputFile)
+	case genFormatD2:
+		return flowchart.GenerateD2(rootTfPaths, outputFile)
+	default:
+		return flowchart.Generate(rootTfPaths, outputFile)
+	}
+}
+
+// parseRootPaths takes values of the 'path' flag, each being a path or 'name=path', and returns names and paths of
+// the roots. A single path without a name is named "." as before, and other paths without a name are named after
+// their directory.
+func parseRootPaths(terraformPaths []string) ([]string, []string, int) {
+	if len(terraformPaths) == 1 && !strings.Contains(terraformPaths[0], "=") {
+		return []string{"."}, terraformPaths, 0
+	}
+
+	rootNames := make([]string, 0, len(terraformPaths))
+	rootPaths := make([]string, 0, len(terraformPaths))
+
+	for _, terraformPath := range terraformPaths {
+		rootName, rootPath, hasName := strings.Cut(terraformPath, "=")
+		if !hasName {
+			rootName, rootPath = filepath.Base(filepath.Clean(terraformPath)), terraformPath
+		}
+
+		if rootName == "" || rootPath == "" {
+			slog.Error("❌ Invalid path, expected 'path' or 'name=path': " + terraformPath)
+
+			return nil, nil, exitCodeErrInvalidGenArgs
+		}
+
+		if slices.Contains(rootNames, rootName) {
+			slog.Error("❌ Paths must have unique names, use 'name=path' to name them: " + rootName)
+
+			return nil, nil, exitCodeErrInvalidGenArgs
+		}
+
+		rootNames = append(rootNames, rootName)
+		rootPaths = append(rootPaths, rootPath)
+	}
+
+	return rootNames, rootPaths, 0
+}
+
+// scanTerraformPath walks overrides and the terraform path, and then parses and links all the paths
+// in the container. It returns the root TfPath and a non-zero exit code on failure.
+func scanTerraformPath(
+	container *tfpath.Container,
+	traverser *tfpath.Traverser,
+	cache *tfpath.Cache,
+	overridesPath, terraformPath, rootTfPathName string,
+) (*tfpath.TfPath, int) {
+	rootTfPaths, exitCode := scanTerraformPaths(
+		container,
+		traverser,
 		cache,
+		overridesPath,
+		[]string{rootTfPathName},
+		[]string{terraformPath},
 	)
+	if exitCode != 0 {
+		return nil, exitCode
+	}
 
+	return rootTfPaths[0], 0
+}
+
+// scanTerraformPaths does the same as scanTerraformPath for many terraform paths, each added to the container under
+// its name, so that external modules are parsed once for all of them. It returns the root TfPaths in the same order.
+func scanTerraformPaths(
+	container *tfpath.Container,
+	traverser *tfpath.Traverser,
+	cache *tfpath.Cache,
+	overridesPath string,
+	rootTfPathNames, terraformPaths []string,
+) ([]*tfpath.TfPath, int) {
 	var err error
 
 	// overrides
@@ -142,12 +534,12 @@ func genHandler(_ context.Context, debug bool, terraformPath, pathIncludeRegexp,
 		if err != nil {
 			slog.Error("❌ Error reading overrides from file: " + err.Error())
 
-			return exitCodeErrReadingOverridesFromFile
+			return nil, exitCodeErrReadingOverridesFromFile
 		}
 
 		err = container.WalkOverrides(overrides, traverser, cache)
 		if err != nil {
-			return exitCodeErrTraversingOverrides
+			return nil, exitCodeErrTraversingOverrides
 		}
 
 		externalModulesNum := len(overrides.ExternalModules)
@@ -156,59 +548,138 @@ func genHandler(_ context.Context, debug bool, terraformPath, pathIncludeRegexp,
 		)
 	}
 
-	// path
-	rootTfPathName := "."
-	rootTfPath := tfpath.NewTfPath(terraformPath, rootTfPathName)
-	container.AddPath(rootTfPathName, rootTfPath)
+	// paths
+	rootTfPaths := make([]*tfpath.TfPath, 0, len(terraformPaths))
 
-	err = traverser.WalkPath(rootTfPath, false)
-	if err != nil {
-		slog.Error(
-			fmt.Sprintf(
-				"❌ Error walking dirs in terraform path 📁%s: %s",
-				rootTfPath.Path,
-				err.Error(),
-			),
-		)
+	for i, terraformPath := range terraformPaths {
+		rootTfPath := tfpath.NewTfPath(terraformPath, rootTfPathNames[i])
+		container.AddRootPath(rootTfPathNames[i], rootTfPath)
+
+		err = traverser.WalkPath(rootTfPath, false)
+		if err != nil {
+			slog.Error(
+				fmt.Sprintf(
+					"❌ Error walking dirs in terraform path 📁%s: %s",
+					rootTfPath.Path,
+					err.Error(),
+				),
+			)
+
+			return nil, exitCodeErrTraversingOverrides
+		}
 
-		return exitCodeErrTraversingOverrides
+		rootTfPaths = append(rootTfPaths, rootTfPath)
 	}
 
 	// as of now, use paths in container
 	err = container.ParsePaths(traverser, cache, 1)
 	if err != nil {
-		return exitCodeErrParsingContainerPaths
+		return nil, exitCodeErrParsingContainerPaths
 	}
 
 	err = container.LinkPaths(traverser)
 	if err != nil {
-		return exitCodeErrLinkingContainerPaths
+		return nil, exitCodeErrLinkingContainerPaths
 	}
 
-	flowchart := chart.NewMermaidFlowChart(onlyRoot, includeFilenames, minify, module)
+	// cycles are not followed, they are drawn where they close
+	for _, cycle := range container.ModuleCycles() {
+		slog.Warn("❗ Module call cycle: " + cycle)
+	}
 
-	err = flowchart.Generate(rootTfPath, outputFile)
-	if err != nil {
-		slog.Error(
+	return rootTfPaths, 0
+}
+
+// logMissingModules lists external modules that could not be found so that it is clear what is not drawn.
+func logMissingModules(container *tfpath.Container) {
+	missingModules := container.MissingModuleNamesSorted()
+	if len(missingModules) == 0 {
+		return
+	}
+
+	slog.Warn(fmt.Sprintf("❗ %d external modules are missing and will not be drawn:", len(missingModules)))
+
+	for _, missingModule := range missingModules {
+		slog.Warn("❗   📦" + missingModule)
+	}
+}
+
+// writeResolutionReport writes the report as JSON, or its unresolved module calls as SARIF or JUnit findings with
+// files relative to the root they are in.
+func writeResolutionReport(report *tfpath.ResolutionReport, path, format string, rootNames, rootPaths []string) error {
+	switch format {
+	case checkFormatSARIF, checkFormatJUnit:
+		roots := make(map[string]string, len(rootNames))
+		for i, rootName := range rootNames {
+			roots[rootName] = rootPaths[i]
+		}
+
+		findingsBytes, err := formatFindings(findings.FromResolutionReport(report, roots), format, "tfsketch gen")
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(filepath.Clean(path), findingsBytes, newFilesMode)
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", path, err)
+		}
+
+		return nil
+	case checkFormatJSON:
+		return report.WriteToFile(path)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// logResolutionReport prints how many module calls were resolved with each method, and the unresolved ones with
+// a reason.
+func logResolutionReport(report *tfpath.ResolutionReport) {
+	methods := make([]string, 0, len(report.Methods))
+	for method := range report.Methods {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		slog.Info(fmt.Sprintf("🔸 Module calls resolved with %s: %d", method, report.Methods[method]))
+	}
+
+	unresolvedCalls := report.UnresolvedCalls()
+	if len(unresolvedCalls) == 0 {
+		return
+	}
+
+	slog.Warn(fmt.Sprintf("❗ %d module calls are unresolved:", len(unresolvedCalls)))
+
+	for _, call := range unresolvedCalls {
+		source := call.Source
+		if call.Version != "" {
+			source += "@" + call.Version
+		}
+
+		slog.Warn(
 			fmt.Sprintf(
-				"❌ Error generating chart from terraform path 📁%s (%s) : %s",
-				rootTfPath.Path,
-				rootTfPathName,
-				err.Error(),
+				"❗   module %s (📦%s) in 📁%s (%s): %s",
+				call.Module,
+				source,
+				call.RelPath,
+				call.Path,
+				call.Reason,
 			),
 		)
-
-		return exitCodeErrGeneratingChart
 	}
-
-	return 0
 }
 
 func setLogger(debug bool) {
-	logLevel := slog.LevelInfo
+	slog.SetLogLoggerLevel(logLevel(debug))
+}
+
+func logLevel(debug bool) slog.Level {
 	if debug {
-		logLevel = slog.LevelDebug
+		return slog.LevelDebug
 	}
 
-	slog.SetLogLoggerLevel(logLevel)
+	return slog.LevelInfo
 }
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/envmatrix"
+	"tfsketch/internal/tfpath"
+)
+
+const (
+	matrixFormatCSV      = "csv"
+	matrixTfvarsFileExt  = ".tfvars"
+	matrixDefaultTfvars  = "envs/*.tfvars"
+	matrixCellAbsent     = "-"
+	matrixCellUnknown    = "?"
+	matrixCellNameless   = "yes"
+	matrixInstancesDelim = ", "
+)
+
+// matrixOptions are values of the 'matrix' flags.
+type matrixOptions struct {
+	terraformPaths []string
+
+	tfvarsGlob        string
+	displayAttributes string
+	outputFile        string
+	format            string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+
+	debug bool
+}
+
+func newMatrixCmd() *cobra.Command {
+	var options matrixOptions
+
+	matrixCmd := &cobra.Command{
+		Use:   "matrix",
+		Short: "Show resources and modules in every environment",
+		Long: "Evaluate 'for_each' and 'count' of resources and module calls with every '.tfvars' file found next to " +
+			"the code, and show which instances exist in each environment and with which names",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(matrixHandler(&options))
+		},
+	}
+
+	matrixCmd.Flags().StringSliceVarP(&options.terraformPaths, "path", "", []string{}, "Paths to directories with terraform code, optionally as 'name=path' (required)")
+	matrixCmd.MarkFlagRequired("path")
+	matrixCmd.MarkFlagDirname("path")
+
+	matrixCmd.Flags().StringVarP(&options.tfvarsGlob, "tfvars", "", matrixDefaultTfvars, "Glob pattern of '.tfvars' files, relative to every directory with terraform code, each being an environment named after the file")
+	matrixCmd.Flags().StringVarP(
+		&options.displayAttributes, "display-attributes", "a", "",
+		"Comma-separated resource attributes; the first found is evaluated as the instance name",
+	)
+
+	matrixCmd.Flags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (standard output if empty)")
+	matrixCmd.MarkFlagFilename("output")
+	matrixCmd.Flags().StringVarP(&options.format, "format", "", driftFormatTable, "Output format: 'table', 'csv' or 'json'")
+
+	matrixCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	matrixCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+	matrixCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+
+	return matrixCmd
+}
+
+//nolint:funlen
+func matrixHandler(options *matrixOptions) int {
+	setLogger(options.debug)
+
+	// logs go to standard error so that they do not mix with the matrix
+	if options.outputFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel(options.debug)})))
+	}
+
+	if !slices.Contains([]string{driftFormatTable, matrixFormatCSV, checkFormatJSON}, options.format) {
+		slog.Error("❌ Unknown output format: " + options.format)
+
+		return exitCodeErrInvalidMatrixArgs
+	}
+
+	_, err := filepath.Match(options.tfvarsGlob, "")
+	if err != nil {
+		slog.Error("❌ Invalid tfvars glob pattern: " + err.Error())
+
+		return exitCodeErrInvalidMatrixArgs
+	}
+
+	rootNames, rootPaths, exitCode := parseRootPaths(options.terraformPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	container := tfpath.NewContainer()
+
+	// only the code of the paths is evaluated, so external modules are not downloaded
+	traverser := tfpath.NewTraverser(
+		container,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		"^.*$",
+		"^.*$",
+		options.displayAttributes,
+		nil,
+	)
+
+	rootTfPaths, exitCode := scanTerraformPaths(container, traverser, nil, "", rootNames, rootPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	environments, err := matrixEnvironments(traverser, rootTfPaths, options.tfvarsGlob)
+	if err != nil {
+		slog.Error("❌ Error reading environments: " + err.Error())
+
+		return exitCodeErrReadingTfvars
+	}
+
+	if len(environments) == 0 {
+		slog.Warn("❗ No tfvars files found with pattern: " + options.tfvarsGlob)
+	}
+
+	matrix := envmatrix.New(environments)
+
+	output := ""
+
+	switch options.format {
+	case checkFormatJSON:
+		matrixBytes, err := json.MarshalIndent(matrix, "", "  ")
+		if err != nil {
+			slog.Error("❌ Error marshalling matrix: " + err.Error())
+
+			return exitCodeErrWritingMatrixOutput
+		}
+
+		output = string(matrixBytes) + "\n"
+	case matrixFormatCSV:
+		output, err = matrixCSV(matrix)
+		if err != nil {
+			slog.Error("❌ Error writing matrix as CSV: " + err.Error())
+
+			return exitCodeErrWritingMatrixOutput
+		}
+	default:
+		output = matrixTable(matrix, len(rootTfPaths) > 1)
+	}
+
+	if options.outputFile == "" {
+		_, _ = fmt.Fprint(os.Stdout, output)
+	} else {
+		err = os.WriteFile(filepath.Clean(options.outputFile), []byte(output), newFilesMode)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error writing matrix 📄%s: %s", options.outputFile, err.Error()))
+
+			return exitCodeErrWritingMatrixOutput
+		}
+	}
+
+	unknownCount := matrix.UnknownCount()
+	if unknownCount > 0 {
+		slog.Warn(fmt.Sprintf("❗ %d cells could not be evaluated and are marked with '%s'", unknownCount, matrixCellUnknown))
+	}
+
+	slog.Info(fmt.Sprintf("🔸 %d rows in %d environments", len(matrix.Rows), len(matrix.Environments)))
+
+	return 0
+}
+
+// matrixEnvironments returns environments of the root paths and their sub-directories, one for each tfvars file
+// matching the glob pattern in the directory.
+func matrixEnvironments(
+	traverser *tfpath.Traverser,
+	rootTfPaths []*tfpath.TfPath,
+	tfvarsGlob string,
+) ([]*envmatrix.Environment, error) {
+	environments := []*envmatrix.Environment{}
+
+	addEnvironments := func(tfPath *tfpath.TfPath, rootName string) error {
+		filePaths, err := filepath.Glob(filepath.Join(tfPath.Path, tfvarsGlob))
+		if err != nil {
+			return fmt.Errorf("error finding tfvars files in %s: %w", tfPath.Path, err)
+		}
+
+		for _, filePath := range filePaths {
+			values, err := traverser.ReadTfvarsFile(filePath)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+
+			name := strings.TrimSuffix(filepath.Base(filePath), matrixTfvarsFileExt)
+
+			slog.Info(fmt.Sprintf("🟢 Found environment %s in file 📄%s (📦%s)", name, filePath, rootName))
+
+			environments = append(environments, &envmatrix.Environment{
+				Name:   name,
+				Root:   rootName,
+				TfPath: tfPath,
+				Values: values,
+			})
+		}
+
+		return nil
+	}
+
+	for _, rootTfPath := range rootTfPaths {
+		err := addEnvironments(rootTfPath, rootTfPath.TraverseName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, childKey := range rootTfPath.ChildrenNamesSorted() {
+			childTfPath := rootTfPath.Children[childKey]
+			if childTfPath == nil {
+				continue
+			}
+
+			err := addEnvironments(childTfPath, rootTfPath.TraverseName)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return environments, nil
+}
+
+// matrixTable returns the matrix as a table with a column for every environment, where a cell lists instances with
+// their names, '-' when there are none, '?' when they could not be evaluated, and is empty when the path does not
+// have the environment.
+func matrixTable(matrix *envmatrix.Matrix, withRoot bool) string {
+	builder := &strings.Builder{}
+	writer := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(writer, "PATH\tADDRESS\t"+strings.Join(matrix.Environments, "\t"))
+
+	for _, row := range matrix.Rows {
+		location := row.Path
+		if withRoot {
+			location = row.Root + ":" + location
+		}
+
+		cells := make([]string, 0, len(matrix.Environments))
+		for _, environment := range matrix.Environments {
+			cells = append(cells, matrixCellText(row, row.Cells[environment]))
+		}
+
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", location, row.Address, strings.Join(cells, "\t"))
+	}
+
+	_ = writer.Flush()
+
+	return builder.String()
+}
+
+// matrixCellText returns names of the instances, with their keys when the row is repeated, eg. '["admin"] admin-role'.
+func matrixCellText(row *envmatrix.Row, cell *envmatrix.Cell) string {
+	if cell == nil {
+		return ""
+	}
+
+	switch cell.Status {
+	case envmatrix.StatusAbsent:
+		return matrixCellAbsent
+	case envmatrix.StatusUnknown:
+		return matrixCellUnknown
+	}
+
+	texts := make([]string, 0, len(cell.Instances))
+
+	for _, instance := range cell.Instances {
+		key := strings.TrimPrefix(instance.Address, row.Address)
+
+		switch {
+		case key != "" && instance.Name != "":
+			texts = append(texts, key+" "+instance.Name)
+		case key != "":
+			texts = append(texts, key)
+		case instance.Name != "":
+			texts = append(texts, instance.Name)
+		default:
+			texts = append(texts, matrixCellNameless)
+		}
+	}
+
+	return strings.Join(texts, matrixInstancesDelim)
+}
+
+// matrixCSV returns the matrix as CSV with a record for every instance in every environment, or a single record of
+// a resource or a module call when it is absent or unknown in the environment.
+func matrixCSV(matrix *envmatrix.Matrix) (string, error) {
+	builder := &strings.Builder{}
+	writer := csv.NewWriter(builder)
+
+	records := [][]string{{"root", "path", "environment", "kind", "address", "status", "name"}}
+
+	for _, row := range matrix.Rows {
+		for _, environment := range matrix.Environments {
+			cell := row.Cells[environment]
+			if cell == nil {
+				continue
+			}
+
+			if len(cell.Instances) == 0 {
+				records = append(records, []string{row.Root, row.Path, environment, row.Kind, row.Address, cell.Status, ""})
+
+				continue
+			}
+
+			for _, instance := range cell.Instances {
+				records = append(
+					records,
+					[]string{row.Root, row.Path, environment, row.Kind, instance.Address, cell.Status, instance.Name},
+				)
+			}
+		}
+	}
+
+	err := writer.WriteAll(records)
+	if err != nil {
+		return "", fmt.Errorf("error writing records: %w", err)
+	}
+
+	return builder.String(), nil
+}
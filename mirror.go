@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/tfpath"
+)
+
+func newMirrorCmd() *cobra.Command {
+	var terraformPath, modulesMirrorPath, overridesPath, cachePath, gitBackendName string
+	var debug bool
+
+	mirrorCmd := &cobra.Command{
+		Use:   "mirror",
+		Short: "Populate module mirror",
+		Long:  "Download external modules discovered in Terraform files and copy them to the module mirror directory",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(mirrorHandler(debug, terraformPath, modulesMirrorPath, overridesPath, cachePath, gitBackendName))
+		},
+	}
+
+	mirrorCmd.Flags().StringVarP(&terraformPath, "path", "", "", "Path to directory with terraform code (required)")
+	mirrorCmd.MarkFlagRequired("path")
+	mirrorCmd.MarkFlagDirname("path")
+
+	mirrorCmd.Flags().StringVarP(&modulesMirrorPath, "module-mirror", "", "", "Path to module mirror directory (required)")
+	mirrorCmd.MarkFlagRequired("module-mirror")
+	mirrorCmd.MarkFlagDirname("module-mirror")
+
+	mirrorCmd.Flags().StringVarP(&overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	mirrorCmd.Flags().StringVarP(&cachePath, "cache", "c", "", "Path to directory where modules will be downloaded (temporary directory if empty)")
+	mirrorCmd.Flags().StringVarP(&gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	mirrorCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
+
+	return mirrorCmd
+}
+
+//nolint:funlen
+func mirrorHandler(debug bool, terraformPath, modulesMirrorPath, overridesPath, cachePath, gitBackendName string) int {
+	slog.Info("🚀 tfsketch mirror starting...")
+	slog.Info("✨ Terraform path to scan:          " + terraformPath)
+	slog.Info("✨ Module mirror path:              " + modulesMirrorPath)
+	slog.Info("✨ External modules overrides file: " + overridesPath)
+	slog.Info("✨ Cache path:                      " + cachePath)
+	slog.Info("✨ Git backend:                     " + gitBackendName)
+
+	setLogger(debug)
+
+	gitBackend, err := tfpath.NewGitBackend(gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	if cachePath == "" {
+		tempCachePath, err := os.MkdirTemp("", "tfsketch-cache-")
+		if err != nil {
+			slog.Error("❌ Error creating temporary cache directory: " + err.Error())
+
+			return exitCodeErrCreatingTempCache
+		}
+
+		defer os.RemoveAll(tempCachePath)
+
+		cachePath = tempCachePath
+	}
+
+	cache := tfpath.NewCache(cachePath, 0, false, gitBackend)
+	mirror := tfpath.NewMirror(modulesMirrorPath)
+
+	container := tfpath.NewContainer()
+	container.Mirror = mirror
+
+	traverser := tfpath.NewTraverser(container, "^.*$", "^SillyName$", "^.*$", "^.*$", "", cache)
+
+	_, exitCode := scanTerraformPath(container, traverser, cache, overridesPath, terraformPath, ".")
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	manifest := cache.Manifest()
+	notMirrored := []string{}
+
+	containerPathKeys := make([]string, 0, len(container.Paths))
+	for containerPathKey := range container.Paths {
+		containerPathKeys = append(containerPathKeys, containerPathKey)
+	}
+
+	sort.Strings(containerPathKeys)
+
+	for _, containerPathKey := range containerPathKeys {
+		// only modules downloaded in this run, as others are local or already in the mirror
+		entry, exists := manifest.Entries[containerPathKey]
+		if !exists {
+			continue
+		}
+
+		if !mirror.IsRegistrySource(containerPathKey) {
+			slog.Warn(fmt.Sprintf("❗ Module 📦%s is not in registry format and cannot be mirrored", containerPathKey))
+
+			notMirrored = append(notMirrored, containerPathKey)
+
+			continue
+		}
+
+		mirroredPath, err := mirror.AddModule(containerPathKey, entry.Ref, filepath.Join(cache.Path(), entry.Dir))
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error mirroring module 📦%s: %s", containerPathKey, err.Error()))
+
+			notMirrored = append(notMirrored, containerPathKey)
+
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("🪞 Mirrored module 📦%s to 📁%s", containerPathKey, mirroredPath))
+	}
+
+	logMissingModules(container)
+
+	for _, containerPathKey := range notMirrored {
+		slog.Warn("❗ Module not mirrored: 📦" + containerPathKey)
+	}
+
+	if len(notMirrored) > 0 || len(container.MissingModules) > 0 {
+		return exitCodeErrMirrorIncomplete
+	}
+
+	return 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/overrides"
+	"tfsketch/internal/tfpath"
+)
+
+func newOverridesCmd() *cobra.Command {
+	var terraformPath, outputFile string
+	var debug bool
+
+	overridesCmd := &cobra.Command{
+		Use:   "overrides",
+		Short: "Manage overrides file",
+		Long:  "Manage YAML file mapping external modules to local paths",
+	}
+
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate overrides file",
+		Long:  "Generate a starter overrides file from external modules found in Terraform files",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(overridesGenerateHandler(debug, terraformPath, outputFile))
+		},
+	}
+
+	generateCmd.Flags().StringVarP(&terraformPath, "path", "", "", "Path to directory with terraform code (required)")
+	generateCmd.MarkFlagRequired("path")
+	generateCmd.MarkFlagDirname("path")
+
+	generateCmd.Flags().StringVarP(&outputFile, "output", "", "", "Path to an output file (standard output if empty)")
+	generateCmd.MarkFlagFilename("output")
+
+	generateCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
+
+	overridesCmd.AddCommand(generateCmd)
+
+	return overridesCmd
+}
+
+func overridesGenerateHandler(debug bool, terraformPath, outputFile string) int {
+	setLogger(debug)
+
+	// logs go to standard error so that they do not mix with the generated file
+	if outputFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel(debug)})))
+	}
+
+	container := tfpath.NewContainer()
+	traverser := tfpath.NewTraverser(container, "^.*$", "^SillyName$", "^.*$", "^.*$", "", nil)
+
+	rootTfPath := tfpath.NewTfPath(terraformPath, ".")
+	container.AddRootPath(".", rootTfPath)
+
+	err := traverser.WalkPath(rootTfPath, false)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error walking dirs in terraform path 📁%s: %s", rootTfPath.Path, err.Error()))
+
+		return exitCodeErrTraversingOverrides
+	}
+
+	// the same 'source@version' keys that container uses to find external modules
+	foundModules := []string{}
+
+	err = traverser.ParsePath(rootTfPath, &foundModules)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error parsing terraform path 📁%s: %s", rootTfPath.Path, err.Error()))
+
+		return exitCodeErrParsingContainerPaths
+	}
+
+	generated := overrides.Generate(foundModules, terraformPath)
+
+	if outputFile == "" {
+		_, _ = fmt.Fprint(os.Stdout, generated)
+
+		return 0
+	}
+
+	err = os.WriteFile(filepath.Clean(outputFile), []byte(generated), newFilesMode)
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Error writing overrides file 📄%s: %s", outputFile, err.Error()))
+
+		return exitCodeErrWritingOverridesFile
+	}
+
+	slog.Info(fmt.Sprintf("🔸 Overrides file written to 📄%s", outputFile))
+
+	return 0
+}
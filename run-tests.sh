@@ -2,14 +2,41 @@
 
 go build .
 
-./tfsketch gen -d -i '^(\.|s.*)$' -e '.*skip.*' -t '^type$' tests/01-only-resources/ tests/01-only-resources.mmd
+./tfsketch gen -d -i '^(\.|s.*)$' -e '.*skip.*' -t '^type$' --path tests/01-only-resources/ --output tests/01-only-resources.mmd
 mmdc -i tests/01-only-resources.mmd -o tests/01-only-resources.svg --configFile=tests/config.json
 
-./tfsketch gen -t '^type$' -a name,id tests/02-local-modules/ tests/02-local-modules.mmd
+./tfsketch gen -t '^type$' -a name,id --path tests/02-local-modules/ --output tests/02-local-modules.mmd
 mmdc -i tests/02-local-modules.mmd -o tests/02-local-modules.svg --configFile=tests/config.json
 
-./tfsketch gen -t '^nevermind|type$' -m -o tests/external-modules.yml tests/03-external-modules/ tests/03-external-modules.mmd
+./tfsketch gen -t '^nevermind|type$' -m -o tests/external-modules.yml --path tests/03-external-modules/ --output tests/03-external-modules.mmd
 mmdc -i tests/03-external-modules.mmd -o tests/03-external-modules.svg --configFile=tests/config.json
 
-./tfsketch gen -a name,id -c tmp/cache -o tests/external-modules.yml -d tests/04-cache/ tests/04-cache.mmd
+./tfsketch gen -a name,id -c tmp/cache -o tests/external-modules.yml -d --path tests/04-cache/ --output tests/04-cache.mmd
 mmdc -i tests/04-cache.mmd -o tests/04-cache.svg --configFile=tests/config.json
+
+./tfsketch gen -l -t '^type$' --path tests/05-lock-files/ --output tests/05-lock-files.mmd
+mmdc -i tests/05-lock-files.mmd -o tests/05-lock-files.svg --configFile=tests/config.json
+
+./tfsketch diff -a name -c tests/06-diff/cache --offline -o tests/06-diff/overrides.yml --old tests/06-diff/old --new tests/06-diff/new --output tests/06-diff.mmd
+mmdc -i tests/06-diff.mmd -o tests/06-diff.svg --configFile=tests/config.json
+
+./tfsketch gen -t '^type$' -a name --path tests/07-for-each/ --output tests/07-for-each.mmd
+mmdc -i tests/07-for-each.mmd -o tests/07-for-each.svg --configFile=tests/config.json
+
+./tfsketch check --rules tests/08-check/rules.yml -t '^type$' --path tests/08-check --format json --output tests/08-check.json
+
+./tfsketch gen --layout terragrunt-live -t '^type$' -a name --path tests/09-terragrunt/ --output tests/09-terragrunt.mmd
+mmdc -i tests/09-terragrunt.mmd -o tests/09-terragrunt.svg --configFile=tests/config.json
+
+./tfsketch gen -t '^type$' -a name --path tests/10-stacks/ --output tests/10-stacks.mmd
+mmdc -i tests/10-stacks.mmd -o tests/10-stacks.svg --configFile=tests/config.json
+
+./tfsketch matrix -a name --path tests/11-matrix/ --format table --output tests/11-matrix.txt
+./tfsketch matrix -a name --path tests/11-matrix/ --format csv --output tests/11-matrix.csv
+./tfsketch matrix -a name --path tests/11-matrix/ --format json --output tests/11-matrix.json
+
+./tfsketch gen -t '^type$' -a name --for-each keys --path tests/07-for-each/ --output tests/07-for-each-keys.mmd
+mmdc -i tests/07-for-each-keys.mmd -o tests/07-for-each-keys.svg --configFile=tests/config.json
+
+./tfsketch gen -t '^type$' -a name --for-each instances --max-instances 2 --path tests/07-for-each/ --output tests/07-for-each-instances.mmd
+mmdc -i tests/07-for-each-instances.mmd -o tests/07-for-each-instances.svg --configFile=tests/config.json
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/chart"
+	"tfsketch/internal/graph"
+	"tfsketch/internal/layout"
+	"tfsketch/internal/tfpath"
+	"tfsketch/internal/viewer"
+)
+
+// serveOptions are values of the 'serve' flags.
+type serveOptions struct {
+	terraformPath string
+	listenAddress string
+	mermaidJSPath string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+	displayAttributes string
+
+	overridesPath     string
+	cachePath         string
+	modulesMirrorPath string
+	gitBackendName    string
+
+	layoutName  string
+	forEachMode string
+
+	maxModulesDepth int
+	maxInstances    int
+
+	debug            bool
+	offline          bool
+	onlyRoot         bool
+	includeFilenames bool
+	module           bool
+}
+
+func newServeCmd() *cobra.Command {
+	var options serveOptions
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve interactive diagram viewer",
+		Long: "Scan Terraform files once and serve a page where resources can be searched, filtered by type and " +
+			"name, and their details viewed",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(serveHandler(&options))
+		},
+	}
+
+	serveCmd.Flags().StringVarP(&options.terraformPath, "path", "", "", "Path to directory with terraform code (required)")
+	serveCmd.MarkFlagRequired("path")
+	serveCmd.MarkFlagDirname("path")
+
+	serveCmd.Flags().StringVarP(&options.listenAddress, "listen", "", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().StringVarP(&options.mermaidJSPath, "mermaid-js", "", "", "Path to a local 'mermaid.min.js' file used to render the diagram instead of the bundled one")
+	serveCmd.MarkFlagFilename("mermaid-js")
+
+	serveCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	serveCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+	serveCmd.Flags().StringVarP(
+		&options.displayAttributes, "display-attributes", "a", "",
+		"Comma-separated resource attributes; the first found is used as the chart’s display name",
+	)
+
+	serveCmd.Flags().StringVarP(&options.layoutName, "layout", "", layout.DefaultPreset, "Layout preset classifying directories: "+layoutPresetsHelp())
+	serveCmd.Flags().StringVarP(&options.forEachMode, "for-each", "", graph.ForEachRaw, "How 'for_each' known from literals and variable defaults is drawn: 'raw' (as in the code), 'keys' (listed in the label) or 'instances' (an element for every instance)")
+	serveCmd.Flags().IntVarP(&options.maxInstances, "max-instances", "", graph.DefaultMaxInstances, "Number of instances drawn, or keys listed, for a single 'for_each'; more instances are listed as keys")
+	serveCmd.Flags().IntVarP(&options.maxModulesDepth, "max-module-depth", "", graph.DefaultMaxModulesDepth, "Depth of module calls drawn from a path, deeper ones are drawn as truncated")
+
+	serveCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	serveCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	serveCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	serveCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	serveCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+
+	serveCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+	serveCmd.Flags().BoolVarP(&options.onlyRoot, "only-root", "r", false, "Show only root directory")
+	serveCmd.Flags().BoolVarP(&options.includeFilenames, "include-filenames", "f", false, "Display source filenames on the diagram")
+	serveCmd.Flags().BoolVarP(&options.module, "module", "m", false, "Treat path as module and show 'modules' sub-directory")
+
+	return serveCmd
+}
+
+//nolint:funlen
+func serveHandler(options *serveOptions) int {
+	slog.Info("🚀 tfsketch serve starting...")
+	slog.Info("✨ Terraform path to scan:          " + options.terraformPath)
+	slog.Info("✨ Listen address:                  " + options.listenAddress)
+	slog.Info("✨ Mermaid JS file:                 " + options.mermaidJSPath)
+	slog.Info("✨ Layout:                          " + options.layoutName)
+	slog.Info("✨ Max module depth:                " + fmt.Sprintf("%d", options.maxModulesDepth))
+	slog.Info("✨ For each:                        " + options.forEachMode)
+	slog.Info("✨ Max instances:                   " + fmt.Sprintf("%d", options.maxInstances))
+
+	setLogger(options.debug)
+
+	if options.maxModulesDepth < 1 {
+		slog.Error("❌ Max module depth must be at least 1")
+
+		return exitCodeErrInvalidServeArgs
+	}
+
+	if !slices.Contains([]string{graph.ForEachRaw, graph.ForEachKeys, graph.ForEachInstances}, options.forEachMode) {
+		slog.Error("❌ Unknown for_each mode, expected 'raw', 'keys' or 'instances': " + options.forEachMode)
+
+		return exitCodeErrInvalidServeArgs
+	}
+
+	if options.maxInstances < 1 {
+		slog.Error("❌ Max instances must be at least 1")
+
+		return exitCodeErrInvalidServeArgs
+	}
+
+	dirLayout, err := layout.New(options.layoutName, "", "", 0)
+	if err != nil {
+		slog.Error("❌ Error creating layout: " + err.Error())
+
+		return exitCodeErrInvalidServeArgs
+	}
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	var cache *tfpath.Cache
+	if options.cachePath != "" {
+		cache = tfpath.NewCache(options.cachePath, 0, options.offline, gitBackend)
+	}
+
+	container := tfpath.NewContainer()
+
+	// all resources are kept in memory, and filtered with regular expressions from the page
+	traverser := tfpath.NewTraverser(
+		container,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		"^.*$",
+		"^.*$",
+		options.displayAttributes,
+		cache,
+	)
+
+	if options.modulesMirrorPath != "" {
+		container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+	}
+
+	dirLayout.Apply(traverser)
+
+	rootTfPath, exitCode := scanTerraformPath(container, traverser, cache, options.overridesPath, options.terraformPath, ".")
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	logMissingModules(container)
+
+	server, err := viewer.NewServer(
+		rootTfPath,
+		chart.Options{
+			Options: graph.Options{
+				OnlyRoot:        options.onlyRoot,
+				Module:          options.module,
+				MaxModulesDepth: options.maxModulesDepth,
+				Layout:          dirLayout,
+				ForEachMode:     options.forEachMode,
+				MaxInstances:    options.maxInstances,
+			},
+			IncludeFilenames: options.includeFilenames,
+		},
+		options.mermaidJSPath,
+	)
+	if err != nil {
+		slog.Error("❌ Error creating viewer: " + err.Error())
+
+		return exitCodeErrServingViewer
+	}
+
+	slog.Info(fmt.Sprintf("🌐 Viewer available at http://%s/", options.listenAddress))
+
+	err = server.ListenAndServe(options.listenAddress)
+	if err != nil {
+		slog.Error("❌ " + err.Error())
+
+		return exitCodeErrServingViewer
+	}
+
+	return 0
+}
@@ -0,0 +1,10 @@
+# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.90.0"
+  constraints = ">= 5.0.0"
+  hashes = [
+    "h1:FKs6lkQYr6sMY9Ae3HP5xNWq0wQtpVJH8RG7OnDbYbk=",
+  ]
+}
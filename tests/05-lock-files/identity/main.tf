@@ -0,0 +1,3 @@
+resource "type" "identity" {
+  name = "identity"
+}
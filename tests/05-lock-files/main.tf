@@ -0,0 +1,3 @@
+resource "type" "root" {
+  name = "root"
+}
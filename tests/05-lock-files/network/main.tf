@@ -0,0 +1,3 @@
+resource "type" "network" {
+  name = "network"
+}
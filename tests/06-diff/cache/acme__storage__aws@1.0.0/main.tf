@@ -0,0 +1,7 @@
+resource "type" "storage-1" {
+  name = "name-storage-1"
+}
+
+resource "type" "storage-2" {
+  name = "name-storage-2"
+}
@@ -0,0 +1,12 @@
+resource "type" "diff-1" {
+  name = "name-diff-1-renamed"
+}
+
+resource "type" "diff-3" {
+  name = "name-diff-3"
+}
+
+module "storage" {
+  source  = "acme/storage/aws"
+  version = "1.0.0"
+}
@@ -0,0 +1,12 @@
+resource "type" "diff-1" {
+  name = "name-diff-1"
+}
+
+resource "type" "diff-2" {
+  name = "name-diff-2"
+}
+
+module "storage" {
+  source  = "acme/storage/aws"
+  version = "1.0.0"
+}
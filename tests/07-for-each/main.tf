@@ -0,0 +1,58 @@
+variable "regions" {
+  default = ["eu-west-1", "us-east-1"]
+}
+
+locals {
+  tiers = {
+    web = "t3.small"
+    db  = "t3.large"
+  }
+}
+
+resource "type" "set" {
+  for_each = toset(["a", "b", "c"])
+  name     = "set-${each.key}"
+}
+
+resource "type" "map" {
+  for_each = {
+    admin  = "admin@example.com"
+    viewer = "viewer@example.com"
+  }
+  name = each.value
+}
+
+resource "type" "list" {
+  for_each = ["x", "y"]
+  name     = "list"
+}
+
+resource "type" "variable" {
+  for_each = var.regions
+  name     = "variable"
+}
+
+resource "type" "local" {
+  for_each = tomap(local.tiers)
+  name     = each.key
+}
+
+resource "type" "unknown" {
+  for_each = toset(data.external.names.result)
+  name     = "unknown"
+}
+
+resource "type" "counted" {
+  count = 2
+  name  = "counted-${count.index}"
+}
+
+module "buckets" {
+  source = "./modules/buckets"
+  names  = ["x", "y", "z"]
+}
+
+module "unknown_buckets" {
+  source = "./modules/buckets"
+  names  = data.external.buckets.result
+}
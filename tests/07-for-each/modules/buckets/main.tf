@@ -0,0 +1,8 @@
+variable "names" {
+  default = []
+}
+
+resource "type" "bucket" {
+  for_each = toset(var.names)
+  name     = "bucket-${each.key}"
+}
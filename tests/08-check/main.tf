@@ -0,0 +1,20 @@
+resource "type" "literal" {
+  name = "admin-role"
+}
+
+resource "type" "invalid" {
+  name = "Admin_Role"
+}
+
+resource "type" "template" {
+  for_each = toset(["admin", "viewer"])
+  name     = "${each.key}-role"
+}
+
+resource "type" "directive" {
+  name = "%{if true}admin%{endif}-role"
+}
+
+resource "type" "reference" {
+  name = var.name
+}
@@ -0,0 +1,11 @@
+include "common" {
+  path = "${get_terragrunt_dir()}/../../_envcommon/app.hcl"
+}
+
+dependency "vpc" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id = dependency.vpc.outputs.vpc_id
+}
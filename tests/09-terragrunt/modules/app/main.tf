@@ -0,0 +1,7 @@
+variable "vpc_id" {
+  type = string
+}
+
+resource "type" "app" {
+  name = "app-${var.vpc_id}"
+}
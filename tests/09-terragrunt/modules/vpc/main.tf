@@ -0,0 +1,7 @@
+resource "type" "vpc" {
+  name = "vpc"
+}
+
+output "vpc_id" {
+  value = type.vpc.id
+}
@@ -0,0 +1,15 @@
+component "network" {
+  source = "./modules/network"
+
+  inputs = {
+    cidr = var.cidr
+  }
+}
+
+component "app" {
+  source = "./modules/app"
+
+  inputs = {
+    subnet_id = component.network.subnet_id
+  }
+}
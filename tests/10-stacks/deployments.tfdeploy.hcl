@@ -0,0 +1,11 @@
+deployment "dev" {
+  inputs = {
+    cidr = "10.0.0.0/16"
+  }
+}
+
+deployment "prod" {
+  inputs = {
+    cidr = "10.1.0.0/16"
+  }
+}
@@ -0,0 +1,7 @@
+variable "subnet_id" {
+  type = string
+}
+
+resource "type" "app" {
+  name = "app"
+}
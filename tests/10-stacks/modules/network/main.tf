@@ -0,0 +1,11 @@
+variable "cidr" {
+  type = string
+}
+
+resource "type" "subnet" {
+  name = "subnet"
+}
+
+output "subnet_id" {
+  value = type.subnet.id
+}
@@ -0,0 +1,31 @@
+variable "buckets" {
+  default = ["logs"]
+}
+
+variable "replicas" {
+  default = 0
+}
+
+locals {
+  prefix = "${terraform.workspace}-app"
+}
+
+resource "type" "bucket" {
+  for_each = toset(var.buckets)
+  name     = "${local.prefix}-${each.key}"
+}
+
+resource "type" "replica" {
+  count = var.replicas
+  name  = "${local.prefix}-replica-${count.index}"
+}
+
+resource "type" "unknown" {
+  for_each = toset(data.external.names.result)
+  name     = each.key
+}
+
+module "monitoring" {
+  source = "./modules/bucket"
+  count  = terraform.workspace == "prod" ? 1 : 0
+}
@@ -0,0 +1,3 @@
+resource "type" "monitoring" {
+  name = "monitoring"
+}
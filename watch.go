@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"tfsketch/internal/chart"
+	"tfsketch/internal/tfpath"
+	"tfsketch/internal/watch"
+)
+
+// watchTerraformPaths watches directories of paths in the container and re-parses the ones with changed files,
+// re-links all the paths and generates the chart again, until interrupted.
+func watchTerraformPaths(
+	ctx context.Context,
+	container *tfpath.Container,
+	traverser *tfpath.Traverser,
+	cache *tfpath.Cache,
+	flowchart *chart.MermaidFlowChart,
+	rootTfPaths []*tfpath.TfPath,
+	outputFile, outputFormat string,
+	debounce time.Duration,
+) int {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dirs := watchedDirs(container, cache)
+
+	slog.Info(fmt.Sprintf("👀 Watching %d directories for changes, press Ctrl+C to stop", len(dirs)))
+
+	err := watch.Watch(ctx, dirs, debounce, func(changedDirs []string) {
+		resourcesBefore, modulesBefore := countResourcesAndModules(rootTfPaths)
+
+		slog.Info(fmt.Sprintf("🔄 Files changed in %d directories: %s", len(changedDirs), strings.Join(changedDirs, ", ")))
+
+		unknownDirs, err := container.ReparseDirs(traverser, cache, changedDirs)
+		if err != nil {
+			slog.Error("❌ Error parsing changed directories: " + err.Error())
+
+			return
+		}
+
+		for _, unknownDir := range unknownDirs {
+			slog.Debug(fmt.Sprintf("🔄 Skipped 📁%s as it is not scanned", unknownDir))
+		}
+
+		err = generateChart(flowchart, rootTfPaths, outputFile, outputFormat)
+		if err != nil {
+			slog.Error("❌ Error generating chart: " + err.Error())
+
+			return
+		}
+
+		resourcesAfter, modulesAfter := countResourcesAndModules(rootTfPaths)
+
+		slog.Info(
+			fmt.Sprintf(
+				"🔄 Chart 📄%s regenerated, resources: %d (%+d), modules: %d (%+d), unresolved module calls: %d",
+				outputFile,
+				resourcesAfter,
+				resourcesAfter-resourcesBefore,
+				modulesAfter,
+				modulesAfter-modulesBefore,
+				container.ResolutionReport().Unresolved,
+			),
+		)
+	})
+	if err != nil {
+		slog.Error("❌ Error watching directories: " + err.Error())
+
+		return exitCodeErrWatchingPaths
+	}
+
+	slog.Info("👋 Stopped watching")
+
+	return 0
+}
+
+// watchedDirs returns directories of paths in the container and their sub-directories, except downloaded modules.
+func watchedDirs(container *tfpath.Container, cache *tfpath.Cache) []string {
+	cacheDir := ""
+	if cache != nil {
+		cacheDir, _ = filepath.Abs(cache.Path())
+	}
+
+	dirs := []string{}
+
+	addDir := func(dir string) {
+		absDir, err := filepath.Abs(dir)
+		if err == nil && cacheDir != "" && strings.HasPrefix(absDir, cacheDir+string(filepath.Separator)) {
+			return
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	for _, tfPath := range container.Paths {
+		addDir(tfPath.Path)
+
+		for _, childTfPath := range tfPath.Children {
+			addDir(childTfPath.Path)
+		}
+	}
+
+	return dirs
+}
+
+func countResourcesAndModules(rootTfPaths []*tfpath.TfPath) (int, int) {
+	resources, modules := 0, 0
+
+	for _, rootTfPath := range rootTfPaths {
+		resources += len(rootTfPath.Resources)
+		modules += len(rootTfPath.Modules)
+
+		for _, childTfPath := range rootTfPath.Children {
+			resources += len(childTfPath.Resources)
+			modules += len(childTfPath.Modules)
+		}
+	}
+
+	return resources, modules
+}
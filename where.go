@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tfsketch/internal/lookup"
+	"tfsketch/internal/tfpath"
+)
+
+// whereOptions are values of the 'where' flags.
+type whereOptions struct {
+	terraformPaths []string
+
+	sourceRegexp string
+	typeRegexp   string
+	outputFile   string
+	format       string
+
+	pathIncludeRegexp string
+	pathExcludeRegexp string
+
+	overridesPath     string
+	cachePath         string
+	modulesMirrorPath string
+	gitBackendName    string
+
+	debug   bool
+	offline bool
+}
+
+func newWhereCmd() *cobra.Command {
+	var options whereOptions
+
+	whereCmd := &cobra.Command{
+		Use:   "where",
+		Short: "Find where modules and resources are used",
+		Long: "Print every chain of module calls from the scanned paths to module calls with matching source, or " +
+			"resources with matching type, with versions and file locations",
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(whereHandler(&options))
+		},
+	}
+
+	whereCmd.Flags().StringSliceVarP(&options.terraformPaths, "path", "", []string{}, "Paths to directories with terraform code, optionally as 'name=path' (required)")
+	whereCmd.MarkFlagRequired("path")
+	whereCmd.MarkFlagDirname("path")
+
+	whereCmd.Flags().StringVarP(&options.sourceRegexp, "source-regexp", "s", "", "Regular expression to match source of the module")
+	whereCmd.Flags().StringVarP(&options.typeRegexp, "type-regexp", "t", "", "Regular expression to match type of the resource")
+
+	whereCmd.Flags().StringVarP(&options.outputFile, "output", "", "", "Path to an output file (standard output if empty)")
+	whereCmd.MarkFlagFilename("output")
+	whereCmd.Flags().StringVarP(&options.format, "format", "", checkFormatText, "Output format: 'text' or 'json'")
+
+	whereCmd.Flags().StringVarP(&options.pathIncludeRegexp, "path-include-regexp", "i", "^.*$", "Regular expression to include paths")
+	whereCmd.Flags().StringVarP(&options.pathExcludeRegexp, "path-exclude-regexp", "e", "^SillyName$", "Regular expression to exclude paths")
+
+	whereCmd.Flags().StringVarP(&options.overridesPath, "overrides", "o", "", "YAML file mapping external modules to local paths")
+	whereCmd.Flags().StringVarP(&options.cachePath, "cache", "c", "", "Path to directory where modules will be downloaded and cached")
+	whereCmd.Flags().StringVarP(&options.modulesMirrorPath, "module-mirror", "", "", "Path to directory with pre-seeded modules consulted before downloading")
+	whereCmd.Flags().StringVarP(&options.gitBackendName, "git-backend", "", tfpath.GitBackendExec, "Git implementation used to download modules: 'exec' or 'go-git'")
+	whereCmd.Flags().BoolVarP(&options.offline, "offline", "", false, "Do not fetch anything and fail when a module is missing in the cache")
+	whereCmd.Flags().BoolVarP(&options.debug, "debug", "d", false, "Enable debug mode")
+
+	return whereCmd
+}
+
+//nolint:funlen
+func whereHandler(options *whereOptions) int {
+	setLogger(options.debug)
+
+	// logs go to standard error so that they do not mix with the matches
+	if options.outputFile == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel(options.debug)})))
+	}
+
+	if !slices.Contains([]string{checkFormatText, checkFormatJSON}, options.format) {
+		slog.Error("❌ Unknown output format: " + options.format)
+
+		return exitCodeErrInvalidWhereArgs
+	}
+
+	if options.sourceRegexp == "" && options.typeRegexp == "" {
+		slog.Error("❌ Either --source-regexp or --type-regexp must be set")
+
+		return exitCodeErrInvalidWhereArgs
+	}
+
+	var compiledSourceRegexp, compiledTypeRegexp *regexp.Regexp
+
+	var err error
+
+	if options.sourceRegexp != "" {
+		compiledSourceRegexp, err = regexp.Compile(options.sourceRegexp)
+		if err != nil {
+			slog.Error("❌ Invalid source regexp: " + err.Error())
+
+			return exitCodeErrInvalidWhereArgs
+		}
+	}
+
+	// resources are parsed only when they are looked for
+	parseTypeRegexp := "^SillyName$"
+
+	if options.typeRegexp != "" {
+		compiledTypeRegexp, err = regexp.Compile(options.typeRegexp)
+		if err != nil {
+			slog.Error("❌ Invalid type regexp: " + err.Error())
+
+			return exitCodeErrInvalidWhereArgs
+		}
+
+		parseTypeRegexp = options.typeRegexp
+	}
+
+	rootNames, rootPaths, exitCode := parseRootPaths(options.terraformPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	gitBackend, err := tfpath.NewGitBackend(options.gitBackendName)
+	if err != nil {
+		slog.Error("❌ Error creating git backend: " + err.Error())
+
+		return exitCodeErrCreatingGitBackend
+	}
+
+	var cache *tfpath.Cache
+	if options.cachePath != "" {
+		cache = tfpath.NewCache(options.cachePath, 0, options.offline, gitBackend)
+	}
+
+	container := tfpath.NewContainer()
+
+	traverser := tfpath.NewTraverser(
+		container,
+		options.pathIncludeRegexp,
+		options.pathExcludeRegexp,
+		parseTypeRegexp,
+		"^.*$",
+		"",
+		cache,
+	)
+
+	if options.modulesMirrorPath != "" {
+		container.Mirror = tfpath.NewMirror(options.modulesMirrorPath)
+	}
+
+	rootTfPaths, exitCode := scanTerraformPaths(container, traverser, cache, options.overridesPath, rootNames, rootPaths)
+	if exitCode != 0 {
+		return exitCode
+	}
+
+	logMissingModules(container)
+
+	matches := lookup.NewFinder(compiledSourceRegexp, compiledTypeRegexp).Find(rootTfPaths)
+
+	output := ""
+
+	switch options.format {
+	case checkFormatJSON:
+		matchesBytes, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			slog.Error("❌ Error marshalling matches: " + err.Error())
+
+			return exitCodeErrWritingWhereOutput
+		}
+
+		output = string(matchesBytes) + "\n"
+	default:
+		builder := &strings.Builder{}
+		for _, match := range matches {
+			builder.WriteString(whereMatchLine(match, len(rootTfPaths) > 1))
+		}
+
+		output = builder.String()
+	}
+
+	if options.outputFile == "" {
+		_, _ = fmt.Fprint(os.Stdout, output)
+	} else {
+		err = os.WriteFile(filepath.Clean(options.outputFile), []byte(output), newFilesMode)
+		if err != nil {
+			slog.Error(fmt.Sprintf("❌ Error writing where output 📄%s: %s", options.outputFile, err.Error()))
+
+			return exitCodeErrWritingWhereOutput
+		}
+	}
+
+	slog.Info(fmt.Sprintf("🔸 %d matches found", len(matches)))
+
+	return 0
+}
+
+// whereMatchLine returns the match as a line with the directory, the module calls leading to it, and the match, eg.
+// 'network: module.vpc (vpc/aws@5.0.0, main.tf:1) > module.flow_log (./modules/flow-log, vpc/main.tf:10) >
+// aws_iam_role.this (vpc/modules/flow-log/main.tf:3)'.
+func whereMatchLine(match *lookup.Match, withRoot bool) string {
+	location := match.RelPath
+	if withRoot {
+		location = match.Root + ":" + location
+	}
+
+	steps := make([]string, 0, len(match.Calls)+1)
+
+	for _, call := range match.Calls {
+		steps = append(
+			steps,
+			fmt.Sprintf("%s (%s, %s:%d)", call.Address(), sourceAtVersion(call.Source, call.Version), call.File,
+				call.LineStart),
+		)
+	}
+
+	if match.Kind == lookup.KindModule {
+		steps = append(
+			steps,
+			fmt.Sprintf("%s (%s, %s:%d)", match.Address, sourceAtVersion(match.Source, match.Version), match.File,
+				match.LineStart),
+		)
+	} else {
+		steps = append(steps, fmt.Sprintf("%s (%s:%d)", match.Address, match.File, match.LineStart))
+	}
+
+	return location + ": " + strings.Join(steps, " > ") + "\n"
+}
+
+func sourceAtVersion(source, version string) string {
+	if version == "" {
+		return source
+	}
+
+	return source + "@" + version
+}